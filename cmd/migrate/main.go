@@ -0,0 +1,77 @@
+package main
+
+import (
+	"backend-go/config"
+	"backend-go/services"
+	"context"
+	"flag"
+	"log"
+)
+
+// Perintah migrasi data MongoDB. Contoh:
+//
+//	go run ./cmd/migrate -step=embedded-messages -dry-run
+//	go run ./cmd/migrate -step=unify-messages
+//	go run ./cmd/migrate -step=search-text -all
+//	go run ./cmd/migrate -step=dedupe-conversations
+func main() {
+	step := flag.String("step", "", "langkah migrasi: embedded-messages, unify-messages, search-text, conversation-flags, dedupe-conversations")
+	dryRun := flag.Bool("dry-run", false, "hitung perubahan tanpa menulis ke database")
+	all := flag.Bool("all", false, "search-text: hitung ulang semua pesan, bukan hanya yang belum terindeks")
+	flag.Parse()
+
+	if err := config.LoadConfig(); err != nil {
+		log.Fatal("Gagal memuat konfigurasi:", err)
+	}
+
+	if err := config.InitMongoDB(); err != nil {
+		log.Fatal("Gagal menginisialisasi database (MongoDB):", err)
+	}
+
+	ctx := context.Background()
+
+	switch *step {
+	case "embedded-messages":
+		stats, err := services.MigrateEmbeddedMessages(ctx, *dryRun)
+		if err != nil {
+			log.Fatal("❌ Migrasi gagal:", err)
+		}
+		log.Printf("✅ Migrasi selesai (dry-run=%v): %d percakapan, %d pesan teks, %d pesan suara dipindahkan, %d dilewati",
+			*dryRun, stats.Conversations, stats.Messages, stats.VoiceMessages, stats.Skipped)
+	case "unify-messages":
+		stats, err := services.MigrateUnifiedMessages(ctx, *dryRun)
+		if err != nil {
+			log.Fatal("❌ Migrasi gagal:", err)
+		}
+		log.Printf("✅ Migrasi selesai (dry-run=%v): %d pesan teks diubah, %d pesan suara dipindahkan dari %d chat",
+			*dryRun, stats.Messages, stats.VoiceMessages, stats.Conversations)
+	case "search-text":
+		stats, err := services.BackfillSearchText(ctx, *all, *dryRun)
+		if err != nil {
+			log.Fatal("❌ Migrasi gagal:", err)
+		}
+		log.Printf("✅ Migrasi selesai (dry-run=%v): %d pesan diindeks ulang", *dryRun, stats.Messages)
+	case "conversation-flags":
+		stats, err := services.BackfillConversationFlags(ctx, *dryRun)
+		if err != nil {
+			log.Fatal("❌ Migrasi gagal:", err)
+		}
+		log.Printf("✅ Migrasi selesai (dry-run=%v): %d field flag percakapan diisi", *dryRun, stats.Conversations)
+	case "dedupe-conversations":
+		stats, err := services.DedupeConversations(ctx, *dryRun)
+		if err != nil {
+			log.Fatal("❌ Migrasi gagal:", err)
+		}
+		log.Printf("✅ Migrasi selesai (dry-run=%v): %d chat ganda digabung, %d pesan diberi nomor ulang, %d dilewati",
+			*dryRun, stats.Conversations, stats.Messages, stats.Skipped)
+	default:
+		log.Fatalf("Langkah migrasi tidak dikenal: %q", *step)
+	}
+
+	// Indeks dibuat setelah langkah migrasi agar indeks unik tidak gagal karena data lama
+	if !*dryRun {
+		if err := config.EnsureMongoIndexes(); err != nil {
+			log.Fatal("Gagal membuat indeks MongoDB:", err)
+		}
+	}
+}
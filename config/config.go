@@ -4,7 +4,9 @@ import (
 	"context"
 	"log"
 	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -24,10 +26,110 @@ var (
 	Port                   string
 	AWSRegion              string
 	AWSBucketName          string
+	AudioLocalDir          string // penyimpanan audio bila AWS tidak dikonfigurasi
 	AWSAccessKeyID         string
 	AWSSecretAccessKey     string
 	GoogleApplicationCreds string
 
+	// Kebijakan PII: kunci AES (base64, 32 byte) dan izin menyimpan teks asli terenkripsi
+	PIIEncryptionKey          string
+	PIIStoreEncryptedOriginal bool
+
+	// Rate limiting: format "<jumlah>/<s|m|h>", contoh "30/m"; REDIS_URL opsional
+	RedisURL            string
+	RateLimitChat       string
+	RateLimitVoice      string
+	RateLimitLogin      string
+	AbuseProfanityMode  string
+	MaxChatMessageChars int
+
+	// Pencarian pesan: "mongo" (indeks teks MongoDB) atau "memory" (indeks terbalik di proses)
+	SearchBackend string
+
+	// Ekspor transkrip: identitas bank di PDF/HTML dan batas pesan untuk ekspor langsung
+	ExportBrandName       string
+	ExportBrandColor      string
+	ExportSyncMaxMessages int
+	ExportWorkers         int
+	// Job ekspor massal: masa sewa klaim, direktori berkas sementara, dan umur file di S3
+	ExportJobLease  time.Duration
+	ExportSpoolDir  string
+	ExportRetention time.Duration
+
+	// Tong sampah chat: lama penyimpanan sebelum dihapus permanen dan interval purger
+	TrashRetentionDays int
+	TrashPurgeInterval time.Duration
+
+	// Tautan berbagi: URL dasar frontend untuk halaman baca-saja dan masa berlaku (jam)
+	ShareBaseURL         string
+	ShareDefaultTTLHours int
+	ShareMaxTTLHours     int
+	RateLimitShare       string
+
+	IdempotencyTTL         time.Duration
+	IdempotencyWait        time.Duration
+	IdempotencyLockTimeout time.Duration
+
+	OutboxInterval      time.Duration
+	OutboxStuckAttempts int
+	OutboxWebhookURL    string
+	OutboxWebhookSecret string
+
+	VoiceWorkers        int
+	VoiceJobDir         string
+	VoiceMaxUploadBytes int64
+	VoiceMaxDuration    time.Duration
+
+	// Worker job latar: interval polling job tertunda dan masa sewa klaim job yang berjalan
+	JobPollInterval time.Duration
+	VoiceJobLease   time.Duration
+
+	// Format balasan default (mp3, ogg, wav) dan format salinan rekaman untuk STT
+	VoiceOutputFormat string
+	VoiceSTTFormat    string
+
+	// Pra-pemrosesan rekaman sebelum STT
+	VoiceVADEnabled     bool
+	VoiceVADNoiseDB     float64
+	VoiceVADMinSpeech   time.Duration
+	VoiceTrimSilence    bool
+	VoiceLoudnorm       bool
+	VoiceLoudnormTarget float64
+	VoiceDenoise        bool
+	VoiceDenoiseFloor   float64
+
+	// Transcoder ffmpeg: biner, jumlah proses bersamaan, batas waktu, dan direktori kerja
+	FFmpegPath           string
+	FFprobePath          string
+	TranscodeConcurrency int
+	TranscodeTimeout     time.Duration
+	TranscodeTempDir     string
+
+	VoiceStreamMaxUtteranceBytes int
+	VoiceStreamChunkBytes        int
+	VoiceStreamOrigins           []string
+
+	STTProvider         string
+	STTFallbackProvider string
+	STTTimeout          time.Duration
+	STTHTTPURL          string
+	STTFakeTranscript   string
+	STTStreamProvider   string
+
+	TTSProvider         string
+	TTSFallbackProvider string
+	TTSTimeout          time.Duration
+	TTSPollyVoice       string
+	TTSPollyEngine      string
+	TTSLocalCommand     string
+	TTSSSMLEnabled      bool
+	TTSGRPCPool         int
+	TTSCacheEnabled     bool
+	TTSPrewarmOnStart   bool
+	TTSPrewarmFile      string
+	TTSPrewarmTop       int
+	TTSPrewarmMinCount  int
+
 	// singleton lock
 	loadConfigOnce sync.Once
 )
@@ -65,7 +167,162 @@ func LoadConfig() error {
 		AWSSecretAccessKey = viper.GetString("AWS_SECRET_ACCESS_KEY")
 		AWSRegion = viper.GetString("AWS_REGION")
 		AWSBucketName = viper.GetString("AWS_BUCKET_NAME")
+		viper.SetDefault("AUDIO_LOCAL_DIR", "uploads")
+		AudioLocalDir = viper.GetString("AUDIO_LOCAL_DIR")
 		GoogleApplicationCreds = viper.GetString("GOOGLE_APPLICATION_CREDENTIALS")
+		PIIEncryptionKey = viper.GetString("PII_ENCRYPTION_KEY")
+		PIIStoreEncryptedOriginal = viper.GetBool("PII_STORE_ENCRYPTED_ORIGINAL")
+
+		viper.SetDefault("RATE_LIMIT_CHAT", "30/m")
+		viper.SetDefault("RATE_LIMIT_VOICE", "10/m")
+		viper.SetDefault("RATE_LIMIT_LOGIN", "10/m")
+		viper.SetDefault("ABUSE_PROFANITY_MODE", "flag")
+		viper.SetDefault("MAX_CHAT_MESSAGE_CHARS", 2000)
+		RedisURL = viper.GetString("REDIS_URL")
+		RateLimitChat = viper.GetString("RATE_LIMIT_CHAT")
+		RateLimitVoice = viper.GetString("RATE_LIMIT_VOICE")
+		RateLimitLogin = viper.GetString("RATE_LIMIT_LOGIN")
+		AbuseProfanityMode = viper.GetString("ABUSE_PROFANITY_MODE")
+		MaxChatMessageChars = viper.GetInt("MAX_CHAT_MESSAGE_CHARS")
+
+		viper.SetDefault("SEARCH_BACKEND", "mongo")
+		SearchBackend = viper.GetString("SEARCH_BACKEND")
+
+		viper.SetDefault("EXPORT_BRAND_NAME", "Bank Nagari")
+		viper.SetDefault("EXPORT_BRAND_COLOR", "#00704A")
+		viper.SetDefault("EXPORT_SYNC_MAX_MESSAGES", 500)
+		viper.SetDefault("EXPORT_WORKERS", 2)
+		viper.SetDefault("EXPORT_JOB_LEASE", "20m") // harus lebih lama dari batas waktu satu job (10 menit)
+		viper.SetDefault("EXPORT_SPOOL_DIR", os.TempDir())
+		viper.SetDefault("EXPORT_RETENTION", "168h")
+		ExportBrandName = viper.GetString("EXPORT_BRAND_NAME")
+		ExportBrandColor = viper.GetString("EXPORT_BRAND_COLOR")
+		ExportSyncMaxMessages = viper.GetInt("EXPORT_SYNC_MAX_MESSAGES")
+		ExportWorkers = viper.GetInt("EXPORT_WORKERS")
+		ExportJobLease = viper.GetDuration("EXPORT_JOB_LEASE")
+		ExportSpoolDir = viper.GetString("EXPORT_SPOOL_DIR")
+		ExportRetention = viper.GetDuration("EXPORT_RETENTION")
+
+		viper.SetDefault("TRASH_RETENTION_DAYS", 30)
+		viper.SetDefault("TRASH_PURGE_INTERVAL", "1h")
+		TrashRetentionDays = viper.GetInt("TRASH_RETENTION_DAYS")
+		TrashPurgeInterval = viper.GetDuration("TRASH_PURGE_INTERVAL")
+
+		viper.SetDefault("SHARE_DEFAULT_TTL_HOURS", 72)
+		viper.SetDefault("SHARE_MAX_TTL_HOURS", 720)
+		viper.SetDefault("RATE_LIMIT_SHARE", "60/m")
+		ShareBaseURL = viper.GetString("SHARE_BASE_URL")
+		ShareDefaultTTLHours = viper.GetInt("SHARE_DEFAULT_TTL_HOURS")
+		ShareMaxTTLHours = viper.GetInt("SHARE_MAX_TTL_HOURS")
+		RateLimitShare = viper.GetString("RATE_LIMIT_SHARE")
+
+		viper.SetDefault("IDEMPOTENCY_TTL", "24h")
+		viper.SetDefault("IDEMPOTENCY_WAIT", "60s")
+		viper.SetDefault("IDEMPOTENCY_LOCK_TIMEOUT", "5m")
+		IdempotencyTTL = viper.GetDuration("IDEMPOTENCY_TTL")
+		IdempotencyWait = viper.GetDuration("IDEMPOTENCY_WAIT")
+		IdempotencyLockTimeout = viper.GetDuration("IDEMPOTENCY_LOCK_TIMEOUT")
+
+		viper.SetDefault("OUTBOX_INTERVAL", "30s")
+		viper.SetDefault("OUTBOX_STUCK_ATTEMPTS", 5)
+		OutboxInterval = viper.GetDuration("OUTBOX_INTERVAL")
+		OutboxStuckAttempts = viper.GetInt("OUTBOX_STUCK_ATTEMPTS")
+		OutboxWebhookURL = viper.GetString("OUTBOX_WEBHOOK_URL")
+		OutboxWebhookSecret = viper.GetString("OUTBOX_WEBHOOK_SECRET")
+
+		viper.SetDefault("VOICE_WORKERS", 4)
+		viper.SetDefault("VOICE_JOB_DIR", "/tmp/voice-jobs")
+		viper.SetDefault("VOICE_MAX_UPLOAD_BYTES", 10<<20)
+		viper.SetDefault("VOICE_MAX_DURATION", "2m")
+		VoiceWorkers = viper.GetInt("VOICE_WORKERS")
+		VoiceJobDir = viper.GetString("VOICE_JOB_DIR")
+		VoiceMaxUploadBytes = viper.GetInt64("VOICE_MAX_UPLOAD_BYTES")
+		VoiceMaxDuration = viper.GetDuration("VOICE_MAX_DURATION")
+
+		viper.SetDefault("JOB_POLL_INTERVAL", "5s")
+		viper.SetDefault("VOICE_JOB_LEASE", "10m") // harus lebih lama dari batas waktu satu job (5 menit)
+		JobPollInterval = viper.GetDuration("JOB_POLL_INTERVAL")
+		VoiceJobLease = viper.GetDuration("VOICE_JOB_LEASE")
+
+		viper.SetDefault("VOICE_OUTPUT_FORMAT", "mp3")
+		viper.SetDefault("VOICE_STT_FORMAT", "mp3")
+		VoiceOutputFormat = viper.GetString("VOICE_OUTPUT_FORMAT")
+		VoiceSTTFormat = viper.GetString("VOICE_STT_FORMAT")
+
+		viper.SetDefault("VOICE_VAD_ENABLED", true)
+		viper.SetDefault("VOICE_VAD_NOISE_DB", -35)       // di bawah level ini dianggap diam
+		viper.SetDefault("VOICE_VAD_MIN_SPEECH", "500ms") // ucapan lebih pendek ditolak
+		viper.SetDefault("VOICE_TRIM_SILENCE", true)
+		viper.SetDefault("VOICE_LOUDNORM", true)
+		viper.SetDefault("VOICE_LOUDNORM_TARGET", -16) // LUFS
+		viper.SetDefault("VOICE_DENOISE", false)
+		viper.SetDefault("VOICE_DENOISE_FLOOR", -25) // dB, untuk filter afftdn
+		VoiceVADEnabled = viper.GetBool("VOICE_VAD_ENABLED")
+		VoiceVADNoiseDB = viper.GetFloat64("VOICE_VAD_NOISE_DB")
+		VoiceVADMinSpeech = viper.GetDuration("VOICE_VAD_MIN_SPEECH")
+		VoiceTrimSilence = viper.GetBool("VOICE_TRIM_SILENCE")
+		VoiceLoudnorm = viper.GetBool("VOICE_LOUDNORM")
+		VoiceLoudnormTarget = viper.GetFloat64("VOICE_LOUDNORM_TARGET")
+		VoiceDenoise = viper.GetBool("VOICE_DENOISE")
+		VoiceDenoiseFloor = viper.GetFloat64("VOICE_DENOISE_FLOOR")
+
+		viper.SetDefault("FFMPEG_PATH", "ffmpeg")
+		viper.SetDefault("FFPROBE_PATH", "ffprobe")
+		viper.SetDefault("TRANSCODE_CONCURRENCY", 4)
+		viper.SetDefault("TRANSCODE_TIMEOUT", "60s")
+		viper.SetDefault("TRANSCODE_TEMP_DIR", os.TempDir())
+		FFmpegPath = viper.GetString("FFMPEG_PATH")
+		FFprobePath = viper.GetString("FFPROBE_PATH")
+		TranscodeConcurrency = viper.GetInt("TRANSCODE_CONCURRENCY")
+		TranscodeTimeout = viper.GetDuration("TRANSCODE_TIMEOUT")
+		TranscodeTempDir = viper.GetString("TRANSCODE_TEMP_DIR")
+
+		viper.SetDefault("STT_PROVIDER", "aws")
+		viper.SetDefault("STT_TIMEOUT", "90s")
+		viper.SetDefault("STT_HTTP_URL", "http://localhost:8000/v1/audio/transcriptions")
+		viper.SetDefault("STT_FAKE_TRANSCRIPT", "saya ingin mengecek saldo rekening")
+		STTProvider = viper.GetString("STT_PROVIDER")
+		STTFallbackProvider = viper.GetString("STT_FALLBACK_PROVIDER")
+		STTTimeout = viper.GetDuration("STT_TIMEOUT")
+		STTHTTPURL = viper.GetString("STT_HTTP_URL")
+		STTFakeTranscript = viper.GetString("STT_FAKE_TRANSCRIPT")
+
+		// Percakapan suara real-time lewat WebSocket (/voice/stream/:chatID)
+		viper.SetDefault("STT_STREAM_PROVIDER", "google")
+		viper.SetDefault("VOICE_STREAM_MAX_UTTERANCE_BYTES", 2<<20) // ±60 detik PCM 16 kHz
+		viper.SetDefault("VOICE_STREAM_CHUNK_BYTES", 16<<10)
+		viper.SetDefault("VOICE_STREAM_ORIGINS", "http://localhost:8501,http://127.0.0.1:8501")
+		STTStreamProvider = viper.GetString("STT_STREAM_PROVIDER")
+		VoiceStreamMaxUtteranceBytes = viper.GetInt("VOICE_STREAM_MAX_UTTERANCE_BYTES")
+		VoiceStreamChunkBytes = viper.GetInt("VOICE_STREAM_CHUNK_BYTES")
+		VoiceStreamOrigins = strings.Split(viper.GetString("VOICE_STREAM_ORIGINS"), ",")
+
+		viper.SetDefault("TTS_PROVIDER", "google")
+		viper.SetDefault("TTS_TIMEOUT", "20s")
+		viper.SetDefault("TTS_POLLY_ENGINE", "standard")
+		viper.SetDefault("TTS_LOCAL_COMMAND", "espeak-ng -v id --stdout")
+		TTSProvider = viper.GetString("TTS_PROVIDER")
+		TTSFallbackProvider = viper.GetString("TTS_FALLBACK_PROVIDER")
+		TTSTimeout = viper.GetDuration("TTS_TIMEOUT")
+		TTSPollyVoice = viper.GetString("TTS_POLLY_VOICE") // Wajib bila polly dipakai; Polly tidak punya suara id-ID
+		TTSPollyEngine = viper.GetString("TTS_POLLY_ENGINE")
+		TTSLocalCommand = viper.GetString("TTS_LOCAL_COMMAND")
+
+		// SSML untuk nominal rupiah, nomor rekening, tanggal, dan singkatan
+		viper.SetDefault("TTS_SSML_ENABLED", true)
+		TTSSSMLEnabled = viper.GetBool("TTS_SSML_ENABLED")
+
+		// Cache audio balasan di S3 (bot/cache/<hash>.mp3) dan pre-warm katalog balasan
+		viper.SetDefault("TTS_GRPC_POOL", 4)
+		viper.SetDefault("TTS_CACHE_ENABLED", true)
+		viper.SetDefault("TTS_PREWARM_TOP", 100)
+		viper.SetDefault("TTS_PREWARM_MIN_COUNT", 3)
+		TTSGRPCPool = viper.GetInt("TTS_GRPC_POOL")
+		TTSCacheEnabled = viper.GetBool("TTS_CACHE_ENABLED")
+		TTSPrewarmOnStart = viper.GetBool("TTS_PREWARM_ON_START")
+		TTSPrewarmFile = viper.GetString("TTS_PREWARM_FILE")
+		TTSPrewarmTop = viper.GetInt("TTS_PREWARM_TOP")
+		TTSPrewarmMinCount = viper.GetInt("TTS_PREWARM_MIN_COUNT")
 
 		// Set environment var for Google Cloud SDK
 		if GoogleApplicationCreds == "" {
@@ -84,6 +341,12 @@ func LoadConfig() error {
 	return loadError
 }
 
+// AWSEnabled bernilai true bila kredensial dan bucket AWS diatur. Tanpa AWS, audio
+// disimpan di AUDIO_LOCAL_DIR dan provider aws/polly tidak dapat dipakai.
+func AWSEnabled() bool {
+	return AWSAccessKeyID != "" && AWSSecretAccessKey != "" && AWSBucketName != ""
+}
+
 func LoadAWSConfig() error {
 	cfg, err := config.LoadDefaultConfig(context.TODO(),
 		config.WithRegion(AWSRegion),
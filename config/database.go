@@ -48,6 +48,23 @@ func InitDB() error {
 	return initError
 }
 
+// postgresSchema berisi perubahan skema PostgreSQL yang aman dijalankan berulang kali
+var postgresSchema = []string{
+	// Waktu pesan yang terakhir menetapkan last_chat_id; outbox yang terlambat tidak
+	// boleh menimpa chat yang lebih baru
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS last_chat_at TIMESTAMPTZ`,
+}
+
+// EnsurePostgresSchema menerapkan postgresSchema secara berurutan
+func EnsurePostgresSchema() error {
+	for _, stmt := range postgresSchema {
+		if _, err := DB.Exec(stmt); err != nil {
+			return fmt.Errorf("%q: %v", stmt, err)
+		}
+	}
+	return nil
+}
+
 // CloseDB closes the database connection gracefully
 func CloseDB() error {
 	if DB != nil {
@@ -6,6 +6,7 @@ import (
 	"log"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -14,6 +15,10 @@ var MongoClient *mongo.Client
 var MongoDB *mongo.Database
 var MongoChatCollection *mongo.Collection
 
+// MongoTransactions bernilai true bila server adalah replica set atau mongos sehingga
+// transaksi multi-dokumen tersedia
+var MongoTransactions bool
+
 func InitMongoDB() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -39,5 +44,22 @@ func InitMongoDB() error {
 	MongoDB = client.Database(dbName)
 	MongoChatCollection = MongoDB.Collection("conversations")
 
+	MongoTransactions = DetectMongoTransactions(ctx, client)
+	if !MongoTransactions {
+		log.Println("⚠️ MongoDB berjalan standalone: penyimpanan pesan tidak berjalan dalam transaksi, gunakan replica set di produksi")
+	}
+
 	return nil
 }
+
+// DetectMongoTransactions memeriksa lewat perintah hello apakah server mendukung transaksi
+func DetectMongoTransactions(ctx context.Context, client *mongo.Client) bool {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		return false
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid"
+}
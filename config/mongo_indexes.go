@@ -0,0 +1,169 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrRequiredMongoIndex menandai kegagalan indeks yang menjadi dasar integritas data
+var ErrRequiredMongoIndex = errors.New("indeks MongoDB wajib tidak terpasang")
+
+// requiredMongoIndexes tidak boleh hilang: ReserveMessageSeq mengandalkan chat_id_unique
+// untuk menolak upsert lintas pengguna dan mencegah dokumen percakapan ganda
+var requiredMongoIndexes = map[string]bool{
+	"conversations.chat_id_unique": true,
+}
+
+// mongoIndexes berisi indeks wajib per collection
+var mongoIndexes = map[string][]mongo.IndexModel{
+	"conversations": {
+		{
+			Keys:    bson.D{{Key: "chat_id", Value: 1}},
+			Options: options.Index().SetName("chat_id_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "updated_at", Value: -1}},
+			Options: options.Index().SetName("user_id_updated_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "updated_at", Value: -1}, {Key: "chat_id", Value: -1}},
+			Options: options.Index().SetName("updated_at_chat_id"),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "pinned", Value: -1}, {Key: "updated_at", Value: -1}},
+			Options: options.Index().SetName("user_id_pinned_updated_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "labels", Value: 1}},
+			Options: options.Index().SetName("user_id_labels"),
+		},
+		{
+			// Hanya percakapan dengan item outbox tertunda yang masuk indeks ini
+			Keys:    bson.D{{Key: "outbox.next_attempt_at", Value: 1}},
+			Options: options.Index().SetName("outbox_next_attempt_at").SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "outbox._id", Value: 1}},
+			Options: options.Index().SetName("outbox_id").SetSparse(true),
+		},
+		{
+			// Hanya chat di tong sampah yang masuk indeks ini
+			Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+			Options: options.Index().SetName("deleted_at").SetPartialFilterExpression(bson.M{"deleted_at": bson.M{"$exists": true}}),
+		},
+	},
+	"messages": {
+		{
+			Keys:    bson.D{{Key: "chat_id", Value: 1}, {Key: "seq", Value: 1}},
+			Options: options.Index().SetName("chat_id_seq_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "chat_id", Value: 1}, {Key: "modality", Value: 1}, {Key: "seq", Value: 1}},
+			Options: options.Index().SetName("chat_id_modality_seq"),
+		},
+		{
+			// Stem sudah dihitung di aplikasi, jadi stemming bawaan MongoDB dimatikan
+			Keys:    bson.D{{Key: "search_text", Value: "text"}},
+			Options: options.Index().SetName("search_text").SetDefaultLanguage("none"),
+		},
+		{
+			// Dipakai ServeAudioFile untuk memastikan audio lokal milik pemanggil
+			Keys:    bson.D{{Key: "audio.url", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetName("audio_url_user_id").SetPartialFilterExpression(bson.M{"audio.url": bson.M{"$exists": true}}),
+		},
+	},
+	"labels": {
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().SetName("user_id_name_unique").SetUnique(true),
+		},
+	},
+	"tts_lexicon": {
+		{
+			Keys:    bson.D{{Key: "term", Value: 1}},
+			Options: options.Index().SetName("term_unique").SetUnique(true),
+		},
+	},
+	"share_links": {
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetName("token_hash_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "chat_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("chat_id_created_at"),
+		},
+	},
+	"share_access_log": {
+		{
+			Keys:    bson.D{{Key: "share_id", Value: 1}, {Key: "at", Value: -1}},
+			Options: options.Index().SetName("share_id_at"),
+		},
+	},
+	"idempotency_keys": {
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0),
+		},
+		{
+			Keys:    bson.D{{Key: "chat_id", Value: 1}},
+			Options: options.Index().SetName("chat_id").SetSparse(true),
+		},
+	},
+	"voice_jobs": {
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("status_created_at"),
+		},
+		{
+			// Job selesai atau gagal dibuang setelah 7 hari
+			Keys:    bson.D{{Key: "completed_at", Value: 1}},
+			Options: options.Index().SetName("completed_at_ttl").SetExpireAfterSeconds(7 * 24 * 3600),
+		},
+	},
+	"export_jobs": {
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("status_created_at"),
+		},
+		{
+			Keys:    bson.D{{Key: "chat_ids", Value: 1}},
+			Options: options.Index().SetName("chat_ids"),
+		},
+	},
+}
+
+// EnsureMongoIndexes membuat indeks yang dibutuhkan saat startup (idempoten). Setiap
+// indeks dibuat terpisah sehingga satu indeks yang gagal tidak menghalangi indeks lain;
+// semua kegagalan dikembalikan, dan kegagalan indeks di requiredMongoIndexes (mis.
+// chat_id_unique pada data dengan percakapan ganda) dibungkus ErrRequiredMongoIndex.
+func EnsureMongoIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var errs []error
+	for collection, indexes := range mongoIndexes {
+		for _, index := range indexes {
+			if _, err := MongoDB.Collection(collection).Indexes().CreateOne(ctx, index); err != nil {
+				name := collection + "." + *index.Options.Name
+				if requiredMongoIndexes[name] {
+					err = fmt.Errorf("%w: %w", ErrRequiredMongoIndex, err)
+				}
+				errs = append(errs, fmt.Errorf("gagal membuat indeks %s: %w", name, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	log.Println("✅ Indeks MongoDB sudah dipastikan")
+	return nil
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisClient bersifat opsional; nil jika REDIS_URL tidak diatur
+var RedisClient *redis.Client
+
+// InitRedis menghubungkan ke Redis (atau server kompatibel Redis) bila REDIS_URL diatur
+func InitRedis() error {
+	if RedisURL == "" {
+		log.Println("ℹ️ REDIS_URL belum diatur, rate limit memakai penyimpanan in-memory")
+		return nil
+	}
+
+	opts, err := redis.ParseURL(RedisURL)
+	if err != nil {
+		return fmt.Errorf("REDIS_URL tidak valid: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("gagal melakukan ping ke Redis: %w", err)
+	}
+
+	RedisClient = client
+	log.Println("✅ Terhubung ke Redis!")
+	return nil
+}
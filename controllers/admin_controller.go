@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"backend-go/services"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -27,10 +28,52 @@ func GetAdminMetricsHandler(c *gin.Context) {
 }
 
 func GetRecentConversationsHandler(c *gin.Context) {
-	convos, err := services.GetRecentConversations(c)
+	req, ok := pageRequestFromQuery(c)
+	if !ok {
+		return
+	}
+
+	convos, err := services.GetRecentConversations(c, req)
+	if services.IsCursorError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, convos)
 }
+
+// GetOutboxHandler menampilkan efek samping yang belum terkirim; ?stuck=true hanya yang macet
+func GetOutboxHandler(c *gin.Context) {
+	req, ok := pageRequestFromQuery(c)
+	if !ok {
+		return
+	}
+
+	items, err := services.ListOutbox(c.Request.Context(), c.Query("stuck") == "true", req)
+	if services.IsCursorError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// RetryOutboxHandler menjadwalkan item outbox untuk segera dikirim ulang
+func RetryOutboxHandler(c *gin.Context) {
+	err := services.RetryOutboxItem(c.Request.Context(), c.Param("itemID"))
+	if errors.Is(err, services.ErrOutboxItemNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Item outbox dijadwalkan ulang"})
+}
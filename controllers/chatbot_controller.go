@@ -2,8 +2,10 @@ package controllers
 
 import (
 	"backend-go/config"
+	"backend-go/middleware"
 	"backend-go/models"
 	"backend-go/services"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -20,7 +22,7 @@ func ChatbotHandler(c *gin.Context) {
 		return
 	}
 
-	// Gunakan UUID yang diterima dari request
+	// Chat ID dibuat server melalui POST /chat dan kepemilikannya dicek ChatOwnerOnly
 	chatID := c.Param("chatID")
 	if chatID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Chat ID tidak ditemukan"})
@@ -55,15 +57,53 @@ func ChatbotHandler(c *gin.Context) {
 
 	// Proses chatbot
 	response, err := services.ProcessChatbot(chatID, req.Message, userID, username)
+	var rejected *services.ErrMessageRejected
+	if errors.As(err, &rejected) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Pesan ditolak", "reasons": rejected.Reasons})
+		return
+	}
+	if errors.Is(err, services.ErrChatNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrChatNotFound.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrChatForbidden) {
+		c.JSON(http.StatusForbidden, gin.H{"error": services.ErrChatForbidden.Error()})
+		return
+	}
 	if err != nil {
 		config.Log.Error("Kesalahan saat memproses chatbot:", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses pesan"})
 		return
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// CreateChatHandler membuat percakapan baru dengan chat ID dari server
+func CreateChatHandler(c *gin.Context) {
+	var req struct {
+		Title string `json:"title"`
+	}
+	// Body opsional, judul default dipakai jika kosong
+	_ = c.ShouldBindJSON(&req)
+
+	userID := c.MustGet("userID").(int)
+	username := c.GetString("username")
+
+	convo, err := services.CreateConversation(userID, username, req.Title)
+	if err != nil {
+		config.Log.Error("Gagal membuat percakapan:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat percakapan"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"chat_id":    convo.ChatID,
+		"chat_title": convo.ChatTitle,
+		"created_at": convo.CreatedAt,
+	})
+}
+
 func GetChatByID(c *gin.Context) {
 	chatID := c.Param("chatID")
 	userID := c.MustGet("userID").(int)
@@ -88,37 +128,55 @@ func GetUserChats(c *gin.Context) {
 		return
 	}
 
-	chats, err := services.FetchUserChatList(userID.(int))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data chat"})
+	req, ok := pageRequestFromQuery(c)
+	if !ok {
 		return
 	}
 
-	// Pastikan selalu return array, meskipun kosong
-	if chats == nil {
-		chats = []services.ChatListItem{}
+	filter, ok := chatListFilterFromQuery(c)
+	if !ok {
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": chats})
+	chats, err := services.FetchUserChatList(userID.(int), filter, req)
+	if errors.Is(err, services.ErrLabelNotFound) || services.IsCursorError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil data chat"})
+		return
+	}
+
+	c.JSON(http.StatusOK, chats)
 }
 
+// GetFullChatHistory mengembalikan riwayat gabungan teks dan suara per halaman
 func GetFullChatHistory(c *gin.Context) {
 	chatID := c.Param("chatID")
 	userID := c.MustGet("userID").(int)
 
-	messages, err := services.GetFullChatHistory(chatID, userID)
+	req, ok := pageRequestFromQuery(c)
+	if !ok {
+		return
+	}
+
+	messages, err := services.ListMessages(chatID, userID, "", req)
+	if services.IsCursorError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil riwayat obrolan lengkap"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"messages": messages})
+	c.JSON(http.StatusOK, messages)
 }
 
 func UpdateLastChatIDHandler(c *gin.Context) {
 	var req struct {
-		UserID int    `json:"user_id"`
-		ChatID string `json:"chat_id"`
+		ChatID string `json:"chat_id" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -126,7 +184,15 @@ func UpdateLastChatIDHandler(c *gin.Context) {
 		return
 	}
 
-	err := services.UpdateLastChatID(req.UserID, req.ChatID)
+	// User selalu diambil dari token, bukan dari body permintaan
+	userID := c.MustGet("userID").(int)
+
+	if err := services.CheckChatOwnership(c.Request.Context(), req.ChatID, userID); err != nil {
+		c.JSON(middleware.ChatAccessStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	err := services.UpdateLastChatID(userID, req.ChatID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal update last_chat_id"})
 		return
@@ -161,10 +227,113 @@ func DeleteChatHandler(c *gin.Context) {
 	userID := c.MustGet("userID").(int)
 
 	err := services.DeleteChat(chatID, userID)
+	if errors.Is(err, services.ErrChatNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		config.Log.Error("Gagal menghapus chat: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus obrolan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Obrolan dipindahkan ke tong sampah",
+		"retention_days": config.TrashRetentionDays,
+	})
+}
+
+// GetTrashHandler menampilkan chat di tong sampah per halaman
+func GetTrashHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+
+	req, ok := pageRequestFromQuery(c)
+	if !ok {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Obrolan berhasil dihapus"})
+	trash, err := services.ListTrash(userID, req)
+	if services.IsCursorError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil tong sampah"})
+		return
+	}
+
+	c.JSON(http.StatusOK, trash)
+}
+
+// RestoreChatHandler memulihkan chat dari tong sampah
+func RestoreChatHandler(c *gin.Context) {
+	chatID := c.Param("chatID")
+	userID := c.MustGet("userID").(int)
+
+	err := services.RestoreChat(chatID, userID)
+	if errors.Is(err, services.ErrChatNotInTrash) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memulihkan obrolan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Obrolan berhasil dipulihkan"})
+}
+
+// PurgeChatHandler menghapus permanen chat di tong sampah tanpa menunggu masa simpan
+func PurgeChatHandler(c *gin.Context) {
+	chatID := c.Param("chatID")
+	userID := c.MustGet("userID").(int)
+
+	report, err := services.PurgeChat(chatID, userID)
+	if errors.Is(err, services.ErrChatNotInTrash) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		config.Log.Error("Gagal menghapus permanen chat: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus permanen obrolan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Obrolan dihapus permanen", "report": report})
+}
+
+// EditMessageHandler menyunting pesan user (atau mengoreksi transkrip suara) lalu
+// membuat balasan bot baru; pasangan lama tetap ada di riwayat sebagai superseded
+func EditMessageHandler(c *gin.Context) {
+	var req struct {
+		Text string `json:"text" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Permintaan tidak valid"})
+		return
+	}
+
+	result, err := services.EditUserMessage(c.Param("chatID"), c.MustGet("userID").(int), c.GetString("username"),
+		c.Param("messageID"), req.Text)
+
+	var rejected *services.ErrMessageRejected
+	switch {
+	case errors.As(err, &rejected):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Pesan ditolak", "reasons": rejected.Reasons})
+	case errors.Is(err, services.ErrMessageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrChatNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrChatNotFound.Error()})
+	case errors.Is(err, services.ErrChatForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": services.ErrChatForbidden.Error()})
+	case errors.Is(err, services.ErrMessageNotEditable), errors.Is(err, services.ErrEditTextEmpty):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMessageSuperseded):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		config.Log.Error("Gagal menyunting pesan: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyunting pesan"})
+	default:
+		c.JSON(http.StatusOK, result)
+	}
 }
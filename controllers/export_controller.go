@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"backend-go/services"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportChatHandler mengekspor satu chat: ?format=pdf|html|json|csv (default pdf).
+// Chat besar dikembalikan sebagai job (202) yang dapat dipantau lewat /chat/exports/:jobID.
+func ExportChatHandler(c *gin.Context) {
+	chatID := c.Param("chatID")
+	userID := c.MustGet("userID").(int)
+	format := c.DefaultQuery("format", models.ExportFormatPDF)
+
+	file, job, err := services.ExportChat(chatID, userID, format)
+	if errors.Is(err, services.ErrUnsupportedExportFormat) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrChatNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		config.Log.Error("Gagal mengekspor chat: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengekspor chat"})
+		return
+	}
+
+	if job != nil {
+		c.JSON(http.StatusAccepted, job)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+file.FileName+`"`)
+	c.Data(http.StatusOK, file.ContentType, file.Data)
+}
+
+// CreateBulkExportHandler membuat job ekspor massal untuk admin
+func CreateBulkExportHandler(c *gin.Context) {
+	var req struct {
+		Format  string     `json:"format" binding:"required"`
+		ChatIDs []string   `json:"chat_ids"`
+		UserID  *int       `json:"user_id"`
+		From    *time.Time `json:"from"`
+		To      *time.Time `json:"to"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Permintaan tidak valid"})
+		return
+	}
+
+	job, err := services.CreateExportJob(models.ExportJob{
+		RequestedBy: c.MustGet("userID").(int),
+		Admin:       true,
+		Format:      req.Format,
+		ChatIDs:     req.ChatIDs,
+		UserID:      req.UserID,
+		From:        req.From,
+		To:          req.To,
+	})
+	if errors.Is(err, services.ErrUnsupportedExportFormat) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrExportUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		config.Log.Error("Gagal membuat job ekspor: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat job ekspor"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// ExportJobStatusHandler mengembalikan status job ekspor
+func ExportJobStatusHandler(admin bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, ok := exportJobFromRequest(c, admin)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// DownloadExportHandler mengalirkan file hasil job ekspor dari S3
+func DownloadExportHandler(admin bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		job, ok := exportJobFromRequest(c, admin)
+		if !ok {
+			return
+		}
+
+		body, contentType, err := services.OpenExportFile(c.Request.Context(), job)
+		if errors.Is(err, services.ErrExportNotReady) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "status": job.Status})
+			return
+		}
+		if errors.Is(err, services.ErrExportExpired) {
+			c.JSON(http.StatusGone, gin.H{"error": err.Error(), "status": job.Status})
+			return
+		}
+		if errors.Is(err, services.ErrExportUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			config.Log.Error("Gagal mengunduh file ekspor: ", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengunduh file ekspor"})
+			return
+		}
+		defer body.Close()
+
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", `attachment; filename="`+job.FileName+`"`)
+		c.Status(http.StatusOK)
+		io.Copy(c.Writer, body)
+	}
+}
+
+func exportJobFromRequest(c *gin.Context, admin bool) (*models.ExportJob, bool) {
+	job, err := services.GetExportJob(c.Param("jobID"), c.MustGet("userID").(int), admin)
+	if errors.Is(err, services.ErrExportJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil job ekspor"})
+		return nil, false
+	}
+	return job, true
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"backend-go/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// chatListFilterFromQuery membaca ?pinned=&starred=&archived=true|false|all&label=&sort=
+func chatListFilterFromQuery(c *gin.Context) (services.ChatListFilter, bool) {
+	filter := services.ChatListFilter{
+		LabelID: c.Query("label"),
+		Sort:    c.Query("sort"),
+	}
+	if !services.ValidConversationSort(filter.Sort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort harus updated, created, atau title"})
+		return filter, false
+	}
+
+	parse := func(name string) (*bool, bool) {
+		raw := c.Query(name)
+		if raw == "" {
+			return nil, true
+		}
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": name + " harus true atau false"})
+			return nil, false
+		}
+		return &v, true
+	}
+
+	var ok bool
+	if filter.Pinned, ok = parse("pinned"); !ok {
+		return filter, false
+	}
+	if filter.Starred, ok = parse("starred"); !ok {
+		return filter, false
+	}
+	if c.Query("archived") == "all" {
+		filter.AllArchived = true
+	} else if filter.Archived, ok = parse("archived"); !ok {
+		return filter, false
+	}
+
+	return filter, true
+}
+
+// SetChatFlagsHandler mengubah flag pinned/archived/starred sebuah chat
+func SetChatFlagsHandler(c *gin.Context) {
+	var req services.ChatFlagsUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Permintaan tidak valid"})
+		return
+	}
+
+	err := services.SetChatFlags(c.Param("chatID"), c.MustGet("userID").(int), req)
+	if errors.Is(err, services.ErrChatNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui chat"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Chat berhasil diperbarui"})
+}
+
+// SetChatLabelsHandler mengganti label sebuah chat
+func SetChatLabelsHandler(c *gin.Context) {
+	var req struct {
+		LabelIDs []string `json:"label_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Permintaan tidak valid"})
+		return
+	}
+
+	labels, err := services.SetChatLabels(c.Param("chatID"), c.MustGet("userID").(int), req.LabelIDs)
+	if errors.Is(err, services.ErrLabelNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrChatNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memperbarui label chat"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"labels": labels})
+}
+
+func GetLabelsHandler(c *gin.Context) {
+	labels, err := services.ListLabels(c.MustGet("userID").(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil label"})
+		return
+	}
+	c.JSON(http.StatusOK, labels)
+}
+
+type labelRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+func CreateLabelHandler(c *gin.Context) {
+	var req labelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Permintaan tidak valid"})
+		return
+	}
+
+	label, err := services.CreateLabel(c.MustGet("userID").(int), req.Name, req.Color)
+	if err != nil {
+		c.JSON(labelErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, label)
+}
+
+func UpdateLabelHandler(c *gin.Context) {
+	var req labelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Permintaan tidak valid"})
+		return
+	}
+
+	label, err := services.UpdateLabel(c.MustGet("userID").(int), c.Param("labelID"), req.Name, req.Color)
+	if err != nil {
+		c.JSON(labelErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, label)
+}
+
+func DeleteLabelHandler(c *gin.Context) {
+	if err := services.DeleteLabel(c.MustGet("userID").(int), c.Param("labelID")); err != nil {
+		c.JSON(labelErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Label berhasil dihapus"})
+}
+
+func labelErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrLabelNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrLabelExists):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrLabelInvalid), errors.Is(err, services.ErrLabelLimit):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
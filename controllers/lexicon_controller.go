@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"backend-go/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type lexiconRequest struct {
+	Term  string `json:"term"`
+	Alias string `json:"alias"`
+}
+
+// GetLexiconHandler mengembalikan entri leksikon TTS buatan admin
+func GetLexiconHandler(c *gin.Context) {
+	entries, err := services.ListLexicon(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil leksikon"})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+func CreateLexiconHandler(c *gin.Context) {
+	var req lexiconRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Permintaan tidak valid"})
+		return
+	}
+
+	entry, err := services.CreateLexiconEntry(c.Request.Context(), c.MustGet("userID").(int), req.Term, req.Alias)
+	if err != nil {
+		c.JSON(lexiconErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+func UpdateLexiconHandler(c *gin.Context) {
+	var req lexiconRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Permintaan tidak valid"})
+		return
+	}
+
+	entry, err := services.UpdateLexiconEntry(c.Request.Context(), c.Param("entryID"), req.Term, req.Alias)
+	if err != nil {
+		c.JSON(lexiconErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+func DeleteLexiconHandler(c *gin.Context) {
+	if err := services.DeleteLexiconEntry(c.Request.Context(), c.Param("entryID")); err != nil {
+		c.JSON(lexiconErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Entri leksikon berhasil dihapus"})
+}
+
+// PreviewSpeechHandler menampilkan SSML dan teks biasa yang akan dikirim ke TTS
+func PreviewSpeechHandler(c *gin.Context) {
+	var req struct {
+		Text string `json:"text" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "text wajib diisi"})
+		return
+	}
+
+	speech := services.PreviewSpeechText(c.Request.Context(), req.Text)
+	c.JSON(http.StatusOK, gin.H{"ssml": speech.SSML, "plain": speech.Plain})
+}
+
+// PrewarmTTSCacheHandler memulai pre-warm cache audio untuk katalog balasan di latar belakang
+func PrewarmTTSCacheHandler(c *gin.Context) {
+	if err := services.StartTTSPrewarm(); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Pre-warm cache TTS dimulai; hasilnya terlihat di /admin/metrics"})
+}
+
+func lexiconErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrLexiconNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrLexiconExists):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrLexiconInvalid):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"backend-go/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pageRequestFromQuery membaca ?before=&after=&limit= untuk endpoint berhalaman.
+// Mengirim 400 dan mengembalikan false jika parameter tidak valid.
+func pageRequestFromQuery(c *gin.Context) (services.PageRequest, bool) {
+	req := services.PageRequest{
+		Before: c.Query("before"),
+		After:  c.Query("after"),
+	}
+
+	if l := c.Query("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit tidak valid"})
+			return req, false
+		}
+		req.Limit = limit
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+
+	return req, true
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"backend-go/config"
+	"backend-go/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchMessagesHandler mencari pesan milik user yang sedang login: ?q=&limit=&after=
+func SearchMessagesHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+	searchHandler(c, &userID)
+}
+
+// AdminSearchHandler mencari pesan lintas semua user; ?user_id= membatasi ke satu user
+func AdminSearchHandler(c *gin.Context) {
+	var userID *int
+	if raw := c.Query("user_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_id tidak valid"})
+			return
+		}
+		userID = &id
+	}
+	searchHandler(c, userID)
+}
+
+func searchHandler(c *gin.Context, userID *int) {
+	req, ok := pageRequestFromQuery(c)
+	if !ok {
+		return
+	}
+
+	results, err := services.SearchMessages(services.SearchQuery{
+		Text:   c.Query("q"),
+		UserID: userID,
+		Page:   req,
+	})
+	if errors.Is(err, services.ErrEmptySearchQuery) || services.IsCursorError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		config.Log.Error("Gagal mencari pesan: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mencari pesan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"backend-go/services"
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateShareLinkHandler membuat tautan baca-saja; token hanya ditampilkan sekali
+func CreateShareLinkHandler(c *gin.Context) {
+	var req struct {
+		TTLHours int `json:"ttl_hours"`
+	}
+	// Body opsional, masa berlaku default dipakai jika kosong
+	_ = c.ShouldBindJSON(&req)
+
+	share, err := services.CreateShareLink(c.Param("chatID"), c.MustGet("userID").(int), req.TTLHours)
+	switch {
+	case errors.Is(err, services.ErrShareTTL):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "max_ttl_hours": config.ShareMaxTTLHours})
+	case errors.Is(err, services.ErrChatNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		config.Log.Error("Gagal membuat tautan berbagi: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat tautan berbagi"})
+	default:
+		c.JSON(http.StatusCreated, share)
+	}
+}
+
+// GetShareLinksHandler menampilkan semua tautan berbagi sebuah chat
+func GetShareLinksHandler(c *gin.Context) {
+	links, err := services.ListShareLinks(c.Param("chatID"), c.MustGet("userID").(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil tautan berbagi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": links})
+}
+
+// RevokeShareLinkHandler mencabut tautan berbagi
+func RevokeShareLinkHandler(c *gin.Context) {
+	err := services.RevokeShareLink(c.Param("chatID"), c.MustGet("userID").(int), c.Param("shareID"))
+	if errors.Is(err, services.ErrShareNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mencabut tautan berbagi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tautan berbagi dicabut"})
+}
+
+// GetShareAccessHandler menampilkan log akses sebuah tautan per halaman
+func GetShareAccessHandler(c *gin.Context) {
+	req, ok := pageRequestFromQuery(c)
+	if !ok {
+		return
+	}
+
+	page, err := services.ListShareAccess(c.Param("chatID"), c.MustGet("userID").(int), c.Param("shareID"), req)
+	if errors.Is(err, services.ErrShareNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if services.IsCursorError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil log akses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// SharedTranscriptHandler membuka transkrip bersama tanpa login. ?format=html untuk
+// halaman siap baca, selain itu JSON.
+func SharedTranscriptHandler(c *gin.Context) {
+	format := c.DefaultQuery("format", models.ExportFormatJSON)
+	if format != models.ExportFormatJSON && format != models.ExportFormatHTML {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format harus json atau html"})
+		return
+	}
+
+	// Halaman berbagi tidak boleh di-cache atau diindeks dan tidak membocorkan token lewat Referer
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+	c.Header("Referrer-Policy", "no-referrer")
+
+	shared, err := services.OpenSharedTranscript(c.Param("token"), c.ClientIP(), c.Request.UserAgent(), format)
+	switch {
+	case errors.Is(err, services.ErrShareNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrShareExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		config.Log.Error("Gagal membuka tautan berbagi: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuka transkrip"})
+		return
+	}
+
+	if format == models.ExportFormatJSON {
+		c.JSON(http.StatusOK, shared)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := services.RenderTranscripts(&buf, models.ExportFormatHTML, []services.ChatTranscript{shared.Transcript()}); err != nil {
+		config.Log.Error("Gagal merender transkrip bersama: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuka transkrip"})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
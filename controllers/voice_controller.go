@@ -1,14 +1,11 @@
 package controllers
 
 import (
-	"context"
+	"errors"
 	"io"
-	"log"
 	"net/http"
-	"os"
-	"os/exec"
 	"path/filepath"
-	"strings"
+	"strconv"
 	"time"
 
 	"backend-go/config"
@@ -16,196 +13,296 @@ import (
 	"backend-go/services"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// UploadVoiceHandler menerima rekaman lalu langsung mengembalikan ID job; konversi,
+// transkripsi, NLP, dan TTS berjalan di worker. Pantau lewat GET /voice/jobs/:jobID
+// atau SSE /voice/jobs/:jobID/events.
 func UploadVoiceHandler(c *gin.Context) {
-	// 1. Ambil form field chat_id dan user_id
+	// Kepemilikan chat_id sudah dicek ChatOwnerOnly
 	chatID := c.PostForm("chat_id")
-	log.Println("Menerima chatID dari form:", chatID)
 	userID := c.MustGet("userID").(int)
 
-	// 2. Ambil file audio
 	file, err := c.FormFile("audio")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "gagal mendapatkan berkas audio"})
 		return
 	}
-
-	// Tambahkan delay kecil agar write selesai (opsional)
-	time.Sleep(500 * time.Millisecond)
-	// 3. Simpan file sementara ke disk
-	inputPath := "/tmp/" + file.Filename
-	if err := c.SaveUploadedFile(file, inputPath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal menyimpan berkas audio"})
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "gagal mendapatkan berkas audio"})
 		return
 	}
+	defer src.Close()
 
-	// (Opsional) cek ukuran file
-	fileInfo, err := os.Stat(inputPath)
-	if err != nil || fileInfo.Size() < 1000 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File audio terlalu kecil atau corrupt"})
+	// Format balasan: audio_format (mp3, ogg, wav) atau header Accept
+	outputFormat, err := services.NegotiateAudioFormat(c.PostForm("audio_format"), c.GetHeader("Accept"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 4. Konversi dari webm ke mp3
-	outputPath := "/tmp/converted_" + strings.TrimSuffix(file.Filename, filepath.Ext(file.Filename)) + ".mp3"
-	outFile, err := os.Create(outputPath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuat file output"})
+	// Override suara opsional per permintaan di atas preferensi tersimpan
+	override, ok := voiceOverrideFromForm(c)
+	if !ok {
+		return
+	}
+	voice, err := services.ResolveVoiceSettings(c.Request.Context(), userID, override)
+	if errors.Is(err, services.ErrVoiceSettingsInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	defer outFile.Close()
-
-	info, err := os.Stat(inputPath)
 	if err != nil {
-		log.Printf("❌ Gagal menemukan file input: %v", err)
+		config.Log.Error("Gagal memuat pengaturan suara: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memuat pengaturan suara"})
 		return
 	}
-	log.Printf("📁 Ukuran file input: %d bytes", info.Size())
-
-	cmd := exec.Command("ffmpeg", "-y", "-i", inputPath, outputPath)
-	output, err := cmd.CombinedOutput()
 
+	job, err := services.CreateVoiceJob(c.Request.Context(), chatID, userID, outputFormat, voice, src)
+	switch {
+	case errors.Is(err, services.ErrAudioTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrAudioUnsupported):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error":     services.ErrAudioUnsupported.Error(),
+			"supported": []string{"webm", "ogg", "wav", "m4a", "aac", "amr", "mp3"},
+		})
+		return
+	case errors.Is(err, services.ErrAudioTooSmall), errors.Is(err, services.ErrAudioTooLong):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		log.Printf("⚠️ Peringatan atau kesalahan FFmpeg: %s", output)
-		// Jangan langsung return 500, cek dulu apakah file hasil ada & valid
+		config.Log.Error("Gagal membuat job suara: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "gagal menyimpan berkas audio"})
+		return
 	}
 
-	log.Printf("Menjalankan perintah ffmpeg: ffmpeg -i %s %s", inputPath, outputPath)
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id":       job.ID.Hex(),
+		"status":       job.Status,
+		"input_format": job.InputFormat,
+		"audio_format": job.OutputFormat,
+		"status_url":   "/voice/jobs/" + job.ID.Hex(),
+		"events_url":   "/voice/jobs/" + job.ID.Hex() + "/events",
+	})
+}
 
-	// Cek apakah file hasil ada dan tidak kosong
-	fi, statErr := os.Stat(outputPath)
-	if statErr != nil || fi.Size() == 0 {
-		log.Printf("❌ Berkas output tidak ditemukan atau kosong: %v", statErr)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Konversi MP3 gagal atau menghasilkan file kosong.",
-		})
-		return
+// voiceOverrideFromForm membaca tts_provider, voice_gender, voice_name, speaking_rate,
+// dan pitch dari form unggahan
+func voiceOverrideFromForm(c *gin.Context) (models.VoiceSettings, bool) {
+	override := models.VoiceSettings{
+		Provider:  c.PostForm("tts_provider"),
+		Gender:    c.PostForm("voice_gender"),
+		VoiceName: c.PostForm("voice_name"),
 	}
 
-	// Kalau sampai sini, konversi dianggap berhasil meskipun ada err
-	log.Printf("✅ Konversi MP3 berhasil, ukuran: %d bytes", fi.Size())
+	parse := func(name string, dst *float64) bool {
+		raw := c.PostForm(name)
+		if raw == "" {
+			return true
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": name + " harus berupa angka"})
+			return false
+		}
+		*dst = v
+		return true
+	}
+	if !parse("speaking_rate", &override.SpeakingRate) || !parse("pitch", &override.Pitch) {
+		return override, false
+	}
+	return override, true
+}
 
-	// 5. Baca hasil MP3 sebagai []byte
-	mp3Bytes, err := os.ReadFile(outputPath)
+// GetVoicePreferencesHandler mengembalikan preferensi suara user
+func GetVoicePreferencesHandler(c *gin.Context) {
+	prefs, err := services.GetVoicePreferences(c.Request.Context(), c.MustGet("userID").(int))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membaca file MP3"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil preferensi suara"})
 		return
 	}
 
-	// Debug step — success log
-	log.Println("Konversi MP3 berhasil, ukuran:", len(mp3Bytes))
-
-	// Log return sebagai response base64 atau hanya info berhasil
-	log.Printf("Konversi MP3 siap untuk transkripsi, chatID: %s, userID: %d", chatID, userID)
+	c.JSON(http.StatusOK, prefs)
+}
 
-	// 6. Kirim ke AWS Transcribe
-	transcript, s3Uri, err := services.TranscribeAudio(mp3Bytes)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mentranskrip audio"})
+// UpdateVoicePreferencesHandler mengganti preferensi suara user setelah divalidasi
+// terhadap daftar suara provider
+func UpdateVoicePreferencesHandler(c *gin.Context) {
+	var req models.VoiceSettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Permintaan tidak valid"})
 		return
 	}
 
-	// 7. Kirim transcript ke NLP Flask
-	nlpResp, err := services.CallNLPService(transcript)
-	log.Println("🎯 Respons dari NLP:", nlpResp.ResponseMessage)
+	prefs, err := services.SaveVoicePreferences(c.Request.Context(), c.MustGet("userID").(int), req)
+	if errors.Is(err, services.ErrVoiceSettingsInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mendapatkan respons NLP"})
+		config.Log.Error("Gagal menyimpan preferensi suara: ", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan preferensi suara"})
 		return
 	}
 
-	// 8. Kirim intent ke TTS
-	botVoiceBytes, err := services.SynthesizeSpeech(nlpResp.ResponseMessage)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengonversi teks menjadi suara"})
+	c.JSON(http.StatusOK, prefs)
+}
+
+// GetTTSVoicesHandler mengembalikan daftar suara provider (?provider=, default provider deployment)
+func GetTTSVoicesHandler(c *gin.Context) {
+	provider := c.DefaultQuery("provider", config.TTSProvider)
+	if !services.ValidTTSProvider(provider) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrUnknownTTSProvider.Error()})
 		return
 	}
 
-	// 9. Upload hasil bot voice ke S3
-	botAudioURL, err := services.UploadBotVoiceToS3("bot_"+file.Filename, botVoiceBytes)
+	voices, err := services.ListTTSVoices(c.Request.Context(), provider)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengunggah audio bot ke S3"})
+		config.Log.Error("Gagal mengambil daftar suara: ", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Gagal mengambil daftar suara"})
 		return
 	}
+	if voices == nil {
+		voices = []services.TTSVoice{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"provider": provider, "voices": voices})
+}
 
-	// 10. Simpan ke MongoDB voice_messages
-	err = services.SaveVoiceChatHistory(chatID, userID, transcript, nlpResp.Intent, s3Uri, botAudioURL, nlpResp.ResponseMessage)
+// GetVoiceJobHandler mengembalikan status job beserta hasilnya jika sudah selesai
+func GetVoiceJobHandler(c *gin.Context) {
+	job, err := services.GetVoiceJob(c.Request.Context(), c.Param("jobID"), c.MustGet("userID").(int))
+	if errors.Is(err, services.ErrVoiceJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan pesan suara"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil status job suara"})
 		return
 	}
 
-	// 11. Bersihkan file sementara
-	os.Remove(inputPath)
-	os.Remove(outputPath)
-
-	// 12. Balas ke frontend
-	c.JSON(http.StatusOK, gin.H{
-		"transcript":    transcript,
-		"intent":        nlpResp.Intent,
-		"bot_audio_url": botAudioURL,
-	})
+	c.JSON(http.StatusOK, job)
 }
 
-func GetVoiceMessages(chatID string) ([]models.VoiceMessage, error) {
-	var messages []models.VoiceMessage
-	collection := config.MongoDB.Collection("voice_messages")
+// VoiceJobEventsHandler mengirim pembaruan job lewat Server-Sent Events. Nama event sama
+// dengan status job (pending, running, done, failed); stream ditutup saat job selesai.
+func VoiceJobEventsHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
 
-	filter := bson.M{"chat_id": chatID}
-	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}})
-	cursor, err := collection.Find(context.TODO(), filter, opts)
+	job, err := services.GetVoiceJob(c.Request.Context(), c.Param("jobID"), userID)
+	if errors.Is(err, services.ErrVoiceJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		return nil, err
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil status job suara"})
+		return
+	}
+
+	updates, unsubscribe := services.SubscribeVoiceJob(job.ID)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	send := func(j *models.VoiceJob) bool {
+		c.SSEvent(j.Status, j)
+		c.Writer.Flush()
+		return j.Terminal()
+	}
+	if send(job) {
+		return
 	}
-	defer cursor.Close(context.TODO())
 
-	for cursor.Next(context.TODO()) {
-		var msg models.VoiceMessage
-		if err := cursor.Decode(&msg); err != nil {
-			return nil, err
+	// Job bisa diproses instance lain, jadi status juga dicek berkala dari MongoDB
+	poll := time.NewTicker(2 * time.Second)
+	defer poll.Stop()
+	last := job.UpdatedAt
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case j := <-updates:
+			last = j.UpdatedAt
+			if send(&j) {
+				return
+			}
+		case <-poll.C:
+			j, err := services.GetVoiceJob(c.Request.Context(), job.ID.Hex(), userID)
+			if err != nil {
+				return
+			}
+			if j.UpdatedAt.Equal(last) {
+				continue
+			}
+			last = j.UpdatedAt
+			if send(j) {
+				return
+			}
 		}
-		messages = append(messages, msg)
 	}
-	return messages, nil
 }
 
+// RetryVoiceJobHandler mengulang job yang gagal mulai dari tahap yang gagal
+func RetryVoiceJobHandler(c *gin.Context) {
+	job, err := services.RetryVoiceJob(c.Param("jobID"), c.MustGet("userID").(int))
+	switch {
+	case errors.Is(err, services.ErrVoiceJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrVoiceJobNotRetryable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengulang job suara"})
+	default:
+		c.JSON(http.StatusAccepted, job)
+	}
+}
+
+// GetVoiceMessagesByID mengembalikan pesan bermodality voice per halaman
 func GetVoiceMessagesByID(c *gin.Context) {
 	chatID := c.Param("chatID")
-	ctx := context.TODO()
+	userID := c.MustGet("userID").(int)
 
-	filter := bson.M{"chat_id": chatID}
-	cursor, err := config.MongoDB.Collection("voice_messages").Find(ctx, filter)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil voice messages"})
+	req, ok := pageRequestFromQuery(c)
+	if !ok {
 		return
 	}
-	defer cursor.Close(ctx)
 
-	var messages []models.VoiceMessage
-	if err := cursor.All(ctx, &messages); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses data voice messages"})
+	messages, err := services.ListMessages(chatID, userID, models.ModalityVoice, req)
+	if services.IsCursorError(err) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil voice messages"})
 		return
 	}
 
 	c.JSON(http.StatusOK, messages)
 }
 
+// ServeAudioFile menyajikan audio lokal yang dirujuk pesan milik pengguna
 func ServeAudioFile(c *gin.Context) {
-	filename := c.Param("filename")
-	filePath := filepath.Join("uploads", filename)
+	filename := filepath.Base(c.Param("filename"))
 
-	// Buka file audio
-	file, err := os.Open(filePath)
-	if err != nil {
+	file, err := services.OpenLocalAudio(filename, c.MustGet("userID").(int))
+	if errors.Is(err, services.ErrAudioNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "File audio tidak ditemukan"})
 		return
 	}
+	if err != nil {
+		config.Log.Error("Gagal membuka file audio:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membuka file audio"})
+		return
+	}
 	defer file.Close()
 
 	// Set header konten audio
-	c.Header("Content-Type", "audio/mpeg") // sesuaikan tipe jika format lain
+	c.Header("Content-Type", services.AudioContentType(filename))
 	c.Header("Content-Disposition", "inline; filename="+filename)
 	c.Status(http.StatusOK)
 	io.Copy(c.Writer, file)
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"backend-go/config"
+	"backend-go/middleware"
+	"backend-go/models"
+	"backend-go/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	voiceStreamWriteWait  = 10 * time.Second
+	voiceStreamPongWait   = 60 * time.Second
+	voiceStreamPingPeriod = voiceStreamPongWait * 9 / 10
+)
+
+// voiceStreamUpgrader hanya menerima origin frontend yang terdaftar di VOICE_STREAM_ORIGINS.
+// Token dikirim lewat subprotocol "bearer.<jwt>" dan server selalu menjawab "voice.v1"
+// sehingga token tidak dipantulkan kembali.
+var voiceStreamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  16 << 10,
+	WriteBufferSize: 16 << 10,
+	Subprotocols:    []string{"voice.v1"},
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || slices.Contains(config.VoiceStreamOrigins, origin)
+	},
+}
+
+// wsVoiceSink menulis event JSON dan audio biner ke satu koneksi; gorilla hanya
+// mengizinkan satu penulis sekaligus
+type wsVoiceSink struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (s *wsVoiceSink) write(messageType int, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(voiceStreamWriteWait))
+	return s.conn.WriteMessage(messageType, data)
+}
+
+func (s *wsVoiceSink) SendEvent(event services.VoiceStreamEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.write(websocket.TextMessage, data)
+}
+
+func (s *wsVoiceSink) SendAudio(chunk []byte) error {
+	return s.write(websocket.BinaryMessage, chunk)
+}
+
+// VoiceStreamHandler membuka percakapan suara dua arah. Frame teks dari klien adalah
+// pesan kontrol JSON (start, stop, barge_in), frame biner adalah potongan audio.
+// Server membalas event JSON dan potongan audio balasan sebagai frame biner.
+func VoiceStreamHandler(c *gin.Context) {
+	userID := c.MustGet("userID").(int)
+	chatID := c.Param("chatID")
+
+	// Format balasan dipilih saat membuka koneksi: ?audio_format=mp3|ogg|wav
+	format, err := services.NegotiateAudioFormat(c.Query("audio_format"), "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	voice, err := services.ResolveVoiceSettings(c.Request.Context(), userID, models.VoiceSettings{})
+	if err != nil {
+		config.Log.Warn("Gagal mengambil preferensi suara, memakai default: ", err)
+	}
+
+	conn, err := voiceStreamUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrader sudah menulis respons error
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(int64(config.VoiceStreamChunkBytes) * 4)
+
+	sink := &wsVoiceSink{conn: conn}
+	session := services.NewVoiceStreamSession(c.Request.Context(), chatID, userID, voice, format, sink, middleware.VoiceTurnLimit(c))
+	defer session.Close()
+
+	// Ping berkala agar koneksi mati terdeteksi walaupun klien diam
+	conn.SetReadDeadline(time.Now().Add(voiceStreamPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(voiceStreamPongWait))
+	})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(voiceStreamPingPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// WriteControl aman dipanggil bersamaan dengan penulis lain
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(voiceStreamWriteWait)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				config.Log.Warn("Koneksi suara streaming terputus: ", err)
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(voiceStreamPongWait))
+
+		switch messageType {
+		case websocket.BinaryMessage:
+			err = session.HandleAudio(data)
+		case websocket.TextMessage:
+			var ctl services.VoiceStreamControl
+			if err = json.Unmarshal(data, &ctl); err != nil {
+				err = services.ErrVoiceStreamControl
+				break
+			}
+			err = session.HandleControl(ctl)
+		}
+		if err != nil {
+			// Kesalahan klien dilaporkan tanpa menutup sesi
+			if !isVoiceStreamClientError(err) {
+				config.Log.Error("Gagal memproses suara streaming: ", err)
+			}
+			sink.SendEvent(services.VoiceStreamEvent{Type: services.VoiceEventError, Error: err.Error()})
+		}
+	}
+}
+
+func isVoiceStreamClientError(err error) bool {
+	return errors.Is(err, services.ErrVoiceStreamBusy) ||
+		errors.Is(err, services.ErrVoiceStreamIdle) ||
+		errors.Is(err, services.ErrVoiceStreamControl) ||
+		errors.Is(err, services.ErrVoiceStreamLimited) ||
+		errors.Is(err, services.ErrVoiceStreamFormat)
+}
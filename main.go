@@ -4,6 +4,7 @@ import (
 	"backend-go/config"
 	"backend-go/routes"
 	"backend-go/services"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -22,22 +23,73 @@ func main() {
 		log.Fatal("Gagal memuat konfigurasi:", err)
 	}
 
-	if err := config.LoadAWSConfig(); err != nil {
-		log.Fatal("Gagal menginisialisasi AWS:", err)
+	if config.AWSEnabled() {
+		if err := config.LoadAWSConfig(); err != nil {
+			log.Fatal("Gagal menginisialisasi AWS:", err)
+		}
+	} else {
+		log.Println("⚠️ AWS tidak dikonfigurasi: audio disimpan di", config.AudioLocalDir, "dan provider aws/polly tidak tersedia")
 	}
 
 	if err := config.InitDB(); err != nil {
 		log.Fatal("Gagal menginisialisasi database (PostgreSQL):", err)
 	}
+	if err := config.EnsurePostgresSchema(); err != nil {
+		log.Fatal("Gagal memperbarui skema PostgreSQL:", err)
+	}
 
 	if err := config.InitMongoDB(); err != nil {
 		log.Fatal("Gagal menginisialisasi database (MongoDB):", err)
 	}
 
+	// Tanpa chat_id_unique server tidak boleh menerima tulisan; bila gagal karena data lama
+	// dengan percakapan ganda, jalankan cmd/migrate -step=dedupe-conversations lalu restart.
+	// Indeks lain yang gagal hanya dicatat.
+	if err := config.EnsureMongoIndexes(); errors.Is(err, config.ErrRequiredMongoIndex) {
+		log.Fatal("Indeks MongoDB wajib gagal dibuat:", err)
+	} else if err != nil {
+		log.Println("⚠️ Sebagian indeks MongoDB belum terpasang:", err)
+	}
+
+	if err := config.InitRedis(); err != nil {
+		log.Fatal("Gagal menginisialisasi Redis:", err)
+	}
+
 	services.InitVoiceServices()
 
 	config.InitLogger()
 
+	if err := services.InitSearch(); err != nil {
+		log.Fatal("Gagal menginisialisasi pencarian:", err)
+	}
+
+	if err := services.InitSTT(); err != nil {
+		log.Fatal("Gagal menginisialisasi speech-to-text:", err)
+	}
+
+	if err := services.InitTranscoder(); err != nil {
+		log.Fatal("ffmpeg/ffprobe tidak tersedia:", err)
+	}
+
+	if err := services.InitStreamingSTT(); err != nil {
+		log.Fatal("Gagal menginisialisasi speech-to-text streaming:", err)
+	}
+
+	if err := services.InitTTS(); err != nil {
+		log.Fatal("Gagal menginisialisasi text-to-speech:", err)
+	}
+
+	services.StartExportWorkers(config.ExportWorkers)
+	services.StartTrashPurger(config.TrashPurgeInterval)
+	services.StartOutboxDispatcher(config.OutboxInterval)
+	services.StartVoiceWorkers(config.VoiceWorkers)
+
+	if config.TTSPrewarmOnStart {
+		if err := services.StartTTSPrewarm(); err != nil {
+			log.Println("Pre-warm cache TTS dilewati:", err)
+		}
+	}
+
 	if config.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -53,8 +105,9 @@ func main() {
 
 	corsConfig := cors.Config{
 		AllowOrigins:     []string{"http://localhost:8501", "http://127.0.0.1:8501"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Idempotent-Replayed", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}
@@ -16,6 +16,9 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Ambil Authorization header
 		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			authHeader = websocketBearer(c)
+		}
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header missing"})
 			c.Abort()
@@ -58,3 +61,17 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// websocketBearer membaca token dari subprotocol "bearer.<token>" karena WebSocket di
+// browser tidak bisa mengirim header Authorization
+func websocketBearer(c *gin.Context) string {
+	if !strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		return ""
+	}
+	for _, proto := range strings.Split(c.GetHeader("Sec-WebSocket-Protocol"), ",") {
+		if token, ok := strings.CutPrefix(strings.TrimSpace(proto), "bearer."); ok && token != "" {
+			return "Bearer " + token
+		}
+	}
+	return ""
+}
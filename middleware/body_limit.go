@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead adalah kelonggaran untuk boundary dan field form selain berkas
+const multipartOverhead = 64 << 10
+
+// MaxBodyBytes membatasi ukuran body permintaan sebelum middleware lain (mis.
+// ChatOwnerOnly yang membaca field form) mem-parsing body. Permintaan dengan
+// Content-Length di atas batas langsung ditolak; body tanpa Content-Length dipotong
+// oleh http.MaxBytesReader saat dibaca.
+func MaxBodyBytes(limit int64) gin.HandlerFunc {
+	limit += multipartOverhead
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Ukuran permintaan melebihi batas"})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// isBodyTooLarge mengenali error baca body dari MaxBodyBytes
+func isBodyTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
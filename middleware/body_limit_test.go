@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaxBodyBytesRejectsBeforeOwnershipCheck(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/upload", func(c *gin.Context) { c.Set("userID", 1) }, MaxBodyBytes(1024), ChatOwnerOnly(), func(c *gin.Context) {
+		t.Error("handler tidak boleh dijalankan untuk body yang terlalu besar")
+	})
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("chat_id", "chat-a")
+	part, _ := w.CreateFormFile("audio", "rekaman.webm")
+	part.Write(make([]byte, 2*multipartOverhead))
+	w.Close()
+
+	for _, chunked := range []bool{false, true} {
+		req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(body.Bytes()))
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		if chunked {
+			// Tanpa Content-Length batas ditegakkan saat body dibaca
+			req.ContentLength = -1
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("chunked=%v: status = %d, ingin 413", chunked, rec.Code)
+		}
+	}
+}
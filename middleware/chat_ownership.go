@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"backend-go/services"
+)
+
+// ChatOwnerOnly memastikan chat pada parameter :chatID (atau field form chat_id)
+// milik user yang sedang login. Harus dipasang setelah JWTAuthMiddleware.
+func ChatOwnerOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		chatID := c.Param("chatID")
+		if chatID == "" {
+			// Body multipart di-parse di sini; batas ukurannya dipasang MaxBodyBytes
+			if _, err := c.MultipartForm(); isBodyTooLarge(err) {
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Ukuran permintaan melebihi batas"})
+				return
+			}
+			chatID = c.PostForm("chat_id")
+		}
+
+		userID, ok := c.Get("userID")
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User ID tidak ditemukan dalam konteks"})
+			return
+		}
+
+		err := services.CheckChatOwnership(c.Request.Context(), chatID, userID.(int))
+		if err != nil {
+			c.AbortWithStatusJSON(ChatAccessStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Set("chatID", chatID)
+		c.Next()
+	}
+}
+
+// ChatAccessStatus memetakan error kepemilikan chat ke status HTTP
+func ChatAccessStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrChatNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrChatForbidden):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"backend-go/config"
+	"backend-go/services"
+)
+
+const maxIdempotencyKeyLen = 255
+
+// idempotencyWriter menyalin body respons agar dapat disimpan setelah handler selesai
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency memutar ulang respons untuk permintaan dengan header Idempotency-Key yang
+// sama (per user dan per scope), sehingga retry klien tidak menyimpan pesan ganda atau
+// menjalankan ulang Transcribe/TTS. Tanpa header, permintaan diproses seperti biasa.
+// Harus dipasang setelah JWTAuthMiddleware dan ChatOwnerOnly.
+func Idempotency(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key terlalu panjang"})
+			return
+		}
+
+		userID := c.MustGet("userID").(int)
+		hash, err := idempotencyRequestHash(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Permintaan tidak valid"})
+			return
+		}
+
+		id := fmt.Sprintf("%s:%d:%s", scope, userID, key)
+		ctx, cancel := context.WithTimeout(c.Request.Context(), config.IdempotencyWait)
+		record, err := services.BeginIdempotentRequest(ctx, id, userID, c.GetString("chatID"), hash)
+		cancel()
+		switch {
+		case errors.Is(err, services.ErrIdempotencyKeyReused):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrIdempotencyInFlight):
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			// Penyimpanan bermasalah: lebih aman menolak daripada memproses ganda
+			config.Log.Error("Gagal memeriksa Idempotency-Key: ", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Layanan sementara tidak tersedia"})
+			return
+		case record != nil:
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.StatusCode, record.ContentType, record.Body)
+			c.Abort()
+			return
+		}
+
+		writer := &idempotencyWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		completed := false
+		defer func() {
+			if !completed {
+				services.ReleaseIdempotentRequest(id)
+			}
+		}()
+
+		c.Next()
+
+		// Error server tidak disimpan agar retry berikutnya diproses ulang
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		if err := services.CompleteIdempotentRequest(id, status, writer.Header().Get("Content-Type"), writer.body.Bytes()); err != nil {
+			config.Log.Warn("Gagal menyimpan respons Idempotency-Key: ", err)
+			return
+		}
+		completed = true
+	}
+}
+
+// idempotencyRequestHash menghitung sidik permintaan. Form multipart di-hash per field
+// dan isi file, bukan byte mentah, karena boundary dapat berbeda di setiap retry.
+func idempotencyRequestHash(c *gin.Context) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return "", err
+		}
+
+		names := make([]string, 0, len(form.Value))
+		for name := range form.Value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(h, "v:%s=%q\n", name, form.Value[name])
+		}
+
+		names = names[:0]
+		for name := range form.File {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			for _, fh := range form.File[name] {
+				f, err := fh.Open()
+				if err != nil {
+					return "", err
+				}
+				fmt.Fprintf(h, "f:%s:%d\n", name, fh.Size)
+				_, err = io.Copy(h, f)
+				f.Close()
+				if err != nil {
+					return "", err
+				}
+			}
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	if c.Request.Body != nil {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return "", err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
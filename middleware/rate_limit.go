@@ -0,0 +1,256 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"backend-go/config"
+	"backend-go/services"
+)
+
+// RateLimitRule adalah aturan token bucket: Burst token, diisi ulang Rate token per detik
+type RateLimitRule struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitStore menyimpan state token bucket per kunci
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, rule RateLimitRule) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// ParseRateLimitRule mengubah string "30/m" menjadi aturan (burst = jumlah per periode)
+func ParseRateLimitRule(spec string) (RateLimitRule, error) {
+	parts := strings.SplitN(strings.TrimSpace(spec), "/", 2)
+	if len(parts) != 2 {
+		return RateLimitRule{}, fmt.Errorf("format rate limit tidak valid: %q", spec)
+	}
+
+	count, err := strconv.Atoi(parts[0])
+	if err != nil || count <= 0 {
+		return RateLimitRule{}, fmt.Errorf("jumlah rate limit tidak valid: %q", spec)
+	}
+
+	var period time.Duration
+	switch parts[1] {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		return RateLimitRule{}, fmt.Errorf("periode rate limit tidak valid: %q", spec)
+	}
+
+	return RateLimitRule{Rate: float64(count) / period.Seconds(), Burst: count}, nil
+}
+
+// ==== Penyimpanan in-memory ====
+
+type tokenBucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	s := &memoryRateLimitStore{buckets: make(map[string]*tokenBucket)}
+	go s.evictIdle(10 * time.Minute)
+	return s
+}
+
+func (s *memoryRateLimitStore) Take(_ context.Context, key string, rule RateLimitRule) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(rule.Burst), lastSeen: now}
+		s.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.lastSeen).Seconds()
+	b.tokens = math.Min(float64(rule.Burst), b.tokens+elapsed*rule.Rate)
+	b.lastSeen = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+
+	wait := time.Duration((1 - b.tokens) / rule.Rate * float64(time.Second))
+	return false, wait, nil
+}
+
+// evictIdle membuang bucket yang sudah lama tidak dipakai agar map tidak terus membesar
+func (s *memoryRateLimitStore) evictIdle(idle time.Duration) {
+	ticker := time.NewTicker(idle)
+	defer ticker.Stop()
+	for range ticker.C {
+		cutoff := time.Now().Add(-idle)
+		s.mu.Lock()
+		for k, b := range s.buckets {
+			if b.lastSeen.Before(cutoff) {
+				delete(s.buckets, k)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// ==== Penyimpanan Redis (dibagi antar instance) ====
+
+// tokenBucketScript mengeksekusi token bucket secara atomik di sisi Redis
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call("HSET", KEYS[1], "tokens", tokens, "ts", now)
+redis.call("EXPIRE", KEYS[1], math.ceil(burst / rate) + 1)
+return {allowed, tostring(tokens)}
+`)
+
+type redisRateLimitStore struct {
+	client *redis.Client
+}
+
+func (s *redisRateLimitStore) Take(ctx context.Context, key string, rule RateLimitRule) (bool, time.Duration, error) {
+	now := float64(time.Now().UnixNano()) / float64(time.Second)
+	res, err := tokenBucketScript.Run(ctx, s.client, []string{"ratelimit:" + key}, rule.Rate, rule.Burst, now).Slice()
+	if err != nil {
+		return false, 0, err
+	}
+
+	allowed, _ := res[0].(int64)
+	if allowed == 1 {
+		return true, 0, nil
+	}
+
+	tokens, _ := strconv.ParseFloat(fmt.Sprint(res[1]), 64)
+	wait := time.Duration((1 - tokens) / rule.Rate * float64(time.Second))
+	return false, wait, nil
+}
+
+// ==== Middleware ====
+
+var (
+	rateLimitStore     RateLimitStore
+	fallbackLimitStore RateLimitStore
+	rateLimitStoreOnce sync.Once
+)
+
+func getRateLimitStores() (RateLimitStore, RateLimitStore) {
+	rateLimitStoreOnce.Do(func() {
+		fallbackLimitStore = newMemoryRateLimitStore()
+		rateLimitStore = fallbackLimitStore
+		if config.RedisClient != nil {
+			rateLimitStore = &redisRateLimitStore{client: config.RedisClient}
+		}
+	})
+	return rateLimitStore, fallbackLimitStore
+}
+
+// rateLimitSpec mengembalikan aturan grup dari konfigurasi
+func rateLimitSpec(group string) string {
+	switch group {
+	case "chat":
+		return config.RateLimitChat
+	case "voice":
+		return config.RateLimitVoice
+	case "login":
+		return config.RateLimitLogin
+	case "share":
+		return config.RateLimitShare
+	}
+	return ""
+}
+
+// rateLimitKeys mengunci per IP dan, bila sudah login, per user ID
+func rateLimitKeys(c *gin.Context, group string) []string {
+	keys := []string{group + ":ip:" + c.ClientIP()}
+	if userID, ok := c.Get("userID"); ok {
+		keys = append(keys, fmt.Sprintf("%s:user:%v", group, userID))
+	}
+	return keys
+}
+
+// takeRateLimit mengambil satu token untuk setiap kunci dan berhenti di kunci pertama
+// yang sudah habis
+func takeRateLimit(ctx context.Context, keys []string, rule RateLimitRule) (bool, time.Duration) {
+	store, fallback := getRateLimitStores()
+	for _, key := range keys {
+		allowed, retryAfter, err := store.Take(ctx, key, rule)
+		if err != nil {
+			// Redis bermasalah: tetap batasi per instance daripada membuka akses penuh
+			config.Log.Warn("Rate limit store error, memakai in-memory: ", err)
+			allowed, retryAfter, _ = fallback.Take(ctx, key, rule)
+		}
+		if !allowed {
+			return false, retryAfter
+		}
+	}
+	return true, 0
+}
+
+// RateLimit membatasi permintaan per grup rute ("chat", "voice", "login") dengan
+// token bucket yang dikunci per IP dan, bila sudah login, per user ID.
+func RateLimit(group string) gin.HandlerFunc {
+	rule, err := ParseRateLimitRule(rateLimitSpec(group))
+	if err != nil {
+		config.Log.Warn("Rate limit untuk grup ", group, " dinonaktifkan: ", err)
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		allowed, retryAfter := takeRateLimit(c.Request.Context(), rateLimitKeys(c, group), rule)
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.Header("X-RateLimit-Limit", strconv.Itoa(rule.Burst))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Terlalu banyak permintaan, silakan coba lagi nanti"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// VoiceTurnLimit mengembalikan pembatas per ucapan untuk sesi suara streaming. Satu
+// koneksi WebSocket bisa memuat banyak giliran, jadi setiap giliran mengambil token
+// dari bucket grup "voice" yang sama dengan unggahan suara. Nil bila rate limit mati.
+func VoiceTurnLimit(c *gin.Context) func(ctx context.Context) error {
+	rule, err := ParseRateLimitRule(rateLimitSpec("voice"))
+	if err != nil {
+		return nil
+	}
+	keys := rateLimitKeys(c, "voice")
+	return func(ctx context.Context) error {
+		if allowed, _ := takeRateLimit(ctx, keys, rule); !allowed {
+			return services.ErrVoiceStreamLimited
+		}
+		return nil
+	}
+}
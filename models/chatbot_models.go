@@ -21,23 +21,92 @@ type ChatbotResponse struct {
 
 // ==== Bagian: MongoDB Conversation ====
 
+// Modality pesan dalam satu riwayat chat
+const (
+	ModalityText   = "text"
+	ModalityVoice  = "voice"
+	ModalityRich   = "rich"
+	ModalityAgent  = "agent"
+	ModalitySystem = "system"
+)
+
+// AudioAttachment menyimpan informasi audio untuk pesan suara
+type AudioAttachment struct {
+	URL        string `bson:"url" json:"url"`
+	Format     string `bson:"format,omitempty" json:"format,omitempty"`
+	DurationMs int64  `bson:"duration_ms,omitempty" json:"duration_ms,omitempty"`
+	SpeechMs   int64  `bson:"speech_ms,omitempty" json:"speech_ms,omitempty"` // Durasi ucapan hasil deteksi aktivitas suara
+}
+
+// Message adalah skema tunggal untuk semua giliran (teks, suara, dll.) dan disimpan
+// sebagai dokumen tersendiri di collection messages
 type Message struct {
-	Sender     string  `bson:"sender" json:"sender"`
-	Message    string  `bson:"message" json:"message"`
-	Intent     string  `bson:"intent,omitempty" json:"intent,omitempty"`
-	Confidence float64 `bson:"confidence,omitempty" json:"confidence,omitempty"`
-	Timestamp  string  `bson:"timestamp" json:"timestamp"`
+	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ChatID            string             `bson:"chat_id" json:"chat_id"`
+	UserID            int                `bson:"user_id" json:"user_id"`
+	Seq               int64              `bson:"seq" json:"seq"`
+	Modality          string             `bson:"modality" json:"modality"`
+	Sender            string             `bson:"sender" json:"sender"` // "user" atau "bot"
+	Content           string             `bson:"content,omitempty" json:"content,omitempty"`
+	Audio             *AudioAttachment   `bson:"audio,omitempty" json:"audio,omitempty"`
+	Transcript        string             `bson:"transcript,omitempty" json:"transcript,omitempty"`
+	Intent            string             `bson:"intent,omitempty" json:"intent,omitempty"`
+	Confidence        float64            `bson:"confidence,omitempty" json:"confidence,omitempty"`
+	Timestamp         time.Time          `bson:"timestamp" json:"timestamp"`
+	PII               *PIIReport         `bson:"pii,omitempty" json:"pii,omitempty"`
+	EncryptedOriginal string             `bson:"encrypted_original,omitempty" json:"-"`
+	Flags             []string           `bson:"flags,omitempty" json:"flags,omitempty"`
+	SearchText        string             `bson:"search_text,omitempty" json:"-"` // Stem bahasa Indonesia untuk indeks teks
+	DeletedAt         *time.Time         `bson:"deleted_at,omitempty" json:"-"`  // Ikut ditandai saat chat masuk tong sampah
+
+	// Suntingan: balasan bot menunjuk pesan user yang dijawabnya; pesan user hasil suntingan
+	// menunjuk pesan aslinya, dan pasangan lama ditandai superseded_by pesan penggantinya
+	ReplyTo      *primitive.ObjectID `bson:"reply_to,omitempty" json:"reply_to,omitempty"`
+	Revises      *primitive.ObjectID `bson:"revises,omitempty" json:"revises,omitempty"`
+	SupersededBy *primitive.ObjectID `bson:"superseded_by,omitempty" json:"superseded_by,omitempty"`
+}
+
+// Text mengembalikan isi pesan yang dapat dibaca: konten teks atau transkrip suara
+func (m Message) Text() string {
+	if m.Content != "" {
+		return m.Content
+	}
+	return m.Transcript
 }
 
 type Conversation struct {
+	ID           primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	ChatID       string               `bson:"chat_id" json:"chat_id"`
+	UserID       int                  `bson:"user_id" json:"user_id"`
+	Username     string               `bson:"username" json:"username"`
+	ChatTitle    string               `bson:"chat_title" json:"chat_title"`
+	LastMessage  *MessagePreview      `bson:"last_message,omitempty" json:"last_message,omitempty"`
+	MessageCount int64                `bson:"message_count" json:"message_count"` // Penghitung urutan pesan teks & suara
+	Pinned       bool                 `bson:"pinned" json:"pinned"`
+	Archived     bool                 `bson:"archived" json:"archived"`
+	Starred      bool                 `bson:"starred" json:"starred"`
+	Labels       []primitive.ObjectID `bson:"labels,omitempty" json:"labels,omitempty"`
+	CreatedAt    time.Time            `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time            `bson:"updated_at" json:"updated_at"`
+	DeletedAt    *time.Time           `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"` // Soft delete; dihapus permanen oleh purger
+	Outbox       []OutboxItem         `bson:"outbox,omitempty" json:"-"`                        // Efek samping yang belum terkirim
+}
+
+// MessagePreview adalah ringkasan pesan terakhir yang disimpan di dokumen percakapan
+type MessagePreview struct {
+	Seq       int64     `bson:"seq" json:"seq"`
+	Sender    string    `bson:"sender" json:"sender"`
+	Text      string    `bson:"text" json:"text"`
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+}
+
+// Label adalah label buatan user untuk mengelompokkan percakapan
+type Label struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	ChatID    string             `bson:"chat_id" json:"chat_id"`
-	UserID    int                `bson:"user_id" json:"user_id"`
-	Username  string             `bson:"username" json:"username"`
-	ChatTitle string             `bson:"chat_title" json:"chat_title"`
-	Messages  []Message          `bson:"messages" json:"messages"`
+	UserID    int                `bson:"user_id" json:"-"`
+	Name      string             `bson:"name" json:"name"`
+	Color     string             `bson:"color" json:"color"` // Format #rrggbb
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
 }
 
 type IntentSummary struct {
@@ -48,23 +117,18 @@ type IntentSummary struct {
 	LastUsed time.Time          `bson:"last_used" json:"last_used"`
 }
 
-type VoiceMessage struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty"`
-	ChatID     string             `bson:"chat_id"`
-	UserID     int                `bson:"user_id" json:"user_id"`
-	Sender     string             `bson:"sender"`
-	AudioURL   string             `bson:"audio_url"`
-	Transcript string             `bson:"transcript"`
-	Intent     string             `bson:"intent"`
-	Timestamp  time.Time          `bson:"timestamp"`
+// ==== Bagian: Laporan PII ====
+
+// PIIFinding mencatat jenis data pribadi yang ditemukan beserta jumlahnya
+type PIIFinding struct {
+	Type  string `bson:"type" json:"type"`
+	Count int    `bson:"count" json:"count"`
 }
 
-type ChatMessageResponse struct {
-	Type       string    `json:"type"`
-	Sender     string    `json:"sender"`
-	Content    string    `json:"content,omitempty"`
-	AudioURL   string    `json:"audio_url,omitempty"`
-	Transcript string    `json:"transcript,omitempty"`
-	Intent     string    `json:"intent,omitempty"`
-	Timestamp  time.Time `json:"timestamp"`
+// PIIReport adalah laporan kepatuhan per pesan (tanpa menyimpan nilai aslinya)
+type PIIReport struct {
+	Findings       []PIIFinding `bson:"findings" json:"findings"`
+	Total          int          `bson:"total" json:"total"`
+	OriginalStored bool         `bson:"original_stored" json:"original_stored"`
+	ScannedAt      time.Time    `bson:"scanned_at" json:"scanned_at"`
 }
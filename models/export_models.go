@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ==== Bagian: Ekspor Transkrip ====
+
+// Format ekspor transkrip yang didukung
+const (
+	ExportFormatPDF  = "pdf"
+	ExportFormatHTML = "html"
+	ExportFormatJSON = "json"
+	ExportFormatCSV  = "csv"
+)
+
+// Status job ekspor di latar belakang
+const (
+	ExportStatusPending = "pending"
+	ExportStatusRunning = "running"
+	ExportStatusDone    = "done"
+	ExportStatusFailed  = "failed"
+	ExportStatusExpired = "expired" // file sudah dihapus dari S3 setelah EXPORT_RETENTION
+)
+
+// ExportJob adalah permintaan ekspor yang diproses worker; hasilnya disimpan di S3
+type ExportJob struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	RequestedBy int                `bson:"requested_by" json:"requested_by"`
+	Admin       bool               `bson:"admin" json:"admin"`
+	Format      string             `bson:"format" json:"format"`
+	ChatIDs     []string           `bson:"chat_ids,omitempty" json:"chat_ids,omitempty"`
+	UserID      *int               `bson:"user_id,omitempty" json:"user_id,omitempty"` // Filter ekspor massal
+	From        *time.Time         `bson:"from,omitempty" json:"from,omitempty"`
+	To          *time.Time         `bson:"to,omitempty" json:"to,omitempty"`
+	Status      string             `bson:"status" json:"status"`
+	Chats       int                `bson:"chats" json:"chats"`
+	FileKey     string             `bson:"file_key,omitempty" json:"-"`
+	FileName    string             `bson:"file_name,omitempty" json:"file_name,omitempty"`
+	Error       string             `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	StartedAt   *time.Time         `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	CompletedAt *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+
+	// Klaim worker: job running hanya diambil alih setelah locked_until lewat
+	Owner       string     `bson:"owner,omitempty" json:"-"`
+	LockedUntil *time.Time `bson:"locked_until,omitempty" json:"-"`
+}
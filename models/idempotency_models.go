@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// ==== Bagian: Idempotency-Key ====
+
+const (
+	IdempotencyStatusProcessing = "processing"
+	IdempotencyStatusDone       = "done"
+)
+
+// IdempotencyRecord menyimpan hasil permintaan ber-Idempotency-Key agar retry klien
+// mendapat respons yang sama tanpa memproses ulang. Dokumen dihapus otomatis oleh
+// TTL index pada expires_at.
+type IdempotencyRecord struct {
+	ID          string    `bson:"_id"` // scope:user_id:key
+	UserID      int       `bson:"user_id"`
+	ChatID      string    `bson:"chat_id,omitempty"` // Agar ikut terhapus saat chat dihapus permanen
+	RequestHash string    `bson:"request_hash"`
+	Status      string    `bson:"status"`
+	StatusCode  int       `bson:"status_code,omitempty"`
+	ContentType string    `bson:"content_type,omitempty"`
+	Body        []byte    `bson:"body,omitempty"`
+	CreatedAt   time.Time `bson:"created_at"`
+	LockedUntil time.Time `bson:"locked_until"` // Lewat dari ini, pemroses dianggap mati dan boleh diambil alih
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
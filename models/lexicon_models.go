@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ==== Bagian: Leksikon Pelafalan TTS ====
+
+// LexiconEntry mengganti pelafalan sebuah istilah saat TTS, mis. "KPR" → "ka pe er".
+// Teks balasan tidak berubah; hanya suara yang memakai alias.
+type LexiconEntry struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Term      string             `bson:"term" json:"term"`   // Istilah persis seperti di teks (peka huruf besar)
+	Alias     string             `bson:"alias" json:"alias"` // Cara membacanya
+	CreatedBy int                `bson:"created_by" json:"created_by"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ==== Bagian: Outbox Efek Samping ====
+
+const (
+	OutboxKindLastChatID = "last_chat_id" // UPDATE users.last_chat_id di PostgreSQL
+	OutboxKindWebhook    = "webhook"      // POST event ke OUTBOX_WEBHOOK_URL
+)
+
+// OutboxItem adalah efek samping di luar MongoDB yang dicatat di dokumen percakapan
+// dalam update atomik yang sama dengan penulisan pesan. Dispatcher mengulanginya
+// sampai berhasil, lalu menghapusnya dari array.
+type OutboxItem struct {
+	ID            primitive.ObjectID `bson:"_id" json:"id"`
+	Kind          string             `bson:"kind" json:"kind"`
+	UserID        int                `bson:"user_id" json:"user_id"`
+	ChatID        string             `bson:"chat_id" json:"chat_id"`
+	Payload       string             `bson:"payload,omitempty" json:"payload,omitempty"` // Body JSON untuk webhook
+	Attempts      int                `bson:"attempts" json:"attempts"`
+	LastError     string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at" json:"next_attempt_at"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ==== Bagian: Tautan Berbagi Percakapan ====
+
+// ShareLink adalah tautan baca-saja untuk satu chat. Token asli hanya diberikan sekali
+// saat dibuat; yang disimpan hanya hash-nya.
+type ShareLink struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ChatID         string             `bson:"chat_id" json:"chat_id"`
+	UserID         int                `bson:"user_id" json:"-"`
+	TokenHash      string             `bson:"token_hash" json:"-"`
+	TokenHint      string             `bson:"token_hint" json:"token_hint"` // Beberapa karakter awal token untuk identifikasi
+	MaxSeq         int64              `bson:"max_seq" json:"max_seq"`       // Pesan setelah tautan dibuat tidak ikut dibagikan
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt      time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt      *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	AccessCount    int64              `bson:"access_count" json:"access_count"`
+	LastAccessedAt *time.Time         `bson:"last_accessed_at,omitempty" json:"last_accessed_at,omitempty"`
+}
+
+// ShareAccess adalah satu baris log akses tautan berbagi yang dapat dilihat pemilik chat
+type ShareAccess struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ShareID   primitive.ObjectID `bson:"share_id" json:"share_id"`
+	ChatID    string             `bson:"chat_id" json:"-"`
+	IP        string             `bson:"ip" json:"ip"`
+	UserAgent string             `bson:"user_agent" json:"user_agent"`
+	Format    string             `bson:"format" json:"format"`
+	At        time.Time          `bson:"at" json:"at"`
+}
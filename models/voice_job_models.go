@@ -0,0 +1,100 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ==== Bagian: Job Pemrosesan Suara ====
+
+// Status job suara
+const (
+	VoiceJobPending = "pending"
+	VoiceJobRunning = "running"
+	VoiceJobDone    = "done"
+	VoiceJobFailed  = "failed"
+)
+
+// Tahap pipeline suara, dijalankan berurutan
+const (
+	VoiceStageConvert    = "convert"    // ffmpeg ke VOICE_STT_FORMAT
+	VoiceStagePreprocess = "preprocess" // deteksi suara, pangkas diam, normalisasi loudness, reduksi derau
+	VoiceStageUpload     = "upload"     // simpan audio user (ke S3 bila STT membutuhkannya)
+	VoiceStageTranscribe = "transcribe" // speech-to-text
+	VoiceStageNLP        = "nlp"        // moderasi dan intent
+	VoiceStageTTS        = "tts"        // sintesis dan unggah audio balasan
+	VoiceStageSave       = "save"       // simpan giliran ke collection messages
+)
+
+// VoiceStages adalah urutan tahap pipeline suara
+var VoiceStages = []string{
+	VoiceStageConvert, VoiceStagePreprocess, VoiceStageUpload, VoiceStageTranscribe,
+	VoiceStageNLP, VoiceStageTTS, VoiceStageSave,
+}
+
+// VoiceJobStage mencatat status dan error per tahap
+type VoiceJobStage struct {
+	Name        string     `bson:"name" json:"name"`
+	Status      string     `bson:"status" json:"status"`
+	Attempts    int        `bson:"attempts" json:"attempts"`
+	Error       string     `bson:"error,omitempty" json:"error,omitempty"`
+	StartedAt   *time.Time `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	CompletedAt *time.Time `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
+
+// VoiceJob adalah satu unggahan suara yang diproses worker. Hasil tiap tahap disimpan
+// sehingga retry melanjutkan dari tahap yang gagal, bukan dari awal.
+type VoiceJob struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ChatID    string             `bson:"chat_id" json:"chat_id"`
+	UserID    int                `bson:"user_id" json:"-"`
+	Status    string             `bson:"status" json:"status"`
+	Stage     string             `bson:"stage,omitempty" json:"stage,omitempty"` // Tahap yang sedang/terakhir berjalan
+	Stages    []VoiceJobStage    `bson:"stages" json:"stages"`
+	Error     string             `bson:"error,omitempty" json:"error,omitempty"`
+	Reasons   []string           `bson:"reasons,omitempty" json:"reasons,omitempty"` // Alasan penolakan moderasi
+	Retryable bool               `bson:"retryable" json:"retryable"`
+
+	// Artefak antar tahap. Rekaman asli ada di GridFS (bucket voice_inputs, _id = ID
+	// job) agar bisa dibaca worker di instance mana pun; ConvertedPath lokal per instance.
+	InputFormat   string   `bson:"input_format,omitempty" json:"input_format,omitempty"`   // Hasil deteksi isi berkas
+	OutputFormat  string   `bson:"output_format,omitempty" json:"output_format,omitempty"` // Format audio balasan hasil negosiasi
+	ConvertedPath string   `bson:"converted_path,omitempty" json:"-"`
+	AudioMs       int64    `bson:"audio_ms,omitempty" json:"-"`                    // Durasi rekaman setelah pra-pemrosesan
+	SpeechMs      int64    `bson:"speech_ms,omitempty" json:"speech_ms,omitempty"` // Durasi ucapan terdeteksi
+	UserAudioURL  string   `bson:"user_audio_url,omitempty" json:"-"`
+	TranscribeJob string   `bson:"transcribe_job,omitempty" json:"-"`
+	Transcript    string   `bson:"transcript,omitempty" json:"transcript,omitempty"` // Disimpan dalam bentuk bermasker
+	STTProvider   string   `bson:"stt_provider,omitempty" json:"stt_provider,omitempty"`
+	Flags         []string `bson:"flags,omitempty" json:"-"`
+	Intent        string   `bson:"intent,omitempty" json:"intent,omitempty"`
+	Confidence    float64  `bson:"confidence,omitempty" json:"-"`
+	Response      string   `bson:"response,omitempty" json:"response,omitempty"` // Disimpan dalam bentuk bermasker
+	BotAudioURL   string   `bson:"bot_audio_url,omitempty" json:"bot_audio_url,omitempty"`
+	TTSProvider   string   `bson:"tts_provider,omitempty" json:"tts_provider,omitempty"`
+
+	// Teks asli terenkripsi (PII_STORE_ENCRYPTED_ORIGINAL) agar retry tahap berikutnya
+	// tetap memakai teks utuh; teks polos hanya ada di memori worker
+	TranscriptEncrypted string `bson:"transcript_encrypted,omitempty" json:"-"`
+	ResponseEncrypted   string `bson:"response_encrypted,omitempty" json:"-"`
+
+	// Sewa klaim worker: job running dengan locked_until yang lewat boleh diklaim ulang
+	Owner       string     `bson:"owner,omitempty" json:"-"`
+	LockedUntil *time.Time `bson:"locked_until,omitempty" json:"-"`
+
+	// Pengaturan suara hasil gabungan preferensi user dan override saat unggah
+	Voice VoiceSettings `bson:"voice" json:"voice"`
+
+	UserMessageID primitive.ObjectID `bson:"user_message_id" json:"user_message_id"`
+	BotMessageID  primitive.ObjectID `bson:"bot_message_id" json:"bot_message_id"`
+
+	CreatedAt   time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `bson:"updated_at" json:"updated_at"`
+	CompletedAt *time.Time `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+}
+
+// Terminal menandakan job tidak akan berubah lagi tanpa retry
+func (j *VoiceJob) Terminal() bool {
+	return j.Status == VoiceJobDone || j.Status == VoiceJobFailed
+}
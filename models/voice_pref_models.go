@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// ==== Bagian: Preferensi Suara ====
+
+// Gender suara TTS
+const (
+	VoiceGenderMale    = "male"
+	VoiceGenderFemale  = "female"
+	VoiceGenderNeutral = "neutral"
+)
+
+// VoiceSettings adalah pengaturan suara TTS. Nilai kosong berarti default provider.
+type VoiceSettings struct {
+	Provider     string  `bson:"provider,omitempty" json:"provider,omitempty"`           // google, polly, local, fake
+	Gender       string  `bson:"gender,omitempty" json:"gender,omitempty"`               // male, female, neutral
+	VoiceName    string  `bson:"voice_name,omitempty" json:"voice_name,omitempty"`       // Nama suara dari daftar provider
+	SpeakingRate float64 `bson:"speaking_rate,omitempty" json:"speaking_rate,omitempty"` // 1.0 = normal
+	Pitch        float64 `bson:"pitch,omitempty" json:"pitch,omitempty"`                 // Semitone, 0 = normal
+}
+
+// VoicePreferences adalah pengaturan suara tersimpan milik user
+type VoicePreferences struct {
+	UserID        int `bson:"_id" json:"-"`
+	VoiceSettings `bson:",inline"`
+	UpdatedAt     *time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"backend-go/config"
 	"backend-go/controllers"
 	"backend-go/middleware"
 
@@ -10,45 +11,84 @@ import (
 // SetupRoutes mengatur semua rute utama aplikasi
 func SetupRoutes(r *gin.Engine) {
 
-	// Rute untuk chatbot
-	r.POST("/chatbot", controllers.ChatbotHandler)
-
 	// Rute untuk autentikasi dan manajemen user
 	authGroup := r.Group("/auth")
 	{
-		authGroup.POST("/login", controllers.Login)                                           // Login user
+		authGroup.POST("/login", middleware.RateLimit("login"), controllers.Login)            // Login user
 		authGroup.POST("/logout", middleware.JWTAuthMiddleware(), controllers.Logout)         // Logout user
 		authGroup.POST("/register", controllers.CreateUser)                                   // Registrasi atau buat user baru
-		authGroup.POST("/chatbot", controllers.ChatbotHandler)                                // Chatbot dapat diakses oleh semua user yang terautentikasi
 		authGroup.GET("/current", middleware.JWTAuthMiddleware(), controllers.GetCurrentUser) // Untuk Ambil Data user yang sedang login
-		authGroup.POST("/last-chat", controllers.UpdateLastChatIDHandler)
+		authGroup.POST("/last-chat", middleware.JWTAuthMiddleware(), controllers.UpdateLastChatIDHandler)
 	}
 
 	// Rute untuk fitur teks chatbot
 	chatGroup := r.Group("/chat")
 	chatGroup.Use(middleware.JWTAuthMiddleware())
 	{
-		chatGroup.POST("/:chatID", controllers.ChatbotHandler)
-		chatGroup.GET("/:chatID/full", controllers.GetFullChatHistory)
-		chatGroup.GET("/:chatID", controllers.GetChatByID)
+		chatGroup.POST("", controllers.CreateChatHandler)
 		chatGroup.GET("/list", controllers.GetUserChats)
-		chatGroup.PUT("/:chatID", controllers.RenameChatHandler)
-		chatGroup.DELETE("/:chatID", controllers.DeleteChatHandler)
+		chatGroup.GET("/search", controllers.SearchMessagesHandler)
+		chatGroup.GET("/labels", controllers.GetLabelsHandler)
+		chatGroup.POST("/labels", controllers.CreateLabelHandler)
+		chatGroup.PUT("/labels/:labelID", controllers.UpdateLabelHandler)
+		chatGroup.DELETE("/labels/:labelID", controllers.DeleteLabelHandler)
+		chatGroup.GET("/trash", controllers.GetTrashHandler)
+		chatGroup.POST("/trash/:chatID/restore", controllers.RestoreChatHandler)
+		chatGroup.DELETE("/trash/:chatID", controllers.PurgeChatHandler)
+		chatGroup.GET("/exports/:jobID", controllers.ExportJobStatusHandler(false))
+		chatGroup.GET("/exports/:jobID/download", controllers.DownloadExportHandler(false))
+
+		owned := chatGroup.Group("/:chatID", middleware.ChatOwnerOnly())
+		owned.POST("", middleware.RateLimit("chat"), middleware.Idempotency("chat"), controllers.ChatbotHandler)
+		owned.GET("/full", controllers.GetFullChatHistory)
+		owned.GET("/export", controllers.ExportChatHandler)
+		owned.GET("", controllers.GetChatByID)
+		owned.PUT("", controllers.RenameChatHandler)
+		owned.DELETE("", controllers.DeleteChatHandler)
+		owned.PATCH("/flags", controllers.SetChatFlagsHandler)
+		owned.PUT("/labels", controllers.SetChatLabelsHandler)
+		owned.PUT("/messages/:messageID", middleware.RateLimit("chat"), controllers.EditMessageHandler)
+		owned.POST("/shares", controllers.CreateShareLinkHandler)
+		owned.GET("/shares", controllers.GetShareLinksHandler)
+		owned.DELETE("/shares/:shareID", controllers.RevokeShareLinkHandler)
+		owned.GET("/shares/:shareID/access", controllers.GetShareAccessHandler)
 	}
 
+	// Transkrip baca-saja yang dibagikan lewat tautan bertoken, tanpa login
+	r.GET("/shared/:token", middleware.RateLimit("share"), controllers.SharedTranscriptHandler)
+
 	// Rute untuk fitur voice message (speech-to-text dan text-to-speech)
 	voiceGroup := r.Group("/voice")
 	voiceGroup.Use(middleware.JWTAuthMiddleware())
 	{
-		voiceGroup.POST("/upload", controllers.UploadVoiceHandler)     // Upload audio dan transkripsi
-		voiceGroup.GET("/:chatID", controllers.GetVoiceMessagesByID)   // Ambil voice messages per chat
-		voiceGroup.GET("/audio/:filename", controllers.ServeAudioFile) // Serve audio TTS dari S3 atau local
+		voiceGroup.POST("/upload", middleware.RateLimit("voice"), middleware.MaxBodyBytes(config.VoiceMaxUploadBytes), middleware.ChatOwnerOnly(), middleware.Idempotency("voice"), controllers.UploadVoiceHandler) // Upload audio dan transkripsi
+		voiceGroup.GET("/:chatID", middleware.ChatOwnerOnly(), controllers.GetVoiceMessagesByID)                                                                                                                    // Ambil voice messages per chat
+		voiceGroup.GET("/audio/:filename", controllers.ServeAudioFile)                                                                                                                                              // Serve audio TTS dari S3 atau local
+		voiceGroup.GET("/jobs/:jobID", controllers.GetVoiceJobHandler)                                                                                                                                              // Status job pemrosesan suara
+		voiceGroup.GET("/jobs/:jobID/events", controllers.VoiceJobEventsHandler)                                                                                                                                    // Push status job (SSE)
+		voiceGroup.POST("/jobs/:jobID/retry", middleware.RateLimit("voice"), controllers.RetryVoiceJobHandler)                                                                                                      // Ulangi job yang gagal
+		voiceGroup.GET("/preferences", controllers.GetVoicePreferencesHandler)                                                                                                                                      // Preferensi suara TTS user
+		voiceGroup.PUT("/preferences", controllers.UpdateVoicePreferencesHandler)                                                                                                                                   // Ubah gender, nama suara, kecepatan, pitch
+		voiceGroup.GET("/voices", controllers.GetTTSVoicesHandler)                                                                                                                                                  // Daftar suara per provider
+		voiceGroup.GET("/stream/:chatID", middleware.RateLimit("voice"), middleware.ChatOwnerOnly(), controllers.VoiceStreamHandler)                                                                                // Percakapan suara real-time (WebSocket)
 	}
 
 	admin := r.Group("/admin", middleware.JWTAuthMiddleware(), controllers.AdminOnly())
 	{
 		admin.GET("/metrics", controllers.GetAdminMetricsHandler)
 		admin.GET("/conversations", controllers.GetRecentConversationsHandler)
+		admin.GET("/search", controllers.AdminSearchHandler)
+		admin.POST("/exports", controllers.CreateBulkExportHandler)
+		admin.GET("/exports/:jobID", controllers.ExportJobStatusHandler(true))
+		admin.GET("/exports/:jobID/download", controllers.DownloadExportHandler(true))
+		admin.GET("/outbox", controllers.GetOutboxHandler)
+		admin.POST("/outbox/:itemID/retry", controllers.RetryOutboxHandler)
+		admin.GET("/tts/lexicon", controllers.GetLexiconHandler)
+		admin.POST("/tts/lexicon", controllers.CreateLexiconHandler)
+		admin.PUT("/tts/lexicon/:entryID", controllers.UpdateLexiconHandler)
+		admin.DELETE("/tts/lexicon/:entryID", controllers.DeleteLexiconHandler)
+		admin.POST("/tts/preview", controllers.PreviewSpeechHandler)
+		admin.POST("/tts/prewarm", controllers.PrewarmTTSCacheHandler)
 	}
 
 }
@@ -0,0 +1,184 @@
+package routes
+
+import (
+	"backend-go/config"
+	"backend-go/services"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// useTestMongo menghubungkan config.MongoDB ke database sementara di MONGO_TEST_URI dan
+// menghapusnya setelah test. Test dilewati bila MONGO_TEST_URI tidak diisi.
+func useTestMongo(t *testing.T) {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI tidak diisi")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("gagal terhubung ke MongoDB: %v", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatalf("gagal ping MongoDB: %v", err)
+	}
+
+	prevClient, prevDB := config.MongoClient, config.MongoDB
+	config.MongoClient = client
+	config.MongoDB = client.Database(fmt.Sprintf("routes_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		config.MongoDB.Drop(ctx)
+		client.Disconnect(ctx)
+		config.MongoClient, config.MongoDB = prevClient, prevDB
+	})
+}
+
+func bearer(t *testing.T, userID int, username string) string {
+	t.Helper()
+	token, err := services.GenerateJWT(userID, username, "user", "active")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + token
+}
+
+func voiceUploadBody(t *testing.T, chatID string) (*bytes.Buffer, string) {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("chat_id", chatID)
+	part, _ := w.CreateFormFile("audio", "rekaman.webm")
+	part.Write([]byte{0x1A, 0x45, 0xDF, 0xA3})
+	w.Close()
+	return &body, w.FormDataContentType()
+}
+
+// TestChatRoutesRejectOtherUsers memastikan user B tidak dapat membaca, mengubah,
+// mengirim pesan, atau memakai fitur suara pada chat milik user A
+func TestChatRoutesRejectOtherUsers(t *testing.T) {
+	useTestMongo(t)
+	gin.SetMode(gin.TestMode)
+
+	const ownerID, intruderID = 101, 202
+	now := time.Now()
+	_, err := config.MongoDB.Collection("conversations").InsertOne(context.Background(), bson.M{
+		"chat_id": "chat-a", "user_id": ownerID, "username": "andi", "chat_title": "Milik A",
+		"message_count": 0, "pinned": false, "archived": false, "starred": false,
+		"created_at": now, "updated_at": now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	SetupRoutes(r)
+
+	ownerAuth := bearer(t, ownerID, "andi")
+	intruderAuth := bearer(t, intruderID, "budi")
+
+	type request struct {
+		method, path, body, contentType string
+	}
+	jsonReq := func(method, path, body string) request {
+		return request{method, path, body, "application/json"}
+	}
+	upload := func(chatID string) request {
+		body, ct := voiceUploadBody(t, chatID)
+		return request{http.MethodPost, "/voice/upload", body.String(), ct}
+	}
+
+	cases := []struct {
+		name string
+		req  request
+		auth string
+		want int
+	}{
+		{"pemilik membaca chat", jsonReq(http.MethodGet, "/chat/chat-a", ""), ownerAuth, http.StatusOK},
+		{"baca chat", jsonReq(http.MethodGet, "/chat/chat-a", ""), intruderAuth, http.StatusForbidden},
+		{"riwayat penuh", jsonReq(http.MethodGet, "/chat/chat-a/full", ""), intruderAuth, http.StatusForbidden},
+		{"kirim pesan", jsonReq(http.MethodPost, "/chat/chat-a", `{"message":"halo"}`), intruderAuth, http.StatusForbidden},
+		{"ganti judul", jsonReq(http.MethodPut, "/chat/chat-a", `{"new_title":"x"}`), intruderAuth, http.StatusForbidden},
+		{"hapus chat", jsonReq(http.MethodDelete, "/chat/chat-a", ""), intruderAuth, http.StatusForbidden},
+		{"ubah flag", jsonReq(http.MethodPatch, "/chat/chat-a/flags", `{"pinned":true}`), intruderAuth, http.StatusForbidden},
+		{"ekspor", jsonReq(http.MethodGet, "/chat/chat-a/export", ""), intruderAuth, http.StatusForbidden},
+		{"pesan suara", jsonReq(http.MethodGet, "/voice/chat-a", ""), intruderAuth, http.StatusForbidden},
+		{"unggah suara", upload("chat-a"), intruderAuth, http.StatusForbidden},
+		{"stream suara", jsonReq(http.MethodGet, "/voice/stream/chat-a", ""), intruderAuth, http.StatusForbidden},
+		{"last chat", jsonReq(http.MethodPost, "/auth/last-chat", `{"chat_id":"chat-a"}`), intruderAuth, http.StatusForbidden},
+		{"chat tidak ada", jsonReq(http.MethodGet, "/chat/chat-x", ""), intruderAuth, http.StatusNotFound},
+		{"unggah ke chat tidak ada", upload("chat-x"), intruderAuth, http.StatusNotFound},
+		{"last chat tidak ada", jsonReq(http.MethodPost, "/auth/last-chat", `{"chat_id":"chat-x"}`), intruderAuth, http.StatusNotFound},
+		{"pemilik menghapus chat", jsonReq(http.MethodDelete, "/chat/chat-a", ""), ownerAuth, http.StatusOK},
+		{"hapus chat yang sudah di tong sampah", jsonReq(http.MethodDelete, "/chat/chat-a", ""), ownerAuth, http.StatusNotFound},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.req.method, tc.req.path, bytes.NewBufferString(tc.req.body))
+			req.Header.Set("Content-Type", tc.req.contentType)
+			req.Header.Set("Authorization", tc.auth)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Errorf("%s %s = %d, ingin %d (%s)", tc.req.method, tc.req.path, w.Code, tc.want, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestLegacyChatbotRoutesRemoved memastikan rute chatbot lama tanpa kepemilikan tidak ada lagi
+func TestLegacyChatbotRoutesRemoved(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	SetupRoutes(r)
+
+	for _, path := range []string{"/chatbot", "/auth/chatbot"} {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(`{"chat_id":"chat-a","message":"halo"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("POST %s = %d, ingin 404", path, w.Code)
+		}
+	}
+}
+
+// TestAdminConversationsRejectsForeignCursor memastikan cursor dari urutan lain (mis.
+// daftar chat urut judul) ditolak dengan 400, bukan 500
+func TestAdminConversationsRejectsForeignCursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	SetupRoutes(r)
+
+	token, err := services.GenerateJWT(1, "admin", "admin", "active")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor := base64.RawURLEncoding.EncodeToString([]byte(`{"n":"Judul","c":"chat-a","k":"title"}`))
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/conversations?after="+cursor, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("GET /admin/conversations = %d, ingin 400: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"backend-go/config"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Aksi moderasi
+const (
+	ModerationAllow  = "allow"
+	ModerationFlag   = "flag"
+	ModerationReject = "reject"
+)
+
+// ModerationResult adalah keputusan filter kata kasar dan spam untuk satu pesan
+type ModerationResult struct {
+	Action  string
+	Reasons []string
+}
+
+// ErrMessageRejected dikembalikan jika pesan ditolak oleh filter sebelum sampai ke NLP
+type ErrMessageRejected struct {
+	Reasons []string
+}
+
+func (e *ErrMessageRejected) Error() string {
+	return fmt.Sprintf("pesan ditolak oleh filter: %s", strings.Join(e.Reasons, ", "))
+}
+
+// profanityWords adalah daftar kata kasar (bentuk dasar, setelah normalisasi)
+var profanityWords = map[string]bool{
+	"anjing": true, "anjir": true, "bangsat": true, "bajingan": true, "babi": true,
+	"goblok": true, "tolol": true, "bego": true, "kampret": true, "brengsek": true,
+	"keparat": true, "jancok": true, "jancuk": true, "asu": true, "kontol": true,
+	"memek": true, "ngentot": true, "pepek": true, "tai": true, "taik": true,
+	"fuck": true, "shit": true, "bitch": true, "asshole": true, "bastard": true,
+}
+
+var (
+	urlPattern   = regexp.MustCompile(`(?i)(https?://|www\.)\S+`)
+	wordPattern  = regexp.MustCompile(`[a-z]+`)
+	leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+)
+
+const (
+	maxLinksPerMessage = 3
+	duplicateWindow    = time.Minute
+	maxDuplicates      = 3
+)
+
+// duplicateTracker mendeteksi pesan identik yang dikirim berulang oleh user yang sama
+type duplicateTracker struct {
+	mu   sync.Mutex
+	seen map[string][]time.Time
+}
+
+var recentMessages = &duplicateTracker{seen: make(map[string][]time.Time)}
+
+func (t *duplicateTracker) hit(key string, now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := now.Add(-duplicateWindow)
+	kept := t.seen[key][:0]
+	for _, ts := range t.seen[key] {
+		if ts.After(cutoff) {
+			kept = append(kept, ts)
+		}
+	}
+	kept = append(kept, now)
+	t.seen[key] = kept
+
+	// Bersihkan entri kedaluwarsa milik user lain sesekali
+	if len(t.seen) > 10000 {
+		for k, v := range t.seen {
+			if len(v) == 0 || v[len(v)-1].Before(cutoff) {
+				delete(t.seen, k)
+			}
+		}
+	}
+	return len(kept)
+}
+
+// ModerateMessage memeriksa kata kasar dan pola spam sebelum pesan diteruskan ke NLP
+func ModerateMessage(userID int, text string) ModerationResult {
+	result := ModerationResult{Action: ModerationAllow}
+	reject := func(reason string) {
+		result.Action = ModerationReject
+		result.Reasons = append(result.Reasons, reason)
+	}
+	flag := func(reason string) {
+		if result.Action != ModerationReject {
+			result.Action = ModerationFlag
+		}
+		result.Reasons = append(result.Reasons, reason)
+	}
+
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		reject("empty")
+		return result
+	}
+
+	if config.MaxChatMessageChars > 0 && utf8.RuneCountInString(trimmed) > config.MaxChatMessageChars {
+		reject("too_long")
+	}
+
+	if len(urlPattern.FindAllString(trimmed, -1)) > maxLinksPerMessage {
+		reject("too_many_links")
+	}
+
+	if hasLongRepeat(trimmed) {
+		flag("repeated_characters")
+	}
+
+	if recentMessages.hit(fmt.Sprintf("%d:%s", userID, strings.ToLower(trimmed)), time.Now()) > maxDuplicates {
+		reject("duplicate_message")
+	}
+
+	if containsProfanity(trimmed) {
+		if config.AbuseProfanityMode == ModerationReject {
+			reject("profanity")
+		} else {
+			flag("profanity")
+		}
+	}
+
+	return result
+}
+
+// hasLongRepeat mendeteksi karakter yang diulang 20 kali atau lebih
+func hasLongRepeat(s string) bool {
+	var prev rune
+	run := 0
+	for _, r := range s {
+		if r == prev {
+			run++
+			if run >= 20 {
+				return true
+			}
+		} else {
+			prev, run = r, 1
+		}
+	}
+	return false
+}
+
+// containsProfanity menormalkan leetspeak dan huruf berulang ("anjiiing", "b4ngs4t")
+func containsProfanity(text string) bool {
+	normalized := leetReplacer.Replace(strings.ToLower(text))
+	for _, w := range wordPattern.FindAllString(normalized, -1) {
+		if profanityWords[w] {
+			return true
+		}
+		if collapsed := collapseRepeats(w); profanityWords[collapsed] {
+			return true
+		}
+	}
+	return false
+}
+
+func collapseRepeats(w string) string {
+	var b strings.Builder
+	var prev rune
+	for i, r := range w {
+		if i == 0 || r != prev {
+			b.WriteRune(r)
+		}
+		prev = r
+	}
+	return b.String()
+}
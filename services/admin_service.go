@@ -1,13 +1,14 @@
 package services
 
 import (
-	"fmt"
+	"context"
+	"time"
 
 	"backend-go/config"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // struct untuk response frontend
@@ -20,7 +21,7 @@ type ConversationSummary struct {
 // GetAdminMetrics:
 // - totalUsers -> dari PostgreSQL (tabel users)
 // - totalConvos -> dari MongoDB (jumlah dokumen pada collection conversations)
-// - totalMsgs -> dari MongoDB (jumlah total elemen pesan di field messages pada tiap dokumen)
+// - totalMsgs -> dari MongoDB (jumlah pesan final pada collection messages, tanpa versi yang disunting)
 func GetAdminMetrics(c *gin.Context) (map[string]int64, error) {
 	var totalUsers int64
 
@@ -42,45 +43,10 @@ func GetAdminMetrics(c *gin.Context) (map[string]int64, error) {
 		return nil, err
 	}
 
-	// 3) Hitung total messages menggunakan aggregation: sum ukuran array messages di setiap dokumen
-	// pipeline:
-	//  - project messagesCount = size(ifNull(messages, []))
-	//  - group _id: null, total: sum(messagesCount)
-	pipeline := mongoPipelineForMessageCount()
-	cursor, err := convCollection.Aggregate(ctx, pipeline)
+	// 3) Hitung total messages langsung dari collection messages
+	totalMsgs, err := config.MongoDB.Collection("messages").CountDocuments(ctx, finalMessagesFilter)
 	if err != nil {
-		config.Log.Error("Error aggregating total messages in MongoDB: ", err)
-		return nil, err
-	}
-	defer cursor.Close(ctx)
-
-	var totalMsgs int64 = 0
-	if cursor.Next(ctx) {
-		var doc bson.M
-		if err := cursor.Decode(&doc); err != nil {
-			config.Log.Error("Error decoding aggregation result: ", err)
-			return nil, err
-		}
-		// doc["total"] bisa berupa int32/int64/float64 tergantung hasil driver
-		if v, ok := doc["total"]; ok && v != nil {
-			switch n := v.(type) {
-			case int32:
-				totalMsgs = int64(n)
-			case int64:
-				totalMsgs = n
-			case float64:
-				totalMsgs = int64(n)
-			default:
-				// fallback: fmt.Sprint -> parse if needed
-				var parsed int64
-				_, _ = fmt.Sscan(fmt.Sprint(n), &parsed)
-				totalMsgs = parsed
-			}
-		}
-	}
-	// check cursor error
-	if err := cursor.Err(); err != nil {
-		config.Log.Error("Cursor error during messages aggregation: ", err)
+		config.Log.Error("Error counting messages in MongoDB: ", err)
 		return nil, err
 	}
 
@@ -90,114 +56,90 @@ func GetAdminMetrics(c *gin.Context) (map[string]int64, error) {
 		"total_messages":      totalMsgs,
 	}
 
+	// 4) Rincian jumlah pesan per modality (messages_text, messages_voice, ...)
+	byModality, err := countMessagesByModality(ctx)
+	if err != nil {
+		config.Log.Error("Error aggregating messages by modality: ", err)
+		return nil, err
+	}
+	for modality, n := range byModality {
+		metrics["messages_"+modality] = n
+	}
+
+	// 5) Statistik cache audio TTS sejak server mulai
+	for name, n := range TTSCacheMetrics() {
+		metrics[name] = n
+	}
+
 	return metrics, nil
 }
 
-// helper: buat pipeline untuk menghitung total messages
-func mongoPipelineForMessageCount() mongoPipeline {
-	// return pipeline as []bson.M or []bson.D depending on style
-	// We define as []bson.M for readability
-	return mongoPipeline{
-		bson.D{{Key: "$project", Value: bson.D{
-			{Key: "messagesCount", Value: bson.D{
-				{Key: "$size", Value: bson.D{
-					{Key: "$ifNull", Value: bson.A{"$messages", bson.A{}}},
-				}},
-			}},
-		}}},
-		bson.D{{Key: "$group", Value: bson.D{
-			{Key: "_id", Value: nil},
-			{Key: "total", Value: bson.D{{Key: "$sum", Value: "$messagesCount"}}},
+// finalMessagesFilter mengecualikan pesan yang sudah digantikan suntingan dari statistik
+var finalMessagesFilter = bson.M{"superseded_by": bson.M{"$exists": false}}
+
+// countMessagesByModality menghitung jumlah pesan per modality
+func countMessagesByModality(ctx context.Context) (map[string]int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: finalMessagesFilter}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$modality"},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
 		}}},
 	}
-}
-
-// typedef-like alias to keep pipeline helper tidy
-type mongoPipeline = []bson.D
 
-// GetRecentConversations: ambil dokumen conversations terbaru dari MongoDB
-// Kembalikan slice ConversationSummary dengan batas limit 10 (atau parameter dari query jika ingin)
-func GetRecentConversations(c *gin.Context) ([]ConversationSummary, error) {
-	var convos []ConversationSummary
+	cursor, err := config.MongoDB.Collection("messages").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
 
-	convCollection := config.MongoDB.Collection("conversations")
-	ctx := c.Request.Context()
+	var rows []struct {
+		Modality string `bson:"_id"`
+		Count    int64  `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
 
-	// limit optional via query param ?limit=
-	limit := int64(10)
-	if l := c.Query("limit"); l != "" {
-		var tmp int
-		if _, err := fmt.Sscan(l, &tmp); err == nil && tmp > 0 {
-			limit = int64(tmp)
+	counts := map[string]int64{}
+	for _, row := range rows {
+		if row.Modality != "" {
+			counts[row.Modality] = row.Count
 		}
 	}
+	return counts, nil
+}
 
-	opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}}).SetLimit(limit)
-
-	cursor, err := convCollection.Find(ctx, bson.D{}, opts)
+// GetRecentConversations: ambil percakapan terbaru dari MongoDB per halaman (cursor)
+func GetRecentConversations(c *gin.Context, req PageRequest) (Page[ConversationSummary], error) {
+	convos, err := PageConversations(c.Request.Context(), bson.M{"deleted_at": notDeleted}, req)
 	if err != nil {
 		config.Log.Error("Error retrieving recent conversations from MongoDB: ", err)
-		return nil, err
+		return Page[ConversationSummary]{}, err
 	}
-	defer cursor.Close(ctx)
 
-	for cursor.Next(ctx) {
-		var doc bson.M
-		if err := cursor.Decode(&doc); err != nil {
-			config.Log.Error("Error decoding conversation document: ", err)
-			return nil, err
+	page := Page[ConversationSummary]{
+		Data:       make([]ConversationSummary, 0, len(convos.Data)),
+		NextCursor: convos.NextCursor,
+		PrevCursor: convos.PrevCursor,
+		HasMore:    convos.HasMore,
+	}
+	for _, conv := range convos.Data {
+		// Preferensi: updated_at, atau timestamp pratinjau pesan terakhir
+		lastAt := conv.UpdatedAt
+		if lastAt.IsZero() && conv.LastMessage != nil {
+			lastAt = conv.LastMessage.Timestamp
 		}
 
-		// Ambil fields: chat_id, username, last_message_at
 		cs := ConversationSummary{
-			ChatID:        toString(doc["chat_id"]),
-			Username:      toString(doc["username"]),
-			LastMessageAt: "",
+			ChatID:   conv.ChatID,
+			Username: conv.Username,
 		}
-
-		// Preferensi: ambil timestamp dari field updated_at jika ada,
-		// atau ambil waktu pesan terakhir dari messages array (messages[-1].created_at)
-		if ua, ok := doc["updated_at"]; ok && ua != nil {
-			cs.LastMessageAt = fmt.Sprint(ua)
-		} else if msgs, ok := doc["messages"]; ok && msgs != nil {
-			// messages mungkin array of bson.M
-			if arr, ok := msgs.(bson.A); ok && len(arr) > 0 {
-				last := arr[len(arr)-1]
-				if m, ok := last.(bson.M); ok {
-					if t, ok := m["created_at"]; ok && t != nil {
-						cs.LastMessageAt = fmt.Sprint(t)
-					} else if t2, ok := m["timestamp"]; ok && t2 != nil {
-						cs.LastMessageAt = fmt.Sprint(t2)
-					} else {
-						// fallback: serialize the message or set empty
-						cs.LastMessageAt = ""
-					}
-				}
-			}
+		if !lastAt.IsZero() {
+			cs.LastMessageAt = lastAt.Format(time.RFC3339)
 		}
-
-		convos = append(convos, cs)
-	}
-
-	if err := cursor.Err(); err != nil {
-		config.Log.Error("Cursor error after iterating recent conversations: ", err)
-		return nil, err
+		page.Data = append(page.Data, cs)
 	}
 
-	return convos, nil
-}
-
-// toString: helper kecil untuk mengkonversi interface{} ke string aman
-func toString(v interface{}) string {
-	if v == nil {
-		return ""
-	}
-	switch x := v.(type) {
-	case string:
-		return x
-	case fmt.Stringer:
-		return x.String()
-	default:
-		return fmt.Sprint(x)
-	}
+	return page, nil
 }
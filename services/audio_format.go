@@ -0,0 +1,169 @@
+package services
+
+import (
+	"backend-go/config"
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/speech/apiv1/speechpb"
+	transcribeTypes "github.com/aws/aws-sdk-go-v2/service/transcribe/types"
+)
+
+// Format audio yang dihasilkan server (balasan TTS dan salinan untuk STT)
+const (
+	AudioFormatMP3 = "mp3"
+	AudioFormatOgg = "ogg" // Ogg Opus, didukung Chrome, Firefox, dan Android
+	AudioFormatWAV = "wav" // PCM 16-bit, untuk klien tanpa dekoder
+)
+
+var ErrAudioFormatUnsupported = errors.New("format audio harus mp3, ogg, atau wav")
+
+// audioOutputFormat menjelaskan cara menghasilkan dan menyajikan satu format keluaran
+type audioOutputFormat struct {
+	contentType string
+	ffmpeg      string // muxer ffmpeg (-f)
+	codec       string // encoder ffmpeg (-c:a)
+	transcribe  transcribeTypes.MediaFormat
+	google      speechpb.RecognitionConfig_AudioEncoding
+}
+
+var audioOutputFormats = map[string]audioOutputFormat{
+	AudioFormatMP3: {"audio/mpeg", "mp3", "libmp3lame", transcribeTypes.MediaFormatMp3, speechpb.RecognitionConfig_MP3},
+	AudioFormatOgg: {"audio/ogg", "ogg", "libopus", transcribeTypes.MediaFormatOgg, speechpb.RecognitionConfig_OGG_OPUS},
+	AudioFormatWAV: {"audio/wav", "wav", "pcm_s16le", transcribeTypes.MediaFormatWav, speechpb.RecognitionConfig_LINEAR16},
+}
+
+// audioFormatAliases menerima nama yang umum dipakai klien untuk format yang sama
+var audioFormatAliases = map[string]string{
+	"mp3": AudioFormatMP3, "mpeg": AudioFormatMP3, "audio/mpeg": AudioFormatMP3, "audio/mp3": AudioFormatMP3,
+	"ogg": AudioFormatOgg, "opus": AudioFormatOgg, "ogg_opus": AudioFormatOgg, "audio/ogg": AudioFormatOgg, "audio/opus": AudioFormatOgg,
+	"wav": AudioFormatWAV, "wave": AudioFormatWAV, "audio/wav": AudioFormatWAV, "audio/wave": AudioFormatWAV, "audio/x-wav": AudioFormatWAV,
+}
+
+// ValidAudioFormat memeriksa nilai konfigurasi format keluaran
+func ValidAudioFormat(format string) bool {
+	_, ok := audioOutputFormats[format]
+	return ok
+}
+
+// NegotiateAudioFormat memilih format balasan: parameter audio_format bila ada, lalu
+// tipe audio yang dikenali dengan bobot q tertinggi di header Accept (urutan header
+// bila bobotnya sama, q=0 berarti ditolak), lalu VOICE_OUTPUT_FORMAT
+func NegotiateAudioFormat(requested, accept string) (string, error) {
+	if requested != "" {
+		format, ok := audioFormatAliases[strings.ToLower(strings.TrimSpace(requested))]
+		if !ok {
+			return "", ErrAudioFormatUnsupported
+		}
+		return format, nil
+	}
+
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(part, ";")
+		format, ok := audioFormatAliases[strings.ToLower(strings.TrimSpace(mediaType))]
+		if !ok {
+			continue
+		}
+		if q := acceptQuality(params); q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	if best != "" {
+		return best, nil
+	}
+	return config.VoiceOutputFormat, nil
+}
+
+// acceptQuality membaca parameter q dari satu entri Accept; tanpa q bobotnya 1
+func acceptQuality(params string) float64 {
+	for _, p := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(p), "=")
+		if strings.EqualFold(name, "q") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 {
+				return 0
+			}
+			return min(q, 1)
+		}
+	}
+	return 1
+}
+
+// AudioFormatOf mengembalikan format dari ekstensi nama berkas atau URL
+func AudioFormatOf(name string) string {
+	return strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+}
+
+// AudioContentType mengembalikan Content-Type untuk nama berkas audio
+func AudioContentType(name string) string {
+	format := AudioFormatOf(name)
+	if f, ok := audioOutputFormats[format]; ok {
+		return f.contentType
+	}
+	if ct, ok := audioInputContentTypes[format]; ok {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// transcodeOptionsFor menyiapkan konversi ke format keluaran
+func transcodeOptionsFor(format string) (TranscodeOptions, error) {
+	f, ok := audioOutputFormats[format]
+	if !ok {
+		return TranscodeOptions{}, fmt.Errorf("%w: %s", ErrAudioFormatUnsupported, format)
+	}
+	return TranscodeOptions{Format: f.ffmpeg, Codec: f.codec}, nil
+}
+
+// ==== Deteksi format masukan ====
+
+// Format rekaman yang diterima dari klien, dikenali dari isi berkas
+const (
+	InputFormatWebM = "webm" // MediaRecorder Chrome/Firefox (Opus)
+	InputFormatOgg  = "ogg"  // Ogg Opus/Vorbis, termasuk voice note WhatsApp
+	InputFormatWAV  = "wav"
+	InputFormatM4A  = "m4a" // MP4/AAC dari iOS dan Safari
+	InputFormatAAC  = "aac" // AAC ADTS tanpa container
+	InputFormatAMR  = "amr" // AMR-NB/WB dari perekam ponsel
+	InputFormatMP3  = "mp3"
+)
+
+// audioSniffBytes adalah jumlah byte awal yang cukup untuk mengenali format
+const audioSniffBytes = 16
+
+var audioInputContentTypes = map[string]string{
+	InputFormatWebM: "audio/webm",
+	InputFormatM4A:  "audio/mp4",
+	InputFormatAAC:  "audio/aac",
+	InputFormatAMR:  "audio/amr",
+}
+
+// SniffAudioFormat mengenali format rekaman dari byte awalnya; ekstensi dan
+// Content-Type dari klien tidak dipercaya. Mengembalikan "" bila tidak dikenali.
+func SniffAudioFormat(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return InputFormatWebM
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return InputFormatOgg
+	case len(head) >= 12 && bytes.HasPrefix(head, []byte("RIFF")) && string(head[8:12]) == "WAVE":
+		return InputFormatWAV
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		return InputFormatM4A
+	case bytes.HasPrefix(head, []byte("#!AMR")):
+		return InputFormatAMR
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return InputFormatMP3
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xF6 == 0xF0:
+		// Sinkronisasi ADTS: layer selalu 00
+		return InputFormatAAC
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return InputFormatMP3
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"backend-go/config"
+	"errors"
+	"testing"
+)
+
+func TestSniffAudioFormat(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"webm", []byte{0x1A, 0x45, 0xDF, 0xA3, 0x9F, 0x42, 0x86, 0x81}, InputFormatWebM},
+		{"ogg", []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00"), InputFormatOgg},
+		{"wav", []byte("RIFF\x24\x08\x00\x00WAVEfmt "), InputFormatWAV},
+		{"riff bukan wav", []byte("RIFF\x24\x08\x00\x00AVI LIST"), ""},
+		{"m4a", []byte("\x00\x00\x00\x20ftypM4A \x00\x00\x00\x00"), InputFormatM4A},
+		{"aac adts", []byte{0xFF, 0xF1, 0x50, 0x80, 0x02, 0x1F, 0xFC}, InputFormatAAC},
+		{"aac adts mpeg-2", []byte{0xFF, 0xF9, 0x50, 0x80}, InputFormatAAC},
+		{"amr-nb", []byte("#!AMR\n\x3C"), InputFormatAMR},
+		{"amr-wb", []byte("#!AMR-WB\n"), InputFormatAMR},
+		{"mp3 dengan id3", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), InputFormatMP3},
+		{"mp3 tanpa id3", []byte{0xFF, 0xFB, 0x90, 0x64}, InputFormatMP3},
+		{"mp3 mpeg-2", []byte{0xFF, 0xF3, 0x48, 0xC4}, InputFormatMP3},
+		{"teks", []byte("halo, ini bukan audio"), ""},
+		{"png", []byte("\x89PNG\r\n\x1a\n"), ""},
+		{"terlalu pendek", []byte{0xFF}, ""},
+		{"kosong", nil, ""},
+	} {
+		if got := SniffAudioFormat(tc.head); got != tc.want {
+			t.Errorf("%s: SniffAudioFormat = %q, ingin %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNegotiateAudioFormat(t *testing.T) {
+	old := config.VoiceOutputFormat
+	config.VoiceOutputFormat = AudioFormatMP3
+	t.Cleanup(func() { config.VoiceOutputFormat = old })
+
+	for _, tc := range []struct {
+		name      string
+		requested string
+		accept    string
+		want      string
+		err       error
+	}{
+		{"parameter menang atas Accept", "ogg", "audio/wav", AudioFormatOgg, nil},
+		{"alias parameter", " OPUS ", "", AudioFormatOgg, nil},
+		{"alias wave", "wave", "", AudioFormatWAV, nil},
+		{"parameter tidak didukung", "flac", "audio/ogg", "", ErrAudioFormatUnsupported},
+		{"Accept pertama yang dikenali", "", "application/json, audio/ogg, audio/wav", AudioFormatOgg, nil},
+		{"Accept dengan bobot q", "", "audio/wav;q=0.5, audio/ogg;q=0.9", AudioFormatOgg, nil},
+		{"Accept q=0 ditolak", "", "audio/ogg;q=0, audio/wav;q=0.1", AudioFormatWAV, nil},
+		{"Accept tanpa format dikenali", "", "audio/*, */*", AudioFormatMP3, nil},
+		{"Accept x-wav huruf besar", "", "Audio/X-WAV", AudioFormatWAV, nil},
+		{"tanpa preferensi", "", "", AudioFormatMP3, nil},
+	} {
+		got, err := NegotiateAudioFormat(tc.requested, tc.accept)
+		if !errors.Is(err, tc.err) || got != tc.want {
+			t.Errorf("%s: NegotiateAudioFormat = %q, %v; ingin %q, %v", tc.name, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+func TestAudioFormatTargets(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		muxer       string
+	}{
+		{"balasan.mp3", "audio/mpeg", "mp3"},
+		{"balasan.ogg", "audio/ogg", "ogg"},
+		{"balasan.wav", "audio/wav", "wav"},
+		{"rekaman.webm", "audio/webm", ""},
+		{"rekaman.m4a", "audio/mp4", ""},
+		{"rekaman.amr", "audio/amr", ""},
+		{"berkas.bin", "application/octet-stream", ""},
+	} {
+		if got := AudioContentType(tc.name); got != tc.contentType {
+			t.Errorf("AudioContentType(%s) = %q, ingin %q", tc.name, got, tc.contentType)
+		}
+		opts, err := transcodeOptionsFor(AudioFormatOf(tc.name))
+		if tc.muxer == "" {
+			if !errors.Is(err, ErrAudioFormatUnsupported) {
+				t.Errorf("transcodeOptionsFor(%s): err = %v, ingin ErrAudioFormatUnsupported", tc.name, err)
+			}
+			continue
+		}
+		if err != nil || opts.Format != tc.muxer {
+			t.Errorf("transcodeOptionsFor(%s) = %+v, %v", tc.name, opts, err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"backend-go/config"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNoSpeech = errors.New("tidak ada suara yang terdeteksi dalam rekaman, silakan rekam ulang lebih dekat ke mikrofon")
+
+// vadMinSilence adalah jeda terpendek yang dihitung sebagai diam, agar jeda antarkata
+// tetap terhitung sebagai ucapan
+const vadMinSilence = 0.3
+
+// trimKeepSilence adalah sisa jeda (detik) di awal dan akhir setelah pemangkasan
+const trimKeepSilence = 0.2
+
+// SpeechAnalysis adalah hasil deteksi aktivitas suara pada satu rekaman
+type SpeechAnalysis struct {
+	Total  time.Duration
+	Speech time.Duration
+}
+
+// PreprocessVoice membersihkan rekaman sebelum STT: reduksi derau (opsional), deteksi
+// aktivitas suara, pemangkasan diam di awal/akhir, dan normalisasi loudness. Setiap
+// langkah diatur lewat konfigurasi VOICE_*. Rekaman tanpa ucapan yang cukup ditolak
+// dengan ErrNoSpeech. format adalah format audio masukan sekaligus keluaran.
+func PreprocessVoice(ctx context.Context, audio []byte, format string) ([]byte, *SpeechAnalysis, error) {
+	var denoise string
+	if config.VoiceDenoise {
+		denoise = fmt.Sprintf("afftdn=nf=%g", config.VoiceDenoiseFloor)
+	}
+
+	var analysis *SpeechAnalysis
+	if config.VoiceVADEnabled {
+		filter := joinFilters(denoise, fmt.Sprintf("silencedetect=noise=%gdB:d=%g", config.VoiceVADNoiseDB, vadMinSilence))
+		log, err := transcoder.Analyze(ctx, audio, filter)
+		if err != nil {
+			return nil, nil, err
+		}
+		if analysis, err = parseSilenceLog(log); err != nil {
+			return nil, nil, err
+		}
+		if analysis.Speech < config.VoiceVADMinSpeech {
+			return nil, analysis, ErrNoSpeech
+		}
+	}
+
+	var trim, loudnorm string
+	if config.VoiceTrimSilence {
+		// silenceremove hanya memangkas awal; akhir dipangkas dengan membalik audio
+		edge := fmt.Sprintf("silenceremove=start_periods=1:start_threshold=%gdB:start_silence=%g",
+			config.VoiceVADNoiseDB, trimKeepSilence)
+		trim = joinFilters(edge, "areverse", edge, "areverse")
+	}
+	if config.VoiceLoudnorm {
+		loudnorm = fmt.Sprintf("loudnorm=I=%g:TP=-1.5:LRA=11", config.VoiceLoudnormTarget)
+	}
+
+	filter := joinFilters(denoise, trim, loudnorm)
+	if filter == "" {
+		return audio, analysis, nil
+	}
+
+	opts, err := transcodeOptionsFor(format)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts.SampleRate = voiceSampleRate
+	opts.Channels = 1
+	opts.Filter = filter
+	processed, err := transcoder.Transcode(ctx, audio, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return processed, analysis, nil
+}
+
+func joinFilters(filters ...string) string {
+	var parts []string
+	for _, f := range filters {
+		if f != "" {
+			parts = append(parts, f)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+var (
+	silenceStartPattern = regexp.MustCompile(`silence_start: (-?[\d.]+)`)
+	silenceEndPattern   = regexp.MustCompile(`silence_end: ([\d.]+)`)
+	ffmpegTimePattern   = regexp.MustCompile(`time=(\d+):(\d+):([\d.]+)`)
+	ffmpegInputDuration = regexp.MustCompile(`Duration: (\d+):(\d+):([\d.]+)`)
+)
+
+// parseSilenceLog menghitung durasi ucapan dari log silencedetect: total durasi
+// dikurangi semua rentang diam. Diam yang belum berakhir dihitung sampai akhir audio.
+func parseSilenceLog(log string) (*SpeechAnalysis, error) {
+	total, ok := lastTimestamp(ffmpegTimePattern, log)
+	if !ok {
+		if total, ok = lastTimestamp(ffmpegInputDuration, log); !ok {
+			return nil, errors.New("durasi audio tidak dapat dibaca dari log ffmpeg")
+		}
+	}
+
+	var silence, openStart float64
+	open := false
+	for _, line := range strings.Split(log, "\n") {
+		if m := silenceStartPattern.FindStringSubmatch(line); m != nil {
+			openStart, _ = strconv.ParseFloat(m[1], 64)
+			open = true
+		} else if m := silenceEndPattern.FindStringSubmatch(line); m != nil && open {
+			end, _ := strconv.ParseFloat(m[1], 64)
+			silence += end - max(openStart, 0)
+			open = false
+		}
+	}
+	if open {
+		silence += total - max(openStart, 0)
+	}
+
+	speech := max(total-silence, 0)
+	return &SpeechAnalysis{
+		Total:  time.Duration(total * float64(time.Second)),
+		Speech: time.Duration(speech * float64(time.Second)),
+	}, nil
+}
+
+// lastTimestamp membaca kemunculan terakhir HH:MM:SS.xx dalam detik
+func lastTimestamp(pattern *regexp.Regexp, log string) (float64, bool) {
+	matches := pattern.FindAllStringSubmatch(log, -1)
+	if len(matches) == 0 {
+		return 0, false
+	}
+	m := matches[len(matches)-1]
+	h, _ := strconv.ParseFloat(m[1], 64)
+	minutes, _ := strconv.ParseFloat(m[2], 64)
+	sec, _ := strconv.ParseFloat(m[3], 64)
+	return h*3600 + minutes*60 + sec, true
+}
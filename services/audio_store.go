@@ -0,0 +1,153 @@
+package services
+
+import (
+	"backend-go/config"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrAudioNotFound = errors.New("file audio tidak ditemukan")
+
+// Audio disimpan di S3 bila AWS dikonfigurasi (s3Client terisi), jika tidak di
+// AUDIO_LOCAL_DIR dan disajikan lewat GET /voice/audio/:filename. Key memakai bentuk
+// S3 (user/..., bot/cache/...); di disk garis miring diganti "-" karena rute audio
+// hanya menerima nama berkas.
+
+// localAudioURLPrefix adalah rute ServeAudioFile
+const localAudioURLPrefix = "/voice/audio/"
+
+// StoreUserVoice menyimpan rekaman pengguna untuk riwayat chat
+func StoreUserVoice(fileName string, audio []byte) (string, error) {
+	if s3Client != nil {
+		return UploadUserVoiceToS3(fileName, audio)
+	}
+	return storeLocalAudio("user/"+fileName, audio)
+}
+
+// StoreBotVoice menyimpan audio balasan bot
+func StoreBotVoice(fileName string, audio []byte) (string, error) {
+	if s3Client != nil {
+		return UploadBotVoiceToS3(fileName, audio)
+	}
+	return storeLocalAudio("bot/"+fileName, audio)
+}
+
+func localAudioPath(key string) string {
+	return filepath.Join(config.AudioLocalDir, strings.ReplaceAll(key, "/", "-"))
+}
+
+func storeLocalAudio(key string, audio []byte) (string, error) {
+	if err := os.MkdirAll(config.AudioLocalDir, 0o755); err != nil {
+		return "", fmt.Errorf("gagal membuat direktori audio lokal: %v", err)
+	}
+	// Ditulis ke berkas sementara lalu di-rename agar pembaca tidak melihat berkas setengah jadi
+	dst := localAudioPath(key)
+	tmp, err := os.CreateTemp(config.AudioLocalDir, ".audio-*")
+	if err != nil {
+		return "", fmt.Errorf("gagal menyimpan audio lokal: %v", err)
+	}
+	_, err = tmp.Write(audio)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("gagal menyimpan audio lokal: %v", err)
+	}
+	return audioObjectURL(key), nil
+}
+
+// audioObjectExists memeriksa keberadaan audio pada penyimpanan aktif
+func audioObjectExists(ctx context.Context, key string) (bool, error) {
+	if s3Client == nil {
+		_, err := os.Stat(localAudioPath(key))
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	_, err := s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(config.AWSBucketName),
+		Key:    aws.String(key),
+	})
+	var notFound *s3Types.NotFound
+	if errors.As(err, &notFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func readAudioObject(ctx context.Context, key string) ([]byte, error) {
+	if s3Client == nil {
+		return os.ReadFile(localAudioPath(key))
+	}
+	out, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(config.AWSBucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
+
+func audioObjectURL(key string) string {
+	if s3Client == nil {
+		return localAudioURLPrefix + filepath.Base(localAudioPath(key))
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", config.AWSBucketName, config.AWSRegion, key)
+}
+
+// localAudioFromURL mengubah URL audio lokal menjadi path berkas; URL lain diabaikan
+func localAudioFromURL(raw string) (string, bool) {
+	name, ok := strings.CutPrefix(raw, localAudioURLPrefix)
+	if !ok || name == "" || name != filepath.Base(name) {
+		return "", false
+	}
+	return filepath.Join(config.AudioLocalDir, name), true
+}
+
+// OpenLocalAudio membuka berkas audio lokal milik userID. Berkas hanya disajikan bila
+// ada pesan pengguna itu yang merujuk URL-nya; selain itu ErrAudioNotFound agar
+// keberadaan rekaman pengguna lain tidak bocor.
+func OpenLocalAudio(filename string, userID int) (*os.File, error) {
+	if filename == "" || filename != filepath.Base(filename) || strings.HasPrefix(filename, ".") {
+		return nil, ErrAudioNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := config.MongoDB.Collection("messages").FindOne(ctx,
+		bson.M{"audio.url": localAudioURLPrefix + filename, "user_id": userID},
+		options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrAudioNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filepath.Join(config.AudioLocalDir, filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrAudioNotFound
+	}
+	return file, err
+}
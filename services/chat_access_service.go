@@ -0,0 +1,92 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrChatNotFound  = errors.New("chat tidak ditemukan")
+	ErrChatForbidden = errors.New("chat ini bukan milik pengguna")
+)
+
+// NewChatID membuat ID chat acak (format UUID v4) di sisi server
+func NewChatID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// CreateConversation membuat percakapan kosong dengan chat ID dari server
+func CreateConversation(userID int, username, title string) (*models.Conversation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	chatID, err := NewChatID()
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuat chat ID: %v", err)
+	}
+
+	now := time.Now()
+	if title == "" {
+		title = fmt.Sprintf("Percakapan pada %s", now.Format("2 January 2006 15:04"))
+	}
+
+	convo := models.Conversation{
+		ChatID:    chatID,
+		UserID:    userID,
+		Username:  username,
+		ChatTitle: title,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if _, err := config.MongoDB.Collection("conversations").InsertOne(ctx, convo); err != nil {
+		return nil, err
+	}
+
+	return &convo, nil
+}
+
+// CheckChatOwnership memastikan chat ada dan dimiliki userID.
+// Mengembalikan ErrChatNotFound (404) atau ErrChatForbidden (403).
+func CheckChatOwnership(ctx context.Context, chatID string, userID int) error {
+	if chatID == "" {
+		return ErrChatNotFound
+	}
+
+	var owner struct {
+		UserID    int        `bson:"user_id"`
+		DeletedAt *time.Time `bson:"deleted_at"`
+	}
+	opts := options.FindOne().SetProjection(bson.M{"user_id": 1, "deleted_at": 1})
+	err := config.MongoDB.Collection("conversations").FindOne(ctx, bson.M{"chat_id": chatID}, opts).Decode(&owner)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrChatNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if owner.UserID != userID {
+		return ErrChatForbidden
+	}
+	// Chat di tong sampah hanya dapat diakses lewat endpoint trash
+	if owner.DeletedAt != nil {
+		return ErrChatNotFound
+	}
+	return nil
+}
@@ -10,10 +10,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"sort"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -23,26 +23,38 @@ type ChatbotService struct {
 }
 
 type ChatListItem struct {
-	ChatID    string    `json:"chat_id"`
-	ChatTitle string    `json:"chat_title"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ChatID      string                 `json:"chat_id"`
+	ChatTitle   string                 `json:"chat_title"`
+	CreatedAt   time.Time              `json:"created_at"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+	LastMessage *models.MessagePreview `json:"last_message,omitempty"`
+	Pinned      bool                   `json:"pinned"`
+	Archived    bool                   `json:"archived"`
+	Starred     bool                   `json:"starred"`
+	Labels      []models.Label         `json:"labels"`
 }
 
-type ChatMessageResponse struct {
-	Sender     string    `json:"sender"`               // "user" atau "bot"
-	Type       string    `json:"type"`                 // "text" atau "voice"
-	Message    string    `json:"message,omitempty"`    // Hanya untuk text
-	AudioURL   string    `json:"audio_url,omitempty"`  // Hanya untuk voice
-	Transcript string    `json:"transcript,omitempty"` // Hanya untuk voice
-	Intent     string    `json:"intent,omitempty"`
-	Timestamp  time.Time `json:"timestamp"`
+// ChatListFilter adalah filter daftar chat; nil berarti tidak difilter.
+// Archived nil menampilkan chat yang tidak diarsipkan saja.
+type ChatListFilter struct {
+	Pinned      *bool
+	Archived    *bool
+	AllArchived bool
+	Starred     *bool
+	LabelID     string
+	Sort        string
 }
 
 // Fungsi utama untuk memproses pesan user
 func ProcessChatbot(chatID string, userMessage string, userID int, username string) (*models.ChatbotResponse, error) {
 	startTime := time.Now()
 
+	// Filter kata kasar dan spam sebelum pesan diteruskan ke NLP
+	moderation := ModerateMessage(userID, userMessage)
+	if moderation.Action == ModerationReject {
+		return nil, &ErrMessageRejected{Reasons: moderation.Reasons}
+	}
+
 	// Panggil layanan NLP (Flask)
 	nlpResp, err := CallNLPService(userMessage)
 	if err != nil {
@@ -50,28 +62,32 @@ func ProcessChatbot(chatID string, userMessage string, userID int, username stri
 	}
 
 	userMsg := models.Message{
+		Modality:  models.ModalityText,
 		Sender:    "user",
-		Message:   userMessage,
-		Timestamp: startTime.Format(time.RFC3339),
+		Content:   userMessage,
+		Timestamp: startTime,
+	}
+	if moderation.Action == ModerationFlag {
+		userMsg.Flags = moderation.Reasons
 	}
 
 	botMsg := models.Message{
+		Modality:   models.ModalityText,
 		Sender:     "bot",
-		Message:    nlpResp.ResponseMessage,
+		Content:    nlpResp.ResponseMessage,
 		Intent:     nlpResp.Intent,
 		Confidence: nlpResp.Confidence,
-		Timestamp:  time.Now().Format(time.RFC3339),
+		Timestamp:  time.Now(),
 	}
+
+	// Masking PII sebelum pesan disimpan
+	ProtectMessage(&userMsg)
+	ProtectMessage(&botMsg)
+
 	// Simpan ke MongoDB
 	err = SaveToMongo(chatID, userID, username, userMsg, botMsg)
 	if err != nil {
-		return nil, fmt.Errorf("gagal menyimpan ke MongoDB: %v", err)
-	}
-
-	// Update last_chat_id ke PostgreSQL
-	if err := UpdateLastChatID(userID, chatID); err != nil {
-		fmt.Println("Gagal update last_chat_id:", err)
-		// kamu bisa log tapi tidak harus menghentikan proses jika error
+		return nil, fmt.Errorf("gagal menyimpan ke MongoDB: %w", err)
 	}
 
 	// Buat respons ke frontend
@@ -85,54 +101,163 @@ func ProcessChatbot(chatID string, userMessage string, userID int, username stri
 	}, nil
 }
 
+// ReserveMessageSeq memesan n nomor urut pesan untuk chat secara atomik lewat satu upsert,
+// sekaligus memperbarui pratinjau pesan terakhir dan mencatat item outbox. Dokumen
+// percakapan dibuat jika belum ada; chat_id milik user lain ditolak oleh indeks unik.
+func ReserveMessageSeq(ctx context.Context, chatID string, userID int, username string, n int64, preview *models.MessagePreview, outbox []models.OutboxItem) (int64, error) {
+	now := time.Now()
+
+	set := bson.M{"updated_at": now}
+	filter := bson.M{"chat_id": chatID, "user_id": userID}
+	update := bson.M{
+		"$inc": bson.M{"message_count": n},
+		"$set": set,
+		"$setOnInsert": bson.M{
+			"username":   username,
+			"chat_title": fmt.Sprintf("Percakapan pada %s", now.Format("2 January 2006 15:04")),
+			"created_at": now,
+			"pinned":     false,
+			"archived":   false,
+			"starred":    false,
+		},
+	}
+	if len(outbox) > 0 {
+		update["$push"] = bson.M{"outbox": bson.M{"$each": outbox}}
+	}
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After).
+		SetProjection(bson.M{"message_count": 1})
+
+	var convo models.Conversation
+	err := config.MongoDB.Collection("conversations").FindOneAndUpdate(ctx, filter, update, opts).Decode(&convo)
+	if mongo.IsDuplicateKeyError(err) {
+		return 0, ErrChatForbidden
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	first := convo.MessageCount - n + 1
+
+	// Pratinjau ditulis terpisah dengan syarat seq agar penulis yang lebih lambat tidak menimpa yang lebih baru
+	if preview != nil {
+		preview.Seq = convo.MessageCount
+		_, err := config.MongoDB.Collection("conversations").UpdateOne(ctx,
+			bson.M{
+				"chat_id": chatID,
+				"$or": bson.A{
+					bson.M{"last_message": bson.M{"$exists": false}},
+					bson.M{"last_message.seq": bson.M{"$lt": preview.Seq}},
+				},
+			},
+			bson.M{"$set": bson.M{"last_message": preview}},
+		)
+		if err != nil {
+			return 0, fmt.Errorf("gagal memperbarui pratinjau pesan terakhir: %w", err)
+		}
+	}
+
+	return first, nil
+}
+
+// NewMessagePreview membuat pratinjau pesan yang dipotong agar dokumen percakapan tetap kecil
+func NewMessagePreview(sender, text string, ts time.Time) *models.MessagePreview {
+	const maxPreviewRunes = 120
+	if r := []rune(text); len(r) > maxPreviewRunes {
+		text = string(r[:maxPreviewRunes]) + "…"
+	}
+	return &models.MessagePreview{Sender: sender, Text: text, Timestamp: ts}
+}
+
 func SaveToMongo(chatID string, userID int, username string, userMsg, botMsg models.Message) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	collection := config.MongoDB.Collection("conversations")
+	return SaveMessages(ctx, chatID, userID, username, &userMsg, &botMsg)
+}
 
-	filter := bson.M{"chat_id": chatID}
-	var existing bson.M
-	err := collection.FindOne(ctx, filter).Decode(&existing)
+// SaveMessages adalah jalur tulis tunggal untuk semua modality: memesan seq,
+// memberi ID tetap, lalu menyimpan pesan ke collection messages. Pemesanan seq,
+// pratinjau, outbox, dan penyisipan pesan berjalan dalam satu transaksi sehingga
+// kegagalan di tengah jalan tidak meninggalkan lubang seq atau outbox tanpa pesan.
+func SaveMessages(ctx context.Context, chatID string, userID int, username string, msgs ...*models.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
 
-	now := time.Now()
+	for _, msg := range msgs {
+		if msg.ID.IsZero() {
+			msg.ID = primitive.NewObjectID()
+		}
+		if msg.Modality == "" {
+			msg.Modality = models.ModalityText
+		}
+	}
 
+	// Efek samping (last_chat_id, webhook) dicatat atomik bersama pemesanan seq
+	outbox, err := messageOutboxItems(chatID, userID, msgs)
 	if err != nil {
-		// Dokumen baru
-		convo := models.Conversation{
-			ChatID:    chatID,
-			UserID:    userID,
-			Username:  username,
-			ChatTitle: fmt.Sprintf("Percakapan pada %s", now.Format("2 January 2006 15:04")),
-			Messages:  []models.Message{userMsg, botMsg},
-			CreatedAt: now,
-			UpdatedAt: now,
+		return err
+	}
+
+	last := msgs[len(msgs)-1]
+	err = withMongoTransaction(ctx, func(ctx context.Context) error {
+		first, err := ReserveMessageSeq(ctx, chatID, userID, username, int64(len(msgs)),
+			NewMessagePreview(last.Sender, last.Text(), last.Timestamp), outbox)
+		if err != nil {
+			return err
 		}
 
-		_, insertErr := collection.InsertOne(ctx, convo)
-		return insertErr
+		docs := make([]interface{}, 0, len(msgs))
+		for i, msg := range msgs {
+			// Balasan bot dalam satu batch otomatis menunjuk pesan user sebelumnya
+			if msg.Sender == "bot" && msg.ReplyTo == nil && i > 0 && msgs[i-1].Sender == "user" {
+				msg.ReplyTo = &msgs[i-1].ID
+			}
+			msg.ChatID = chatID
+			msg.UserID = userID
+			msg.Seq = first + int64(i)
+			msg.SearchText = BuildSearchText(msg.Text())
+			docs = append(docs, msg)
+		}
+
+		_, err = config.MongoDB.Collection("messages").InsertMany(ctx, docs)
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
-	// Jika sudah ada, push message baru dan update waktu
-	update := bson.M{
-		"$push": bson.M{
-			"messages": bson.M{
-				"$each": []models.Message{userMsg, botMsg},
-			},
-		},
-		"$set": bson.M{
-			"updated_at": now,
-		},
+	indexMessages(msgs)
+	kickOutbox()
+	return nil
+}
+
+// withMongoTransaction menjalankan fn dalam satu transaksi MongoDB; konflik tulis
+// sementara diulang otomatis oleh driver. Pada server standalone fn dijalankan
+// langsung tanpa transaksi.
+func withMongoTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !config.MongoTransactions {
+		return fn(ctx)
 	}
+	session, err := config.MongoClient.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
 
-	_, updateErr := collection.UpdateOne(ctx, filter, update)
-	return updateErr
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
 }
 
 func UpdateLastChatID(userID int, chatID string) error {
 	db := config.DB
 
-	query := `UPDATE users SET last_chat_id = $1 WHERE id = $2`
+	// Pilihan user dicatat dengan waktu sekarang agar outbox pesan lama tidak menimpanya
+	query := `UPDATE users SET last_chat_id = $1, last_chat_at = NOW() WHERE id = $2`
 	_, err := db.Exec(query, chatID, userID)
 	if err != nil {
 		return fmt.Errorf("gagal update last_chat_id di PostgreSQL: %v", err)
@@ -140,11 +265,17 @@ func UpdateLastChatID(userID int, chatID string) error {
 	return nil
 }
 
-func GetChatByID(chatID string, userID int) (*models.Conversation, error) {
+// ChatDetail berisi metadata percakapan dan satu halaman pesan terbaru
+type ChatDetail struct {
+	models.Conversation
+	Messages Page[models.Message] `json:"messages"`
+}
+
+func GetChatByID(chatID string, userID int) (*ChatDetail, error) {
 	var convo models.Conversation
 	err := config.MongoDB.Collection("conversations").FindOne(
 		context.TODO(),
-		bson.M{"chat_id": chatID, "user_id": userID},
+		bson.M{"chat_id": chatID, "user_id": userID, "deleted_at": notDeleted},
 	).Decode(&convo)
 
 	if err != nil {
@@ -154,143 +285,147 @@ func GetChatByID(chatID string, userID int) (*models.Conversation, error) {
 		return nil, err // Error lainnya (koneksi, dsb)
 	}
 
-	return &convo, nil
-}
-
-func FetchUserChatList(userID int) ([]ChatListItem, error) {
-	collection := config.MongoDB.Collection("conversations")
-
-	filter := bson.M{"user_id": userID}
-	opts := options.Find().SetSort(bson.M{"updated_at": -1}) // urut terbaru duluan
-
-	cursor, err := collection.Find(context.Background(), filter, opts)
+	messages, err := ListMessages(chatID, userID, "", PageRequest{})
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(context.Background())
 
-	var results []ChatListItem
-	for cursor.Next(context.Background()) {
-		var conv models.Conversation
-		if err := cursor.Decode(&conv); err != nil {
-			continue
-		}
+	return &ChatDetail{Conversation: convo, Messages: messages}, nil
+}
 
-		results = append(results, ChatListItem{
-			ChatID:    conv.ChatID,
-			ChatTitle: conv.ChatTitle,
-			UpdatedAt: conv.UpdatedAt,
-		})
-	}
-	if err := cursor.Err(); err != nil {
-		return nil, err
+// ListMessages mengambil satu halaman pesan sebuah chat; modality kosong berarti semua
+func ListMessages(chatID string, userID int, modality string, req PageRequest) (Page[models.Message], error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"chat_id": chatID, "user_id": userID}
+	if modality != "" {
+		filter["modality"] = modality
 	}
 
-	return results, nil
+	return PageMessages(ctx, filter, req)
 }
 
-func RenameChatTitle(chatID string, userID int, newTitle string) error {
+// FetchUserChatList mengambil daftar chat user per halaman. Chat yang disematkan selalu
+// tampil lebih dulu, lalu diurutkan sesuai filter.Sort (default: terbaru lebih dulu).
+func FetchUserChatList(userID int, filter ChatListFilter, req PageRequest) (Page[ChatListItem], error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	collection := config.MongoDB.Collection("conversations")
+	query := bson.M{"user_id": userID, "deleted_at": notDeleted}
+	if filter.Pinned != nil {
+		query["pinned"] = boolFilter(*filter.Pinned)
+	}
+	if filter.Starred != nil {
+		query["starred"] = boolFilter(*filter.Starred)
+	}
+	switch {
+	case filter.AllArchived:
+	case filter.Archived != nil:
+		query["archived"] = boolFilter(*filter.Archived)
+	default:
+		query["archived"] = boolFilter(false)
+	}
+	if filter.LabelID != "" {
+		labelID, err := primitive.ObjectIDFromHex(filter.LabelID)
+		if err != nil {
+			return Page[ChatListItem]{}, ErrLabelNotFound
+		}
+		query["labels"] = labelID
+	}
 
-	filter := bson.M{"chat_id": chatID, "user_id": userID}
-	update := bson.M{"$set": bson.M{"chat_title": newTitle}}
+	convos, err := PageConversationsSorted(ctx, query, ConversationSort{By: filter.Sort, PinnedFirst: true}, req)
+	if err != nil {
+		return Page[ChatListItem]{}, err
+	}
 
-	result, err := collection.UpdateOne(ctx, filter, update)
+	labels, err := userLabelMap(ctx, userID)
 	if err != nil {
-		return err
+		return Page[ChatListItem]{}, err
 	}
 
-	if result.MatchedCount == 0 {
-		return errors.New("chat tidak ditemukan atau tidak diizinkan")
+	page := Page[ChatListItem]{
+		Data:       make([]ChatListItem, 0, len(convos.Data)),
+		NextCursor: convos.NextCursor,
+		PrevCursor: convos.PrevCursor,
+		HasMore:    convos.HasMore,
+	}
+	for _, conv := range convos.Data {
+		page.Data = append(page.Data, newChatListItem(conv, labels))
 	}
 
-	return nil
+	return page, nil
+}
+
+func newChatListItem(conv models.Conversation, labels map[primitive.ObjectID]models.Label) ChatListItem {
+	item := ChatListItem{
+		ChatID:      conv.ChatID,
+		ChatTitle:   conv.ChatTitle,
+		CreatedAt:   conv.CreatedAt,
+		UpdatedAt:   conv.UpdatedAt,
+		LastMessage: conv.LastMessage,
+		Pinned:      conv.Pinned,
+		Archived:    conv.Archived,
+		Starred:     conv.Starred,
+		Labels:      []models.Label{},
+	}
+	for _, id := range conv.Labels {
+		if label, ok := labels[id]; ok {
+			item.Labels = append(item.Labels, label)
+		}
+	}
+	return item
+}
+
+// boolFilter mencocokkan false juga untuk dokumen lama yang belum memiliki field tersebut
+func boolFilter(v bool) interface{} {
+	if v {
+		return true
+	}
+	return bson.M{"$ne": true}
 }
 
-func DeleteChat(chatID string, userID int) error {
+func RenameChatTitle(chatID string, userID int, newTitle string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	collection := config.MongoDB.Collection("conversations")
-	filter := bson.M{"chat_id": chatID, "user_id": userID}
 
-	result, err := collection.DeleteOne(ctx, filter)
+	filter := bson.M{"chat_id": chatID, "user_id": userID, "deleted_at": notDeleted}
+	update := bson.M{"$set": bson.M{"chat_title": newTitle}}
+
+	result, err := collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return err
 	}
 
-	if result.DeletedCount == 0 {
+	if result.MatchedCount == 0 {
 		return errors.New("chat tidak ditemukan atau tidak diizinkan")
 	}
 
 	return nil
 }
 
-func GetFullChatHistory(chatID string, userID int) ([]ChatMessageResponse, error) {
+// GetFullChatHistory mengembalikan seluruh riwayat chat (semua modality) urut seq.
+// Dipakai untuk proses internal; endpoint HTTP memakai ListMessages yang berhalaman.
+func GetFullChatHistory(chatID string, userID int) ([]models.Message, error) {
 	ctx := context.TODO()
 
-	// 1. Ambil data Conversation (text)
-	var convo models.Conversation
-	err := config.MongoDB.Collection("conversations").FindOne(ctx, bson.M{
-		"chat_id": chatID,
-		"user_id": userID,
-	}).Decode(&convo)
-
-	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
-		return nil, err
-	}
-
-	// 2. Ambil data VoiceMessage (suara)
-	cursor, err := config.MongoDB.Collection("voice_messages").Find(ctx, bson.M{
+	cursor, err := config.MongoDB.Collection("messages").Find(ctx, bson.M{
 		"chat_id": chatID,
 		"user_id": userID,
-	})
+	}, options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}))
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var voiceMessages []models.VoiceMessage
-	if err := cursor.All(ctx, &voiceMessages); err != nil {
+	messages := []models.Message{}
+	if err := cursor.All(ctx, &messages); err != nil {
 		return nil, err
 	}
 
-	// 3. Gabungkan dua tipe message
-	var combined []ChatMessageResponse
-
-	// Text messages
-	for _, msg := range convo.Messages {
-		t, _ := time.Parse(time.RFC3339, msg.Timestamp)
-		combined = append(combined, ChatMessageResponse{
-			Sender:    msg.Sender,
-			Type:      "text",
-			Message:   msg.Message,
-			Intent:    msg.Intent,
-			Timestamp: t,
-		})
-	}
-
-	// Voice messages
-	for _, voice := range voiceMessages {
-		combined = append(combined, ChatMessageResponse{
-			Sender:     voice.Sender,
-			Type:       "voice",
-			AudioURL:   voice.AudioURL,
-			Transcript: voice.Transcript,
-			Intent:     voice.Intent,
-			Timestamp:  voice.Timestamp,
-		})
-	}
-
-	// 4. Urutkan berdasarkan timestamp
-	sort.Slice(combined, func(i, j int) bool {
-		return combined[i].Timestamp.Before(combined[j].Timestamp)
-	})
-
-	return combined, nil
+	return messages, nil
 }
 
 // ==== Fungsi untuk panggil NLP Flask ====
@@ -301,6 +436,9 @@ type nlpResponse struct {
 	Confidence      float64 `json:"confidence"`
 }
 
+// nlpServiceURL adalah endpoint layanan NLP Flask; diganti di pengujian
+var nlpServiceURL = "http://localhost:5000/nlp"
+
 func CallNLPService(message string) (*nlpResponse, error) {
 	requestBody, err := json.Marshal(map[string]string{
 		"message": message,
@@ -309,7 +447,7 @@ func CallNLPService(message string) (*nlpResponse, error) {
 		return nil, err
 	}
 
-	resp, err := http.Post("http://localhost:5000/nlp", "application/json", bytes.NewBuffer(requestBody))
+	resp, err := http.Post(nlpServiceURL, "application/json", bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("gagal memanggil layanan NLP: %v", err)
 	}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestSaveMessagesConcurrentSameChat(t *testing.T) {
+	useTestMongo(t)
+
+	const writers = 25
+	chatID := "chat-concurrent"
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	ids := make(chan primitive.ObjectID, writers*2)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			now := time.Now()
+			userMsg := models.Message{Sender: "user", Content: fmt.Sprintf("pesan %d", i), Timestamp: now}
+			botMsg := models.Message{Sender: "bot", Content: fmt.Sprintf("balasan %d", i), Timestamp: now}
+			if err := SaveMessages(ctx, chatID, 7, "budi", &userMsg, &botMsg); err != nil {
+				errs <- err
+				return
+			}
+			ids <- userMsg.ID
+			ids <- botMsg.ID
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	close(ids)
+	for err := range errs {
+		t.Fatalf("SaveMessages gagal: %v", err)
+	}
+
+	conversations := config.MongoDB.Collection("conversations")
+	n, err := conversations.CountDocuments(ctx, bson.M{"chat_id": chatID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("jumlah dokumen percakapan = %d, ingin 1", n)
+	}
+
+	var convo models.Conversation
+	if err := conversations.FindOne(ctx, bson.M{"chat_id": chatID}).Decode(&convo); err != nil {
+		t.Fatal(err)
+	}
+	if convo.MessageCount != writers*2 {
+		t.Errorf("message_count = %d, ingin %d", convo.MessageCount, writers*2)
+	}
+	if convo.LastMessage == nil || convo.LastMessage.Seq != writers*2 {
+		t.Errorf("pratinjau terakhir = %+v, ingin seq %d", convo.LastMessage, writers*2)
+	}
+
+	cursor, err := config.MongoDB.Collection("messages").Find(ctx, bson.M{"chat_id": chatID},
+		options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored []models.Message
+	if err := cursor.All(ctx, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != writers*2 {
+		t.Fatalf("jumlah pesan = %d, ingin %d", len(stored), writers*2)
+	}
+	found := map[primitive.ObjectID]bool{}
+	for i, msg := range stored {
+		if msg.Seq != int64(i+1) {
+			t.Fatalf("seq ke-%d = %d, seq harus berurutan tanpa lubang", i, msg.Seq)
+		}
+		found[msg.ID] = true
+	}
+	for id := range ids {
+		if !found[id] {
+			t.Errorf("pesan %s hilang", id.Hex())
+		}
+	}
+}
+
+func TestSaveMessagesRejectsOtherUsersChat(t *testing.T) {
+	useTestMongo(t)
+	ctx := context.Background()
+
+	first := models.Message{Sender: "user", Content: "halo", Timestamp: time.Now()}
+	if err := SaveMessages(ctx, "chat-owned", 1, "a", &first); err != nil {
+		t.Fatal(err)
+	}
+	intruder := models.Message{Sender: "user", Content: "halo juga", Timestamp: time.Now()}
+	if err := SaveMessages(ctx, "chat-owned", 2, "b", &intruder); !errors.Is(err, ErrChatForbidden) {
+		t.Fatalf("err = %v, ingin ErrChatForbidden", err)
+	}
+
+	n, err := config.MongoDB.Collection("messages").CountDocuments(ctx, bson.M{"chat_id": "chat-owned"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("jumlah pesan = %d, pesan user lain tidak boleh tersimpan", n)
+	}
+}
+
+func TestEnsureMongoIndexesRequiresChatIDUnique(t *testing.T) {
+	useTestMongo(t)
+	ctx := context.Background()
+	convos := config.MongoDB.Collection("conversations")
+
+	if _, err := convos.Indexes().DropOne(ctx, "chat_id_unique"); err != nil {
+		t.Fatal(err)
+	}
+	for _, userID := range []int{1, 2} {
+		if _, err := convos.InsertOne(ctx, models.Conversation{ChatID: "chat-ganda", UserID: userID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := config.EnsureMongoIndexes(); !errors.Is(err, config.ErrRequiredMongoIndex) {
+		t.Errorf("err = %v, ingin ErrRequiredMongoIndex", err)
+	}
+}
+
+func TestProcessChatbotKeepsOwnershipErrors(t *testing.T) {
+	useTestMongo(t)
+	useFakeNLP(t, "halo dari penyusup", "Halo!")
+
+	first := models.Message{Sender: "user", Content: "halo", Timestamp: time.Now()}
+	if err := SaveMessages(context.Background(), "chat-milik-1", 1, "a", &first); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ProcessChatbot("chat-milik-1", "halo dari penyusup", 2, "b"); !errors.Is(err, ErrChatForbidden) {
+		t.Errorf("err = %v, ingin ErrChatForbidden", err)
+	}
+}
@@ -0,0 +1,417 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3Types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrExportJobNotFound  = errors.New("job ekspor tidak ditemukan")
+	ErrExportNotReady     = errors.New("file ekspor belum siap")
+	ErrExportExpired      = errors.New("file ekspor sudah kedaluwarsa, silakan buat ekspor baru")
+	ErrExportUnavailable  = errors.New("ekspor massal membutuhkan penyimpanan S3 yang belum dikonfigurasi")
+	errExportJobLeaseLost = errors.New("klaim job ekspor sudah diambil worker lain")
+)
+
+// exportFilePrefix adalah awalan key S3 untuk semua hasil ekspor
+const exportFilePrefix = "exports/"
+
+// exportJobWake membangunkan worker saat ada job ekspor baru
+var exportJobWake = newJobWaker()
+
+// StartExportWorkers menjalankan worker ekspor dan pembersih file ekspor lama. Worker
+// mengambil job pending dari MongoDB, termasuk job yang belum selesai sebelum server
+// terakhir kali berhenti setelah sewanya habis.
+func StartExportWorkers(n int) {
+	if n <= 0 {
+		n = 1
+	}
+	if err := os.MkdirAll(config.ExportSpoolDir, 0o700); err != nil {
+		config.Log.Error("Gagal membuat direktori sementara ekspor: ", err)
+	}
+	for i := 0; i < n; i++ {
+		owner := jobWorkerID("export", i)
+		go runJobWorker(exportJobWake, config.JobPollInterval, func() (bool, error) {
+			return claimAndRunExportJob(owner)
+		})
+	}
+
+	go cleanExpiredExports()
+
+	config.Log.Infof("📦 %d worker ekspor transkrip berjalan", n)
+}
+
+// CreateExportJob menyimpan job baru lalu membangunkan worker
+func CreateExportJob(job models.ExportJob) (*models.ExportJob, error) {
+	if err := ValidateExportFormat(job.Format); err != nil {
+		return nil, err
+	}
+	if s3Client == nil {
+		return nil, ErrExportUnavailable
+	}
+
+	job.ID = primitive.NewObjectID()
+	job.Status = models.ExportStatusPending
+	job.CreatedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := config.MongoDB.Collection("export_jobs").InsertOne(ctx, job); err != nil {
+		return nil, err
+	}
+	exportJobWake.wake()
+	return &job, nil
+}
+
+// GetExportJob mengambil job; user biasa hanya dapat melihat job miliknya sendiri
+func GetExportJob(jobID string, userID int, admin bool) (*models.ExportJob, error) {
+	id, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return nil, ErrExportJobNotFound
+	}
+
+	filter := bson.M{"_id": id}
+	if !admin {
+		filter["requested_by"] = userID
+		filter["admin"] = false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var job models.ExportJob
+	err = config.MongoDB.Collection("export_jobs").FindOne(ctx, filter).Decode(&job)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrExportJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// OpenExportFile membuka hasil job dari S3; pemanggil wajib menutup reader-nya
+func OpenExportFile(ctx context.Context, job *models.ExportJob) (io.ReadCloser, string, error) {
+	if job.Status == models.ExportStatusExpired {
+		return nil, "", ErrExportExpired
+	}
+	if job.Status != models.ExportStatusDone || job.FileKey == "" {
+		return nil, "", ErrExportNotReady
+	}
+	if s3Client == nil {
+		return nil, "", ErrExportUnavailable
+	}
+
+	out, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(config.AWSBucketName),
+		Key:    aws.String(job.FileKey),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("gagal mengambil file ekspor dari S3: %v", err)
+	}
+	return out.Body, exportContentTypes[job.Format], nil
+}
+
+func claimAndRunExportJob(owner string) (bool, error) {
+	job, err := claimExportJob(owner)
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	if err := runExportJob(job); err != nil {
+		config.Log.Errorf("Job ekspor %s gagal: %v", job.ID.Hex(), err)
+		if !errors.Is(err, errExportJobLeaseLost) {
+			if ferr := finishExportJob(job, bson.M{"status": models.ExportStatusFailed, "error": err.Error()}); ferr != nil {
+				config.Log.Error("Gagal memperbarui status job ekspor: ", ferr)
+			}
+		}
+	}
+	return true, nil
+}
+
+// claimExportJob mengklaim job tertua yang bisa diproses secara atomik. Job running hanya
+// diambil alih setelah sewanya habis. Mengembalikan nil bila tidak ada job.
+func claimExportJob(owner string) (*models.ExportJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var job models.ExportJob
+	err := config.MongoDB.Collection("export_jobs").FindOneAndUpdate(ctx,
+		claimableJobFilter(models.ExportStatusPending, models.ExportStatusRunning, now),
+		bson.M{"$set": bson.M{
+			"status":       models.ExportStatusRunning,
+			"owner":        owner,
+			"locked_until": now.Add(config.ExportJobLease),
+			"started_at":   now,
+		}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "created_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&job)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// finishExportJob menyimpan status akhir hanya bila klaim masih milik worker ini
+func finishExportJob(job *models.ExportJob, set bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set["completed_at"] = time.Now()
+	res, err := config.MongoDB.Collection("export_jobs").UpdateOne(ctx,
+		bson.M{"_id": job.ID, "owner": job.Owner},
+		bson.M{"$set": set, "$unset": bson.M{"owner": "", "locked_until": ""}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errExportJobLeaseLost
+	}
+	return nil
+}
+
+// runExportJob merender transkrip ke berkas sementara di EXPORT_SPOOL_DIR sambil membaca
+// percakapan lewat cursor, lalu mengunggah berkas itu ke S3. Memori yang dipakai hanya
+// sebesar satu chat, bukan seluruh hasil ekspor.
+func runExportJob(job *models.ExportJob) error {
+	if s3Client == nil {
+		return ErrExportUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	spool, err := os.CreateTemp(config.ExportSpoolDir, "export-*")
+	if err != nil {
+		return fmt.Errorf("gagal membuat berkas sementara ekspor: %v", err)
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	chats, err := writeExportTranscripts(ctx, job, spool)
+	if err != nil {
+		return err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	fileName := exportFileName("transkrip-"+job.ID.Hex(), job.Format)
+	key := exportFilePrefix + fileName
+	_, err = manager.NewUploader(s3Client).Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(config.AWSBucketName),
+		Key:         aws.String(key),
+		Body:        spool,
+		ContentType: aws.String(exportContentTypes[job.Format]),
+	})
+	if err != nil {
+		return fmt.Errorf("gagal mengunggah file ekspor ke S3: %v", err)
+	}
+
+	err = finishExportJob(job, bson.M{
+		"status":    models.ExportStatusDone,
+		"chats":     chats,
+		"file_key":  key,
+		"file_name": fileName,
+	})
+	if err != nil {
+		// Job sudah dikerjakan ulang worker lain; file ini tidak akan pernah diunduh
+		deleteExportObjects(context.WithoutCancel(ctx), []string{key})
+		return err
+	}
+	return nil
+}
+
+// writeExportTranscripts menulis percakapan sesuai cakupan job satu per satu dari cursor.
+// Job milik user selalu dibatasi ke chat miliknya sendiri. Mengembalikan jumlah chat.
+func writeExportTranscripts(ctx context.Context, job *models.ExportJob, w io.Writer) (int, error) {
+	filter := bson.M{"deleted_at": notDeleted}
+	if len(job.ChatIDs) > 0 {
+		filter["chat_id"] = bson.M{"$in": job.ChatIDs}
+	}
+	if !job.Admin {
+		filter["user_id"] = job.RequestedBy
+	} else if job.UserID != nil {
+		filter["user_id"] = *job.UserID
+	}
+	if job.From != nil || job.To != nil {
+		updated := bson.M{}
+		if job.From != nil {
+			updated["$gte"] = *job.From
+		}
+		if job.To != nil {
+			updated["$lte"] = *job.To
+		}
+		filter["updated_at"] = updated
+	}
+
+	tw, err := newTranscriptWriter(w, job.Format)
+	if err != nil {
+		return 0, err
+	}
+
+	cursor, err := config.MongoDB.Collection("conversations").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}}).SetProjection(bson.M{"outbox": 0}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	chats := 0
+	for cursor.Next(ctx) {
+		var convo models.Conversation
+		if err := cursor.Decode(&convo); err != nil {
+			return chats, err
+		}
+		messages, err := GetFullChatHistory(convo.ChatID, convo.UserID)
+		if err != nil {
+			return chats, fmt.Errorf("gagal mengambil riwayat chat %s: %v", convo.ChatID, err)
+		}
+		if err := tw.WriteChat(ChatTranscript{Conversation: convo, Messages: messages}); err != nil {
+			return chats, err
+		}
+		chats++
+	}
+	if err := cursor.Err(); err != nil {
+		return chats, err
+	}
+	return chats, tw.Close()
+}
+
+// cleanExpiredExports menandai job yang filenya lebih tua dari EXPORT_RETENTION sebagai
+// expired lalu menghapus semua objek exports/ yang sudah lewat umur itu, termasuk file
+// yatim dari job yang gagal menyimpan statusnya
+func cleanExpiredExports() {
+	if config.ExportRetention <= 0 || s3Client == nil {
+		return
+	}
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for ; ; <-ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		jobs, objects, err := expireExports(ctx, time.Now().Add(-config.ExportRetention))
+		cancel()
+		if err != nil {
+			config.Log.Error("Gagal membersihkan file ekspor lama: ", err)
+		}
+		if jobs > 0 || objects > 0 {
+			config.Log.Infof("📦 %d job ekspor kedaluwarsa, %d file ekspor dihapus dari S3", jobs, objects)
+		}
+	}
+}
+
+func expireExports(ctx context.Context, cutoff time.Time) (jobs, objects int, err error) {
+	res, err := config.MongoDB.Collection("export_jobs").UpdateMany(ctx,
+		bson.M{"status": models.ExportStatusDone, "completed_at": bson.M{"$lt": cutoff}},
+		bson.M{"$set": bson.M{"status": models.ExportStatusExpired}, "$unset": bson.M{"file_key": ""}})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(config.AWSBucketName),
+		Prefix: aws.String(exportFilePrefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return int(res.ModifiedCount), 0, err
+		}
+		for _, obj := range page.Contents {
+			if obj.LastModified != nil && obj.LastModified.Before(cutoff) {
+				keys = append(keys, aws.ToString(obj.Key))
+			}
+		}
+	}
+	return int(res.ModifiedCount), deleteExportObjects(ctx, keys), nil
+}
+
+// deleteExportJobs menghapus job ekspor milik chat beserta filenya di S3: job yang
+// menyebut chat itu, ekspor user pemiliknya tanpa daftar chat, dan ekspor massal admin
+// yang difilter ke user pemiliknya. Rentang tanggal diabaikan. Ekspor massal admin untuk
+// semua user tidak ikut dihapus dan berakhir lewat EXPORT_RETENTION.
+func deleteExportJobs(ctx context.Context, convo models.Conversation) (jobs int64, objects int, err error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"chat_ids": convo.ChatID},
+		bson.M{"chat_ids": bson.M{"$in": bson.A{nil, bson.A{}}}, "$or": bson.A{
+			bson.M{"admin": false, "requested_by": convo.UserID},
+			bson.M{"admin": true, "user_id": convo.UserID},
+		}},
+	}}
+
+	coll := config.MongoDB.Collection("export_jobs")
+	cursor, err := coll.Find(ctx, filter, options.Find().SetProjection(bson.M{"file_key": 1}))
+	if err != nil {
+		return 0, 0, err
+	}
+	var found []models.ExportJob
+	if err := cursor.All(ctx, &found); err != nil {
+		return 0, 0, err
+	}
+	var keys []string
+	for _, job := range found {
+		if job.FileKey != "" {
+			keys = append(keys, job.FileKey)
+		}
+	}
+	objects = deleteExportObjects(ctx, keys)
+
+	// Job yang sedang berjalan kehilangan klaimnya dan menghapus file yang baru diunggahnya
+	res, err := coll.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, objects, err
+	}
+	return res.DeletedCount, objects, nil
+}
+
+// deleteExportObjects menghapus file ekspor dari S3 per 1000 key (batas DeleteObjects).
+// Kegagalan hanya dicatat; mengembalikan jumlah objek yang terhapus.
+func deleteExportObjects(ctx context.Context, keys []string) int {
+	if s3Client == nil {
+		return 0
+	}
+	deleted := 0
+	for start := 0; start < len(keys); start += 1000 {
+		batch := keys[start:min(start+1000, len(keys))]
+		ids := make([]s3Types.ObjectIdentifier, len(batch))
+		for i, key := range batch {
+			ids[i] = s3Types.ObjectIdentifier{Key: aws.String(key)}
+		}
+		out, err := s3Client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(config.AWSBucketName),
+			Delete: &s3Types.Delete{Objects: ids, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			config.Log.Warn("Gagal menghapus file ekspor dari S3: ", err)
+			continue
+		}
+		for _, e := range out.Errors {
+			config.Log.Warnf("Gagal menghapus file ekspor %s: %s", aws.ToString(e.Key), aws.ToString(e.Message))
+		}
+		deleted += len(batch) - len(out.Errors)
+	}
+	return deleted
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func insertExportJob(t *testing.T, job models.ExportJob) {
+	t.Helper()
+	if _, err := config.MongoDB.Collection("export_jobs").InsertOne(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClaimExportJobRespectsLease(t *testing.T) {
+	useTestMongo(t)
+	config.ExportJobLease = time.Minute
+
+	base := time.Now().Add(-time.Hour)
+	future, past := time.Now().Add(time.Minute), time.Now().Add(-time.Minute)
+	leased := models.ExportJob{ID: primitive.NewObjectID(), Status: models.ExportStatusRunning, Owner: "lain", LockedUntil: &future, CreatedAt: base}
+	expired := models.ExportJob{ID: primitive.NewObjectID(), Status: models.ExportStatusRunning, Owner: "mati", LockedUntil: &past, CreatedAt: base.Add(time.Second)}
+	pending := models.ExportJob{ID: primitive.NewObjectID(), Status: models.ExportStatusPending, CreatedAt: base.Add(2 * time.Second)}
+	for _, job := range []models.ExportJob{leased, expired, pending} {
+		insertExportJob(t, job)
+	}
+
+	for _, want := range []primitive.ObjectID{expired.ID, pending.ID} {
+		job, err := claimExportJob("worker-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job == nil || job.ID != want {
+			t.Fatalf("klaim = %+v, ingin job %s", job, want.Hex())
+		}
+		if job.Owner != "worker-1" || job.LockedUntil == nil || !job.LockedUntil.After(time.Now()) {
+			t.Errorf("job %s tidak tercatat milik worker-1 dengan sewa aktif", job.ID.Hex())
+		}
+	}
+
+	job, err := claimExportJob("worker-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job != nil {
+		t.Fatalf("job %s dengan sewa aktif tidak boleh diklaim ulang", job.ID.Hex())
+	}
+}
+
+func TestFinishExportJobDetectsLostLease(t *testing.T) {
+	useTestMongo(t)
+	config.ExportJobLease = time.Minute
+
+	insertExportJob(t, models.ExportJob{ID: primitive.NewObjectID(), Status: models.ExportStatusPending, CreatedAt: time.Now()})
+	job, err := claimExportJob("worker-1")
+	if err != nil || job == nil {
+		t.Fatalf("klaim gagal: %v", err)
+	}
+
+	// Sewa habis dan job diambil alih worker lain
+	ctx := context.Background()
+	if _, err := config.MongoDB.Collection("export_jobs").UpdateOne(ctx, bson.M{"_id": job.ID}, bson.M{"$set": bson.M{"owner": "worker-2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := finishExportJob(job, bson.M{"status": models.ExportStatusDone}); !errors.Is(err, errExportJobLeaseLost) {
+		t.Fatalf("finish = %v, ingin errExportJobLeaseLost", err)
+	}
+
+	var stored models.ExportJob
+	if err := config.MongoDB.Collection("export_jobs").FindOne(ctx, bson.M{"_id": job.ID}).Decode(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.ExportStatusRunning || stored.Owner != "worker-2" {
+		t.Errorf("status job ditimpa worker yang kehilangan sewa: %+v", stored)
+	}
+}
+
+func TestWriteExportTranscriptsLimitsUserScope(t *testing.T) {
+	useTestMongo(t)
+	ctx := context.Background()
+
+	for _, chat := range []struct {
+		id     string
+		userID int
+	}{{"chat-a1", 1}, {"chat-a2", 1}, {"chat-b1", 2}} {
+		msg := models.Message{Sender: "user", Content: "halo " + chat.id, Timestamp: time.Now()}
+		if err := SaveMessages(ctx, chat.id, chat.userID, "u", &msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	job := &models.ExportJob{RequestedBy: 1, Format: models.ExportFormatJSON}
+	chats, err := writeExportTranscripts(ctx, job, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		Chats []transcriptJSON `json:"chats"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("JSON ekspor tidak valid: %v\n%s", err, buf.String())
+	}
+	if chats != 2 || len(out.Chats) != 2 {
+		t.Fatalf("ekspor user 1 berisi %d chat (%d di dokumen), ingin 2", chats, len(out.Chats))
+	}
+	for _, chat := range out.Chats {
+		if chat.UserID != 1 || len(chat.Messages) != 1 {
+			t.Errorf("chat %s milik user %d dengan %d pesan", chat.ChatID, chat.UserID, len(chat.Messages))
+		}
+	}
+}
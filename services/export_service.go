@@ -0,0 +1,443 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrUnsupportedExportFormat = errors.New("format ekspor tidak didukung (pdf, html, json, csv)")
+
+// ChatTranscript adalah satu percakapan lengkap yang siap dirender
+type ChatTranscript struct {
+	Conversation models.Conversation
+	Messages     []models.Message
+}
+
+// ExportFile adalah hasil render ekspor yang langsung dikirim ke klien
+type ExportFile struct {
+	Data        []byte
+	ContentType string
+	FileName    string
+}
+
+var exportContentTypes = map[string]string{
+	models.ExportFormatPDF:  "application/pdf",
+	models.ExportFormatHTML: "text/html; charset=utf-8",
+	models.ExportFormatJSON: "application/json",
+	models.ExportFormatCSV:  "text/csv; charset=utf-8",
+}
+
+// ValidateExportFormat memastikan format dikenal
+func ValidateExportFormat(format string) error {
+	if _, ok := exportContentTypes[format]; !ok {
+		return ErrUnsupportedExportFormat
+	}
+	return nil
+}
+
+// ExportChat merender satu chat milik user. Chat besar tidak dirender langsung
+// melainkan dijadikan job latar belakang; salah satu dari kedua hasil akan nil.
+func ExportChat(chatID string, userID int, format string) (*ExportFile, *models.ExportJob, error) {
+	if err := ValidateExportFormat(format); err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var convo models.Conversation
+	err := config.MongoDB.Collection("conversations").FindOne(ctx, bson.M{"chat_id": chatID, "user_id": userID, "deleted_at": notDeleted}).Decode(&convo)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, ErrChatNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Tanpa S3 hasil job tidak punya tempat disimpan, jadi chat besar tetap dirender langsung
+	if s3Client != nil && config.ExportSyncMaxMessages > 0 && convo.MessageCount > int64(config.ExportSyncMaxMessages) {
+		job, err := CreateExportJob(models.ExportJob{
+			RequestedBy: userID,
+			Format:      format,
+			ChatIDs:     []string{chatID},
+		})
+		return nil, job, err
+	}
+
+	messages, err := GetFullChatHistory(chatID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := RenderTranscripts(&buf, format, []ChatTranscript{{Conversation: convo, Messages: messages}}); err != nil {
+		return nil, nil, err
+	}
+
+	return &ExportFile{
+		Data:        buf.Bytes(),
+		ContentType: exportContentTypes[format],
+		FileName:    exportFileName("chat-"+chatID, format),
+	}, nil, nil
+}
+
+func exportFileName(base, format string) string {
+	return fmt.Sprintf("%s-%s.%s", base, time.Now().Format("20060102-150405"), format)
+}
+
+// transcriptWriter menulis transkrip satu chat demi satu chat sehingga ekspor massal
+// tidak perlu memuat semua percakapan ke memori. Close menulis penutup dokumen.
+type transcriptWriter interface {
+	WriteChat(chat ChatTranscript) error
+	Close() error
+}
+
+// newTranscriptWriter membuat penulis untuk format yang diminta dan langsung menulis
+// pembuka dokumen
+func newTranscriptWriter(w io.Writer, format string) (transcriptWriter, error) {
+	switch format {
+	case models.ExportFormatJSON:
+		return newJSONTranscriptWriter(w)
+	case models.ExportFormatCSV:
+		return newCSVTranscriptWriter(w)
+	case models.ExportFormatHTML:
+		return newHTMLTranscriptWriter(w)
+	case models.ExportFormatPDF:
+		return newPDFTranscriptWriter(w), nil
+	}
+	return nil, ErrUnsupportedExportFormat
+}
+
+// RenderTranscripts menulis satu atau beberapa percakapan dalam format yang diminta
+func RenderTranscripts(w io.Writer, format string, chats []ChatTranscript) error {
+	tw, err := newTranscriptWriter(w, format)
+	if err != nil {
+		return err
+	}
+	for _, chat := range chats {
+		if err := tw.WriteChat(chat); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func senderLabel(msg models.Message) string {
+	label := "Nasabah"
+	if msg.Sender == "bot" {
+		label = "Bot"
+	}
+	if msg.Modality == models.ModalityVoice {
+		label += " (suara)"
+	}
+	return label
+}
+
+func audioURL(msg models.Message) string {
+	if msg.Audio == nil {
+		return ""
+	}
+	return msg.Audio.URL
+}
+
+// ==== JSON ====
+
+type transcriptJSON struct {
+	ChatID    string           `json:"chat_id"`
+	ChatTitle string           `json:"chat_title"`
+	UserID    int              `json:"user_id"`
+	Username  string           `json:"username"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	Messages  []models.Message `json:"messages"`
+}
+
+// jsonTranscriptWriter menghasilkan dokumen yang sama dengan json.Encoder berindentasi
+// atas {brand, exported_at, chats}, tetapi setiap chat ditulis begitu selesai dibaca
+type jsonTranscriptWriter struct {
+	w     io.Writer
+	chats int
+}
+
+func newJSONTranscriptWriter(w io.Writer) (*jsonTranscriptWriter, error) {
+	brand, err := json.Marshal(config.ExportBrandName)
+	if err != nil {
+		return nil, err
+	}
+	exportedAt, err := json.Marshal(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	_, err = fmt.Fprintf(w, "{\n  \"brand\": %s,\n  \"exported_at\": %s,\n  \"chats\": [", brand, exportedAt)
+	return &jsonTranscriptWriter{w: w}, err
+}
+
+func (t *jsonTranscriptWriter) WriteChat(chat ChatTranscript) error {
+	data, err := json.MarshalIndent(transcriptJSON{
+		ChatID:    chat.Conversation.ChatID,
+		ChatTitle: chat.Conversation.ChatTitle,
+		UserID:    chat.Conversation.UserID,
+		Username:  chat.Conversation.Username,
+		CreatedAt: chat.Conversation.CreatedAt,
+		UpdatedAt: chat.Conversation.UpdatedAt,
+		Messages:  chat.Messages,
+	}, "    ", "  ")
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n    "
+	if t.chats == 0 {
+		sep = "\n    "
+	}
+	t.chats++
+	_, err = io.WriteString(t.w, sep+string(data))
+	return err
+}
+
+func (t *jsonTranscriptWriter) Close() error {
+	end := "]\n}\n"
+	if t.chats > 0 {
+		end = "\n  ]\n}\n"
+	}
+	_, err := io.WriteString(t.w, end)
+	return err
+}
+
+// ==== CSV ====
+
+type csvTranscriptWriter struct {
+	cw *csv.Writer
+}
+
+func newCSVTranscriptWriter(w io.Writer) (*csvTranscriptWriter, error) {
+	cw := csv.NewWriter(w)
+	header := []string{"chat_id", "chat_title", "user_id", "seq", "timestamp", "sender", "modality", "text", "transcript", "audio_url", "intent", "confidence"}
+	if err := cw.Write(header); err != nil {
+		return nil, err
+	}
+	return &csvTranscriptWriter{cw: cw}, nil
+}
+
+func (t *csvTranscriptWriter) WriteChat(chat ChatTranscript) error {
+	for _, msg := range chat.Messages {
+		confidence := ""
+		if msg.Confidence != 0 {
+			confidence = strconv.FormatFloat(msg.Confidence, 'f', 4, 64)
+		}
+		row := []string{
+			chat.Conversation.ChatID,
+			chat.Conversation.ChatTitle,
+			strconv.Itoa(chat.Conversation.UserID),
+			strconv.FormatInt(msg.Seq, 10),
+			msg.Timestamp.Format(time.RFC3339),
+			msg.Sender,
+			msg.Modality,
+			msg.Content,
+			msg.Transcript,
+			audioURL(msg),
+			msg.Intent,
+			confidence,
+		}
+		if err := t.cw.Write(row); err != nil {
+			return err
+		}
+	}
+	t.cw.Flush()
+	return t.cw.Error()
+}
+
+func (t *csvTranscriptWriter) Close() error {
+	t.cw.Flush()
+	return t.cw.Error()
+}
+
+// ==== HTML ====
+
+var transcriptHTML = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"sender": senderLabel,
+	"audio":  audioURL,
+	"time":   func(t time.Time) string { return t.Local().Format("02 Jan 2006 15:04") },
+}).Parse(`{{define "head"}}<!DOCTYPE html>
+<html lang="id">
+<head>
+<meta charset="utf-8">
+<title>Transkrip Percakapan – {{.Brand}}</title>
+<style>
+body { font-family: Arial, Helvetica, sans-serif; margin: 0; color: #222; }
+header { background: {{.Color}}; color: #fff; padding: 16px 24px; }
+header h1 { margin: 0; font-size: 20px; }
+header p { margin: 4px 0 0; font-size: 12px; opacity: .85; }
+section { padding: 16px 24px; border-bottom: 1px solid #ddd; page-break-after: always; }
+h2 { font-size: 16px; margin: 0 0 4px; }
+.meta { font-size: 12px; color: #666; margin-bottom: 12px; }
+.msg { margin: 8px 0; padding: 8px 12px; border-radius: 6px; max-width: 80%; }
+.user { background: #eef5f1; }
+.bot { background: #f4f4f4; margin-left: auto; }
+.who { font-size: 11px; color: #555; margin-bottom: 2px; }
+.audio { font-size: 12px; margin-top: 4px; }
+</style>
+</head>
+<body>
+<header><h1>{{.Brand}}</h1><p>Transkrip percakapan · diekspor {{time .ExportedAt}}</p></header>
+{{end}}{{define "chat"}}<section>
+<h2>{{.Conversation.ChatTitle}}</h2>
+<div class="meta">Chat ID {{.Conversation.ChatID}} · {{.Conversation.Username}} · dibuat {{time .Conversation.CreatedAt}}</div>
+{{range .Messages}}<div class="msg {{if eq .Sender "bot"}}bot{{else}}user{{end}}">
+<div class="who">{{sender .}} · {{time .Timestamp}}</div>
+<div>{{.Text}}</div>
+{{with audio .}}<div class="audio"><audio controls preload="none" src="{{.}}"></audio> <a href="{{.}}">Unduh audio</a></div>{{end}}
+</div>
+{{end}}</section>
+{{end}}{{define "foot"}}</body>
+</html>
+{{end}}`))
+
+type htmlTranscriptWriter struct {
+	w io.Writer
+}
+
+func newHTMLTranscriptWriter(w io.Writer) (*htmlTranscriptWriter, error) {
+	err := transcriptHTML.ExecuteTemplate(w, "head", struct {
+		Brand      string
+		Color      template.CSS
+		ExportedAt time.Time
+	}{
+		Brand:      config.ExportBrandName,
+		Color:      template.CSS(brandColorHex()),
+		ExportedAt: time.Now(),
+	})
+	return &htmlTranscriptWriter{w: w}, err
+}
+
+func (t *htmlTranscriptWriter) WriteChat(chat ChatTranscript) error {
+	return transcriptHTML.ExecuteTemplate(t.w, "chat", chat)
+}
+
+func (t *htmlTranscriptWriter) Close() error {
+	return transcriptHTML.ExecuteTemplate(t.w, "foot", nil)
+}
+
+// ==== PDF ====
+
+// brandColorHex mengembalikan warna brand yang valid (#rrggbb), jika tidak memakai default
+func brandColorHex() string {
+	if _, _, _, ok := parseHexColor(config.ExportBrandColor); ok {
+		return config.ExportBrandColor
+	}
+	return "#00704A"
+}
+
+func parseHexColor(s string) (int, int, int, bool) {
+	if len(s) != 7 || s[0] != '#' {
+		return 0, 0, 0, false
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff), true
+}
+
+// pdfTranscriptWriter menambah halaman per chat. gofpdf menyusun dokumen di memorinya
+// sendiri sampai Close, tetapi pesan sumber tiap chat sudah bisa dilepas setelah ditulis.
+type pdfTranscriptWriter struct {
+	w       io.Writer
+	pdf     *gofpdf.Fpdf
+	tr      func(string) string
+	r, g, b int
+	chats   int
+}
+
+func newPDFTranscriptWriter(w io.Writer) *pdfTranscriptWriter {
+	r, g, b, _ := parseHexColor(brandColorHex())
+	exportedAt := time.Now().Local().Format("02 Jan 2006 15:04")
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	// Font inti PDF memakai cp1252; karakter di luar itu (emoji) dihilangkan
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle("Transkrip Percakapan - "+config.ExportBrandName, true)
+	pdf.SetAuthor(config.ExportBrandName, true)
+
+	pdf.SetHeaderFunc(func() {
+		pdf.SetFillColor(r, g, b)
+		pdf.Rect(0, 0, 210, 18, "F")
+		pdf.SetTextColor(255, 255, 255)
+		pdf.SetFont("Helvetica", "B", 14)
+		pdf.SetXY(10, 5)
+		pdf.CellFormat(120, 8, tr(config.ExportBrandName), "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.CellFormat(70, 8, tr("Diekspor "+exportedAt), "", 0, "R", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+		pdf.SetY(24)
+	})
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(0, 6, fmt.Sprintf("Halaman %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	return &pdfTranscriptWriter{w: w, pdf: pdf, tr: tr, r: r, g: g, b: b}
+}
+
+func (t *pdfTranscriptWriter) WriteChat(chat ChatTranscript) error {
+	pdf, tr := t.pdf, t.tr
+	t.chats++
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.MultiCell(0, 6, tr(chat.Conversation.ChatTitle), "", "L", false)
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.SetTextColor(100, 100, 100)
+	pdf.MultiCell(0, 4, tr(fmt.Sprintf("Chat ID %s · %s · dibuat %s",
+		chat.Conversation.ChatID, chat.Conversation.Username,
+		chat.Conversation.CreatedAt.Local().Format("02 Jan 2006 15:04"))), "", "L", false)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.Ln(4)
+
+	for _, msg := range chat.Messages {
+		pdf.SetFont("Helvetica", "B", 9)
+		if msg.Sender == "bot" {
+			pdf.SetTextColor(t.r, t.g, t.b)
+		}
+		pdf.CellFormat(0, 5, tr(fmt.Sprintf("%s · %s", senderLabel(msg), msg.Timestamp.Local().Format("02 Jan 2006 15:04"))), "", 1, "L", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.MultiCell(0, 5, tr(msg.Text()), "", "L", false)
+
+		if url := audioURL(msg); url != "" {
+			pdf.SetFont("Helvetica", "U", 8)
+			pdf.SetTextColor(0, 0, 200)
+			pdf.WriteLinkString(4, "Dengarkan audio", url)
+			pdf.SetTextColor(0, 0, 0)
+			pdf.Ln(4)
+		}
+		pdf.Ln(2)
+	}
+	return pdf.Error()
+}
+
+func (t *pdfTranscriptWriter) Close() error {
+	if t.chats == 0 {
+		t.pdf.AddPage()
+		t.pdf.SetFont("Helvetica", "", 10)
+		t.pdf.CellFormat(0, 6, "Tidak ada percakapan untuk diekspor.", "", 1, "L", false, 0, "")
+	}
+	return t.pdf.Output(t.w)
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"backend-go/models"
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleTranscripts(n int) []ChatTranscript {
+	at := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	chats := make([]ChatTranscript, n)
+	for i := range chats {
+		chats[i] = ChatTranscript{
+			Conversation: models.Conversation{ChatID: "chat-" + string(rune('a'+i)), ChatTitle: "Saldo <rekening>", UserID: 7, CreatedAt: at, UpdatedAt: at},
+			Messages: []models.Message{
+				{Sender: "user", Modality: models.ModalityText, Content: "cek saldo", Seq: 1, Timestamp: at},
+				{Sender: "bot", Modality: models.ModalityText, Content: "Saldo Anda & bunga", Seq: 2, Timestamp: at},
+			},
+		}
+	}
+	return chats
+}
+
+var exportedAtPattern = regexp.MustCompile(`"exported_at": "[^"]*"`)
+
+// Dokumen JSON yang ditulis per chat harus sama persis dengan hasil json.Encoder lama
+func TestJSONTranscriptWriterMatchesEncoder(t *testing.T) {
+	for _, n := range []int{0, 1, 3} {
+		chats := sampleTranscripts(n)
+
+		var got bytes.Buffer
+		if err := RenderTranscripts(&got, models.ExportFormatJSON, chats); err != nil {
+			t.Fatal(err)
+		}
+
+		out := struct {
+			Brand      string           `json:"brand"`
+			ExportedAt time.Time        `json:"exported_at"`
+			Chats      []transcriptJSON `json:"chats"`
+		}{Chats: make([]transcriptJSON, 0, n)}
+		json.Unmarshal(got.Bytes(), &out)
+		out.Chats = out.Chats[:0]
+		for _, chat := range chats {
+			out.Chats = append(out.Chats, transcriptJSON{
+				ChatID:    chat.Conversation.ChatID,
+				ChatTitle: chat.Conversation.ChatTitle,
+				UserID:    chat.Conversation.UserID,
+				Username:  chat.Conversation.Username,
+				CreatedAt: chat.Conversation.CreatedAt,
+				UpdatedAt: chat.Conversation.UpdatedAt,
+				Messages:  chat.Messages,
+			})
+		}
+		var want bytes.Buffer
+		enc := json.NewEncoder(&want)
+		enc.SetIndent("", "  ")
+		enc.Encode(out)
+
+		normalize := func(b []byte) string { return exportedAtPattern.ReplaceAllString(string(b), `"exported_at": ""`) }
+		if normalize(got.Bytes()) != normalize(want.Bytes()) {
+			t.Errorf("%d chat: JSON berbeda\ndapat:\n%s\ningin:\n%s", n, got.String(), want.String())
+		}
+	}
+}
+
+func TestTranscriptWritersCloseDocument(t *testing.T) {
+	for format, tail := range map[string]string{
+		models.ExportFormatHTML: "</html>\n",
+		models.ExportFormatCSV:  "",
+		models.ExportFormatPDF:  "%%EOF\n",
+	} {
+		var buf bytes.Buffer
+		if err := RenderTranscripts(&buf, format, sampleTranscripts(2)); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		out := buf.String()
+		if format == models.ExportFormatCSV {
+			if rows := strings.Count(out, "\n"); rows != 5 {
+				t.Errorf("csv: %d baris, ingin header + 4 pesan", rows)
+			}
+			continue
+		}
+		if !strings.HasSuffix(out, tail) {
+			t.Errorf("%s tidak ditutup dengan %q", format, tail)
+		}
+		if format == models.ExportFormatHTML && strings.Count(out, "<section>") != 2 {
+			t.Errorf("html harus berisi 2 section")
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// idempotencyPollInterval adalah jeda saat menunggu permintaan duplikat yang sedang diproses
+const idempotencyPollInterval = 250 * time.Millisecond
+
+var (
+	ErrIdempotencyKeyReused = errors.New("Idempotency-Key sudah dipakai untuk permintaan yang berbeda")
+	ErrIdempotencyInFlight  = errors.New("permintaan dengan Idempotency-Key yang sama masih diproses")
+)
+
+// BeginIdempotentRequest mengklaim kunci untuk diproses. Mengembalikan record selesai
+// jika permintaan yang sama sudah pernah diproses (respons tinggal diputar ulang), atau
+// nil jika pemanggil kini memegang kunci dan wajib memanggil Complete/Release.
+// Duplikat yang datang bersamaan menunggu hasil sampai ctx habis.
+func BeginIdempotentRequest(ctx context.Context, id string, userID int, chatID, requestHash string) (*models.IdempotencyRecord, error) {
+	coll := config.MongoDB.Collection("idempotency_keys")
+
+	for {
+		now := time.Now()
+		_, err := coll.InsertOne(ctx, models.IdempotencyRecord{
+			ID:          id,
+			UserID:      userID,
+			ChatID:      chatID,
+			RequestHash: requestHash,
+			Status:      models.IdempotencyStatusProcessing,
+			CreatedAt:   now,
+			LockedUntil: now.Add(config.IdempotencyLockTimeout),
+			ExpiresAt:   now.Add(config.IdempotencyTTL),
+		})
+		if err == nil {
+			return nil, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+
+		var existing models.IdempotencyRecord
+		err = coll.FindOne(ctx, bson.M{"_id": id}).Decode(&existing)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// Pemroses sebelumnya gagal dan melepas kunci; coba klaim lagi
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if existing.RequestHash != requestHash {
+			return nil, ErrIdempotencyKeyReused
+		}
+		if existing.Status == models.IdempotencyStatusDone {
+			return &existing, nil
+		}
+
+		// Pemroses yang melewati batas kunci dianggap mati (mis. server restart)
+		if now.After(existing.LockedUntil) {
+			res, err := coll.UpdateOne(ctx,
+				bson.M{"_id": id, "status": models.IdempotencyStatusProcessing, "locked_until": existing.LockedUntil},
+				bson.M{"$set": bson.M{"locked_until": now.Add(config.IdempotencyLockTimeout)}})
+			if err != nil {
+				return nil, err
+			}
+			if res.ModifiedCount == 1 {
+				return nil, nil
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ErrIdempotencyInFlight
+		case <-time.After(idempotencyPollInterval):
+		}
+	}
+}
+
+// CompleteIdempotentRequest menyimpan respons agar dapat diputar ulang sampai TTL habis
+func CompleteIdempotentRequest(id string, statusCode int, contentType string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := config.MongoDB.Collection("idempotency_keys").UpdateOne(ctx, bson.M{"_id": id},
+		bson.M{"$set": bson.M{
+			"status":       models.IdempotencyStatusDone,
+			"status_code":  statusCode,
+			"content_type": contentType,
+			"body":         body,
+		}})
+	return err
+}
+
+// deleteIdempotencyKeys menghapus respons tersimpan milik chat; isinya memuat pesan chat
+func deleteIdempotencyKeys(ctx context.Context, chatID string) (int64, error) {
+	res, err := config.MongoDB.Collection("idempotency_keys").DeleteMany(ctx, bson.M{"chat_id": chatID})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// ReleaseIdempotentRequest melepas kunci tanpa menyimpan respons sehingga retry
+// berikutnya diproses ulang (dipakai saat terjadi error server)
+func ReleaseIdempotentRequest(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := config.MongoDB.Collection("idempotency_keys").DeleteOne(ctx,
+		bson.M{"_id": id, "status": models.IdempotencyStatusProcessing})
+	if err != nil {
+		config.Log.Warn("Gagal melepas Idempotency-Key: ", err)
+	}
+}
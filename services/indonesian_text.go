@@ -0,0 +1,176 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopWordsID adalah kata umum bahasa Indonesia yang diabaikan saat pencarian
+var stopWordsID = map[string]bool{
+	"yang": true, "dan": true, "di": true, "ke": true, "dari": true, "untuk": true,
+	"dengan": true, "pada": true, "adalah": true, "ini": true, "itu": true, "atau": true,
+	"juga": true, "tidak": true, "akan": true, "sudah": true, "saya": true, "aku": true,
+	"kamu": true, "anda": true, "kami": true, "kita": true, "mereka": true, "dia": true,
+	"apa": true, "apakah": true, "bagaimana": true, "berapa": true, "kapan": true,
+	"dimana": true, "mana": true, "bisa": true, "dapat": true, "ada": true, "oleh": true,
+	"dalam": true, "karena": true, "jadi": true, "jika": true, "kalau": true, "agar": true,
+	"supaya": true, "tentang": true, "seperti": true, "saat": true, "masih": true,
+	"telah": true, "bahwa": true, "lagi": true, "pun": true, "lah": true, "kah": true,
+	"nya": true, "mau": true, "ingin": true, "tolong": true, "mohon": true, "bot": true,
+	"the": true, "a": true, "an": true, "and": true, "or": true, "of": true, "to": true,
+	"is": true, "in": true,
+}
+
+// vowelRootsID adalah kata dasar berawalan vokal yang sering muncul setelah meng-/peng-.
+// Tanpa kamus, meng- + vokal dianggap meluluhkan k (mengirim -> kirim) kecuali kata
+// sisanya diawali salah satu kata dasar ini (mengambil -> ambil). Perubahan daftar ini
+// mengubah stem; jalankan cmd/migrate -step=search-text -all setelahnya.
+var vowelRootsID = []string{
+	"ajar", "ajak", "aju", "akses", "aktif", "alam", "alih", "ambil", "amati", "aman", "angkat",
+	"angsur", "antar", "atas", "atur", "awas", "edit", "ekspor", "impor", "ikut", "ingat",
+	"info", "input", "isi", "olah", "ubah", "ucap", "ukur", "ulang", "umum", "undang",
+	"unduh", "unggah", "urus", "usul",
+}
+
+// TextToken adalah satu kata beserta posisinya di teks asli
+type TextToken struct {
+	Word  string
+	Stem  string
+	Start int
+	End   int
+}
+
+// TokenizeIndonesian memecah teks menjadi kata (huruf/angka) dengan posisi byte aslinya
+func TokenizeIndonesian(text string) []TextToken {
+	var tokens []TextToken
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := strings.ToLower(text[start:end])
+		tokens = append(tokens, TextToken{Word: word, Stem: StemIndonesian(word), Start: start, End: end})
+		start = -1
+	}
+
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+	return tokens
+}
+
+// SearchStems mengembalikan stem unik (tanpa stop-word) untuk pengindeksan dan kueri
+func SearchStems(text string) []string {
+	seen := map[string]bool{}
+	var stems []string
+	for _, tok := range TokenizeIndonesian(text) {
+		if stopWordsID[tok.Word] || len(tok.Stem) < 2 || seen[tok.Stem] {
+			continue
+		}
+		seen[tok.Stem] = true
+		stems = append(stems, tok.Stem)
+	}
+	return stems
+}
+
+// StemIndonesian adalah stemmer ringan berbasis aturan (varian Nazief–Adriani tanpa kamus):
+// menghapus partikel, kata ganti kepunyaan, sufiks derivasi, lalu prefiks.
+func StemIndonesian(word string) string {
+	w := strings.ToLower(word)
+	if len([]rune(w)) <= 4 || !isAlpha(w) {
+		return w
+	}
+
+	for _, suf := range []string{"lah", "kah", "tah", "pun"} {
+		if strings.HasSuffix(w, suf) && len(w)-len(suf) >= 4 {
+			w = strings.TrimSuffix(w, suf)
+			break
+		}
+	}
+	for _, suf := range []string{"nya", "ku", "mu"} {
+		if strings.HasSuffix(w, suf) && len(w)-len(suf) >= 4 {
+			w = strings.TrimSuffix(w, suf)
+			break
+		}
+	}
+	for _, suf := range []string{"kan", "an", "i"} {
+		if strings.HasSuffix(w, suf) && len(w)-len(suf) >= 4 {
+			w = strings.TrimSuffix(w, suf)
+			break
+		}
+	}
+
+	// Maksimal dua lapis prefiks, misal "memper-", "di-per-"
+	for i := 0; i < 2; i++ {
+		stripped := stripPrefix(w)
+		if stripped == w || len(stripped) < 3 {
+			break
+		}
+		w = stripped
+	}
+	return w
+}
+
+func stripPrefix(w string) string {
+	vowel := func(s string) bool { return s != "" && strings.ContainsRune("aiueo", rune(s[0])) }
+
+	switch {
+	case strings.HasPrefix(w, "di"), strings.HasPrefix(w, "ke"), strings.HasPrefix(w, "se"):
+		return w[2:]
+	case strings.HasPrefix(w, "meng"), strings.HasPrefix(w, "peng"):
+		rest := w[4:]
+		if vowel(rest) && !hasVowelRoot(rest) {
+			// mengirim -> kirim, pengiriman -> kirim
+			return "k" + rest
+		}
+		// mengambil -> ambil, menggunakan -> gunakan
+		return rest
+	case strings.HasPrefix(w, "meny"), strings.HasPrefix(w, "peny"):
+		// menyimpan -> simpan
+		return "s" + w[4:]
+	case strings.HasPrefix(w, "mem"), strings.HasPrefix(w, "pem"):
+		rest := w[3:]
+		if vowel(rest) {
+			// memakai -> pakai
+			return "p" + rest
+		}
+		return rest
+	case strings.HasPrefix(w, "men"), strings.HasPrefix(w, "pen"):
+		rest := w[3:]
+		if vowel(rest) {
+			// menabung -> tabung
+			return "t" + rest
+		}
+		return rest
+	case strings.HasPrefix(w, "ber"), strings.HasPrefix(w, "ter"), strings.HasPrefix(w, "per"):
+		return w[3:]
+	case strings.HasPrefix(w, "be"), strings.HasPrefix(w, "te"), strings.HasPrefix(w, "pe"), strings.HasPrefix(w, "me"):
+		return w[2:]
+	}
+	return w
+}
+
+func hasVowelRoot(w string) bool {
+	for _, root := range vowelRootsID {
+		if strings.HasPrefix(w, root) {
+			return true
+		}
+	}
+	return false
+}
+
+func isAlpha(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
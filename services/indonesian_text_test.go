@@ -0,0 +1,102 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStemIndonesian(t *testing.T) {
+	for _, tc := range []struct {
+		word, want string
+	}{
+		// Sufiks derivasi lalu prefiks
+		{"pembayaran", "bayar"},
+		{"mengirimkan", "kirim"},
+		{"pengiriman", "kirim"},
+		{"dibatalkan", "batal"},
+		{"tabungan", "tabung"},
+		{"pinjaman", "pinjam"},
+		// Peluluhan meng-/peng- + vokal
+		{"mengambil", "ambil"},
+		{"pengambilan", "ambil"},
+		{"mengubah", "ubah"},
+		{"mengaktifkan", "aktif"},
+		// Peluluhan men-/mem-/meny-
+		{"menabung", "tabung"},
+		{"membuka", "buka"},
+		{"menyusun", "susun"},
+		// Prefiks tanpa peluluhan dan dua lapis prefiks
+		{"diblokir", "blokir"},
+		{"ditransfer", "transfer"},
+		{"pertanyaan", "tanya"},
+		{"memperbarui", "baru"},
+		{"keamanan", "aman"},
+		// Partikel dan kata ganti kepunyaan
+		{"bisakah", "bisa"},
+		{"bukalah", "buka"},
+		{"kartunya", "kartu"},
+		{"rekeningku", "rekening"},
+		// Kata pendek, huruf besar, dan angka
+		{"BCA", "bca"},
+		{"Saldo", "saldo"},
+		{"123456", "123456"},
+	} {
+		if got := StemIndonesian(tc.word); got != tc.want {
+			t.Errorf("StemIndonesian(%q) = %q, ingin %q", tc.word, got, tc.want)
+		}
+	}
+}
+
+// Kata turunan harus menghasilkan stem yang sama dengan kata dasarnya agar kueri
+// "kirim" menemukan "mengirimkan" dan sebaliknya
+func TestStemIndonesianMatchesRoot(t *testing.T) {
+	for _, pair := range [][2]string{
+		{"mengirimkan", "kirim"},
+		{"pengambilan", "ambil"},
+		{"memakai", "pakai"},
+		{"dibukakan", "buka"},
+		{"menggunakan", "gunakan"},
+		{"memperbarui", "perbarui"},
+		{"keamanan", "aman"},
+	} {
+		derived, root := StemIndonesian(pair[0]), StemIndonesian(pair[1])
+		if derived != root {
+			t.Errorf("stem %q = %q, stem %q = %q; harus sama", pair[0], derived, pair[1], root)
+		}
+	}
+}
+
+func TestSearchStems(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want []string
+	}{
+		{"Saya ingin mengirimkan uang ke rekening BCA, apakah bisa dibatalkan?", []string{"kirim", "uang", "rekening", "bca", "batal"}},
+		{"Kirim, dikirim, pengiriman", []string{"kirim"}},
+		{"yang dan di ke", nil},
+		{"Tolong blokir kartu saya", []string{"blokir", "kartu"}},
+	} {
+		if got := SearchStems(tc.text); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("SearchStems(%q) = %q, ingin %q", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestTokenizeIndonesianKeepsOffsets(t *testing.T) {
+	text := "Café, transfer-nya!"
+	var got []TextToken
+	for _, tok := range TokenizeIndonesian(text) {
+		got = append(got, tok)
+		if text[tok.Start:tok.End] == "" {
+			t.Errorf("token %q tanpa posisi", tok.Word)
+		}
+	}
+	want := []TextToken{
+		{Word: "café", Stem: "café", Start: 0, End: 5},
+		{Word: "transfer", Stem: "transfer", Start: 7, End: 15},
+		{Word: "nya", Stem: "nya", Start: 16, End: 19},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TokenizeIndonesian = %+v, ingin %+v", got, want)
+	}
+}
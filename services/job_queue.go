@@ -0,0 +1,76 @@
+package services
+
+import (
+	"backend-go/config"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Job latar (suara, ekspor) disimpan di MongoDB sebagai sumber kebenaran. Worker
+// mengklaim job langsung dari collection; channel hanya sinyal bangun agar job baru
+// tidak menunggu interval polling, sehingga job tidak pernah tertahan walaupun
+// sinyal terlewat atau server berganti.
+
+// jobWaker membangunkan worker yang sedang menunggu polling berikutnya
+type jobWaker chan struct{}
+
+func newJobWaker() jobWaker {
+	return make(jobWaker, 1)
+}
+
+// wake tidak pernah memblokir; satu sinyal tertunda sudah cukup karena worker yang
+// bangun akan terus mengklaim sampai tidak ada job tersisa
+func (w jobWaker) wake() {
+	select {
+	case w <- struct{}{}:
+	default:
+	}
+}
+
+// runJobWorker memanggil claimAndRun sampai tidak ada job yang bisa diklaim, lalu
+// menunggu sinyal bangun atau interval polling. claimAndRun mengembalikan false bila
+// tidak ada job.
+func runJobWorker(w jobWaker, interval time.Duration, claimAndRun func() (bool, error)) {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			found, err := claimAndRun()
+			if err != nil {
+				config.Log.Error("Gagal mengklaim job: ", err)
+				break
+			}
+			if !found {
+				break
+			}
+			// Worker lain yang menganggur ikut memeriksa job berikutnya
+			w.wake()
+		}
+		select {
+		case <-w:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimableJobFilter memilih job yang menunggu, atau job berjalan yang sewanya sudah
+// habis karena workernya mati. Job berjalan tanpa locked_until (data lama) juga diambil.
+func claimableJobFilter(pending, running string, now time.Time) bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"status": pending},
+		bson.M{"status": running, "locked_until": bson.M{"$not": bson.M{"$gte": now}}},
+	}}
+}
+
+// jobWorkerID memberi identitas unik per worker untuk field owner
+func jobWorkerID(kind string, n int) string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s/%s/%d/%d", kind, host, os.Getpid(), n)
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxLabelsPerUser = 50
+	maxLabelNameLen  = 30
+	defaultLabelHex  = "#9E9E9E"
+)
+
+var (
+	ErrLabelNotFound = errors.New("label tidak ditemukan")
+	ErrLabelExists   = errors.New("label dengan nama tersebut sudah ada")
+	ErrLabelInvalid  = errors.New("nama label wajib diisi (maks. 30 karakter) dan warna harus berformat #rrggbb")
+	ErrLabelLimit    = errors.New("jumlah label sudah mencapai batas")
+)
+
+// ChatFlagsUpdate berisi flag yang ingin diubah; nil berarti tidak diubah
+type ChatFlagsUpdate struct {
+	Pinned   *bool `json:"pinned"`
+	Archived *bool `json:"archived"`
+	Starred  *bool `json:"starred"`
+}
+
+// SetChatFlags mengubah pinned/archived/starred tanpa mengubah updated_at
+// sehingga urutan chat berdasarkan aktivitas tetap terjaga
+func SetChatFlags(chatID string, userID int, update ChatFlagsUpdate) error {
+	set := bson.M{}
+	if update.Pinned != nil {
+		set["pinned"] = *update.Pinned
+	}
+	if update.Archived != nil {
+		set["archived"] = *update.Archived
+	}
+	if update.Starred != nil {
+		set["starred"] = *update.Starred
+	}
+	if len(set) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := config.MongoDB.Collection("conversations").UpdateOne(ctx,
+		bson.M{"chat_id": chatID, "user_id": userID, "deleted_at": notDeleted},
+		bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrChatNotFound
+	}
+	return nil
+}
+
+// SetChatLabels mengganti seluruh label sebuah chat; semua label harus milik user
+func SetChatLabels(chatID string, userID int, labelIDs []string) ([]models.Label, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	owned, err := userLabelMap(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(labelIDs))
+	labels := make([]models.Label, 0, len(labelIDs))
+	seen := map[primitive.ObjectID]bool{}
+	for _, raw := range labelIDs {
+		id, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			return nil, ErrLabelNotFound
+		}
+		label, ok := owned[id]
+		if !ok {
+			return nil, ErrLabelNotFound
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		labels = append(labels, label)
+	}
+
+	result, err := config.MongoDB.Collection("conversations").UpdateOne(ctx,
+		bson.M{"chat_id": chatID, "user_id": userID, "deleted_at": notDeleted},
+		bson.M{"$set": bson.M{"labels": ids}})
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrChatNotFound
+	}
+	return labels, nil
+}
+
+// ListLabels mengembalikan label milik user urut nama
+func ListLabels(userID int) ([]models.Label, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := config.MongoDB.Collection("labels").Find(ctx, bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	labels := []models.Label{}
+	if err := cursor.All(ctx, &labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// CreateLabel membuat label baru untuk user
+func CreateLabel(userID int, name, color string) (*models.Label, error) {
+	name, color, err := normalizeLabel(name, color)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	count, err := config.MongoDB.Collection("labels").CountDocuments(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxLabelsPerUser {
+		return nil, ErrLabelLimit
+	}
+
+	label := models.Label{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Name:      name,
+		Color:     color,
+		CreatedAt: time.Now(),
+	}
+	if _, err := config.MongoDB.Collection("labels").InsertOne(ctx, label); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrLabelExists
+		}
+		return nil, err
+	}
+	return &label, nil
+}
+
+// UpdateLabel mengganti nama dan/atau warna label
+func UpdateLabel(userID int, labelID, name, color string) (*models.Label, error) {
+	id, err := primitive.ObjectIDFromHex(labelID)
+	if err != nil {
+		return nil, ErrLabelNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var current models.Label
+	err = config.MongoDB.Collection("labels").FindOne(ctx, bson.M{"_id": id, "user_id": userID}).Decode(&current)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrLabelNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = current.Name
+	}
+	if color == "" {
+		color = current.Color
+	}
+	name, color, err = normalizeLabel(name, color)
+	if err != nil {
+		return nil, err
+	}
+
+	var label models.Label
+	err = config.MongoDB.Collection("labels").FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "user_id": userID},
+		bson.M{"$set": bson.M{"name": name, "color": color}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&label)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrLabelExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &label, nil
+}
+
+// DeleteLabel menghapus label dan melepasnya dari semua chat user
+func DeleteLabel(userID int, labelID string) error {
+	id, err := primitive.ObjectIDFromHex(labelID)
+	if err != nil {
+		return ErrLabelNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := config.MongoDB.Collection("labels").DeleteOne(ctx, bson.M{"_id": id, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrLabelNotFound
+	}
+
+	_, err = config.MongoDB.Collection("conversations").UpdateMany(ctx,
+		bson.M{"user_id": userID, "labels": id},
+		bson.M{"$pull": bson.M{"labels": id}})
+	return err
+}
+
+// userLabelMap memuat semua label user untuk melengkapi daftar chat
+func userLabelMap(ctx context.Context, userID int) (map[primitive.ObjectID]models.Label, error) {
+	cursor, err := config.MongoDB.Collection("labels").Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	var labels []models.Label
+	if err := cursor.All(ctx, &labels); err != nil {
+		return nil, err
+	}
+
+	byID := make(map[primitive.ObjectID]models.Label, len(labels))
+	for _, l := range labels {
+		byID[l.ID] = l
+	}
+	return byID, nil
+}
+
+func normalizeLabel(name, color string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxLabelNameLen {
+		return "", "", ErrLabelInvalid
+	}
+
+	if color == "" {
+		color = defaultLabelHex
+	}
+	color = strings.ToUpper(color)
+	if _, _, _, ok := parseHexColor(color); !ok {
+		return "", "", ErrLabelInvalid
+	}
+	return name, color, nil
+}
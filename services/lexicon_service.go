@@ -0,0 +1,190 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxLexiconTermLen  = 50
+	maxLexiconAliasLen = 200
+	// lexiconCacheTTL membatasi selisih leksikon antar instance setelah admin mengubahnya
+	lexiconCacheTTL = 5 * time.Minute
+)
+
+var (
+	ErrLexiconNotFound = errors.New("entri leksikon tidak ditemukan")
+	ErrLexiconExists   = errors.New("istilah tersebut sudah ada di leksikon")
+	ErrLexiconInvalid  = errors.New("istilah (maks. 50 karakter) dan alias (maks. 200 karakter) wajib diisi")
+)
+
+// lexiconCache menyimpan gabungan leksikon bawaan dan entri admin
+var lexiconCache = struct {
+	sync.Mutex
+	entries  map[string]string
+	loadedAt time.Time
+}{}
+
+// ListLexicon mengembalikan entri leksikon buatan admin urut istilah
+func ListLexicon(ctx context.Context) ([]models.LexiconEntry, error) {
+	cursor, err := config.MongoDB.Collection("tts_lexicon").Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "term", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	entries := []models.LexiconEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// CreateLexiconEntry menambah pelafalan baru
+func CreateLexiconEntry(ctx context.Context, adminID int, term, alias string) (*models.LexiconEntry, error) {
+	term, alias, err := normalizeLexicon(term, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entry := models.LexiconEntry{
+		ID:        primitive.NewObjectID(),
+		Term:      term,
+		Alias:     alias,
+		CreatedBy: adminID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := config.MongoDB.Collection("tts_lexicon").InsertOne(ctx, entry); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrLexiconExists
+		}
+		return nil, err
+	}
+	invalidateLexicon()
+	return &entry, nil
+}
+
+// UpdateLexiconEntry mengganti istilah dan/atau alias sebuah entri
+func UpdateLexiconEntry(ctx context.Context, entryID, term, alias string) (*models.LexiconEntry, error) {
+	id, err := primitive.ObjectIDFromHex(entryID)
+	if err != nil {
+		return nil, ErrLexiconNotFound
+	}
+
+	var current models.LexiconEntry
+	err = config.MongoDB.Collection("tts_lexicon").FindOne(ctx, bson.M{"_id": id}).Decode(&current)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrLexiconNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if term == "" {
+		term = current.Term
+	}
+	if alias == "" {
+		alias = current.Alias
+	}
+	term, alias, err = normalizeLexicon(term, alias)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry models.LexiconEntry
+	err = config.MongoDB.Collection("tts_lexicon").FindOneAndUpdate(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"term": term, "alias": alias, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&entry)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrLexiconExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	invalidateLexicon()
+	return &entry, nil
+}
+
+// DeleteLexiconEntry menghapus entri; istilah bawaan dengan nama sama kembali berlaku
+func DeleteLexiconEntry(ctx context.Context, entryID string) error {
+	id, err := primitive.ObjectIDFromHex(entryID)
+	if err != nil {
+		return ErrLexiconNotFound
+	}
+
+	result, err := config.MongoDB.Collection("tts_lexicon").DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrLexiconNotFound
+	}
+	invalidateLexicon()
+	return nil
+}
+
+func normalizeLexicon(term, alias string) (string, string, error) {
+	term = strings.TrimSpace(term)
+	alias = strings.Join(strings.Fields(alias), " ")
+	if term == "" || alias == "" || utf8.RuneCountInString(term) > maxLexiconTermLen ||
+		utf8.RuneCountInString(alias) > maxLexiconAliasLen {
+		return "", "", ErrLexiconInvalid
+	}
+	return term, alias, nil
+}
+
+func invalidateLexicon() {
+	lexiconCache.Lock()
+	lexiconCache.entries = nil
+	lexiconCache.Unlock()
+}
+
+// activeLexicon mengembalikan leksikon bawaan ditimpa entri admin. Bila Mongo gagal
+// dibaca, leksikon terakhir (atau bawaan) tetap dipakai agar TTS tidak ikut gagal.
+func activeLexicon(ctx context.Context) map[string]string {
+	lexiconCache.Lock()
+	defer lexiconCache.Unlock()
+
+	if lexiconCache.entries != nil && time.Since(lexiconCache.loadedAt) < lexiconCacheTTL {
+		return lexiconCache.entries
+	}
+
+	entries, err := ListLexicon(ctx)
+	if err != nil {
+		config.Log.Warn("Gagal memuat leksikon TTS: ", err)
+		if lexiconCache.entries != nil {
+			return lexiconCache.entries
+		}
+		return defaultLexicon
+	}
+
+	merged := make(map[string]string, len(defaultLexicon)+len(entries))
+	for term, alias := range defaultLexicon {
+		merged[term] = alias
+	}
+	for _, e := range entries {
+		merged[e.Term] = e.Alias
+	}
+	lexiconCache.entries = merged
+	lexiconCache.loadedAt = time.Now()
+	return merged
+}
+
+// PreviewSpeechText menampilkan SSML dan teks biasa yang akan dikirim ke TTS
+func PreviewSpeechText(ctx context.Context, text string) SpeechText {
+	return BuildSpeechText(text, activeLexicon(ctx))
+}
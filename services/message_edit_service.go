@@ -0,0 +1,193 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrMessageNotFound    = errors.New("pesan tidak ditemukan")
+	ErrEditTextEmpty      = errors.New("teks suntingan tidak boleh kosong")
+	ErrMessageNotEditable = errors.New("hanya pesan pengguna yang dapat disunting")
+	ErrMessageSuperseded  = errors.New("pesan ini sudah digantikan oleh suntingan lain")
+)
+
+// EditResult berisi pasangan pesan baru hasil suntingan dan ID pasangan lama yang digantikan
+type EditResult struct {
+	UserMessage models.Message       `json:"user_message"`
+	BotMessage  models.Message       `json:"bot_message"`
+	Superseded  []primitive.ObjectID `json:"superseded"`
+	Escalate    bool                 `json:"escalate"`
+}
+
+// EditUserMessage menyunting pesan teks atau mengoreksi transkrip suara, menjalankan ulang
+// NLP, lalu menambahkan pasangan baru di akhir riwayat. Pasangan lama tetap tersimpan dan
+// ditandai superseded_by sehingga tidak ikut dihitung analitik.
+func EditUserMessage(chatID string, userID int, username, messageID, newText string) (*EditResult, error) {
+	newText = strings.TrimSpace(newText)
+	if newText == "" {
+		return nil, ErrEditTextEmpty
+	}
+
+	originalID, err := primitive.ObjectIDFromHex(messageID)
+	if err != nil {
+		return nil, ErrMessageNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	messages := config.MongoDB.Collection("messages")
+
+	var original models.Message
+	err = messages.FindOne(ctx, bson.M{"_id": originalID, "chat_id": chatID, "user_id": userID}).Decode(&original)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if original.Sender != "user" {
+		return nil, ErrMessageNotEditable
+	}
+	if original.SupersededBy != nil {
+		return nil, ErrMessageSuperseded
+	}
+
+	moderation := ModerateMessage(userID, newText)
+	if moderation.Action == ModerationReject {
+		return nil, &ErrMessageRejected{Reasons: moderation.Reasons}
+	}
+
+	nlpResp, err := CallNLPService(newText)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	userMsg := models.Message{
+		ID:        primitive.NewObjectID(),
+		Modality:  original.Modality,
+		Sender:    "user",
+		Intent:    nlpResp.Intent,
+		Timestamp: now,
+		Revises:   &original.ID,
+	}
+	botMsg := models.Message{
+		Modality:   original.Modality,
+		Sender:     "bot",
+		Intent:     nlpResp.Intent,
+		Confidence: nlpResp.Confidence,
+		Timestamp:  now.Add(time.Millisecond),
+	}
+	if moderation.Action == ModerationFlag {
+		userMsg.Flags = moderation.Reasons
+	}
+
+	if original.Modality == models.ModalityVoice {
+		// Rekaman tetap sama; hanya transkripnya yang dikoreksi
+		userMsg.Audio = original.Audio
+		userMsg.Transcript = newText
+		botMsg.Transcript = nlpResp.ResponseMessage
+		botMsg.Audio, err = synthesizeReplyAudio(userID, nlpResp.ResponseMessage)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		userMsg.Content = newText
+		botMsg.Content = nlpResp.ResponseMessage
+	}
+
+	// Klaim pesan asli lebih dulu agar dua suntingan bersamaan tidak sama-sama berhasil
+	claim, err := messages.UpdateOne(ctx,
+		bson.M{"_id": original.ID, "superseded_by": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"superseded_by": userMsg.ID}})
+	if err != nil {
+		return nil, err
+	}
+	if claim.ModifiedCount == 0 {
+		return nil, ErrMessageSuperseded
+	}
+
+	ProtectMessage(&userMsg)
+	ProtectMessage(&botMsg)
+
+	if err := SaveMessages(ctx, chatID, userID, username, &userMsg, &botMsg); err != nil {
+		// Kembalikan klaim agar pesan asli dapat disunting ulang
+		_, _ = messages.UpdateOne(ctx, bson.M{"_id": original.ID, "superseded_by": userMsg.ID},
+			bson.M{"$unset": bson.M{"superseded_by": ""}})
+		return nil, fmt.Errorf("gagal menyimpan suntingan: %w", err)
+	}
+
+	replies, err := originalReplyIDs(ctx, original)
+	if err != nil {
+		return nil, err
+	}
+	if len(replies) > 0 {
+		_, err = messages.UpdateMany(ctx,
+			bson.M{"_id": bson.M{"$in": replies}, "superseded_by": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"superseded_by": userMsg.ID}})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	superseded := append([]primitive.ObjectID{original.ID}, replies...)
+	unindexMessages(superseded)
+
+	return &EditResult{
+		UserMessage: userMsg,
+		BotMessage:  botMsg,
+		Superseded:  superseded,
+		Escalate:    nlpResp.Confidence < 0.6,
+	}, nil
+}
+
+// originalReplyIDs mencari balasan bot untuk pesan user. Pesan lama tanpa reply_to
+// dicocokkan lewat seq berikutnya.
+func originalReplyIDs(ctx context.Context, original models.Message) ([]primitive.ObjectID, error) {
+	filter := bson.M{
+		"chat_id": original.ChatID,
+		"sender":  "bot",
+		"$or": bson.A{
+			bson.M{"reply_to": original.ID},
+			bson.M{"reply_to": bson.M{"$exists": false}, "seq": original.Seq + 1},
+		},
+	}
+	cursor, err := config.MongoDB.Collection("messages").Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var replies []models.Message
+	if err := cursor.All(ctx, &replies); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(replies))
+	for _, r := range replies {
+		ids = append(ids, r.ID)
+	}
+	return ids, nil
+}
+
+// synthesizeReplyAudio membuat audio balasan bot untuk koreksi transkrip suara
+func synthesizeReplyAudio(userID int, text string) (*models.AudioAttachment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*config.TTSTimeout)
+	defer cancel()
+
+	audio, err := SynthesizeToS3(ctx, TTSRequest{Text: text, Voice: userVoiceSettings(ctx, userID)})
+	if err != nil {
+		return nil, err
+	}
+	return &models.AudioAttachment{URL: audio.URL, Format: audio.Format}, nil
+}
@@ -0,0 +1,538 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MigrationStats merangkum hasil satu langkah migrasi
+type MigrationStats struct {
+	Conversations int
+	Messages      int
+	VoiceMessages int
+	Skipped       int
+}
+
+// legacyTextMessage adalah bentuk lama pesan teks (timestamp string RFC3339, field message)
+type legacyTextMessage struct {
+	Seq               int64             `bson:"seq"`
+	Sender            string            `bson:"sender"`
+	Message           string            `bson:"message"`
+	Intent            string            `bson:"intent"`
+	Confidence        float64           `bson:"confidence"`
+	Timestamp         string            `bson:"timestamp"`
+	PII               *models.PIIReport `bson:"pii"`
+	EncryptedOriginal string            `bson:"encrypted_original"`
+	Flags             []string          `bson:"flags"`
+}
+
+// legacyVoiceMessage adalah dokumen lama di collection voice_messages
+type legacyVoiceMessage struct {
+	ID                primitive.ObjectID `bson:"_id"`
+	ChatID            string             `bson:"chat_id"`
+	UserID            int                `bson:"user_id"`
+	Seq               int64              `bson:"seq"`
+	Sender            string             `bson:"sender"`
+	AudioURL          string             `bson:"audio_url"`
+	Transcript        string             `bson:"transcript"`
+	Intent            string             `bson:"intent"`
+	Timestamp         time.Time          `bson:"timestamp"`
+	PII               *models.PIIReport  `bson:"pii"`
+	EncryptedOriginal string             `bson:"encrypted_original"`
+}
+
+// legacyConversation adalah bentuk lama percakapan dengan pesan tertanam di array messages
+type legacyConversation struct {
+	ChatID   string              `bson:"chat_id"`
+	UserID   int                 `bson:"user_id"`
+	Messages []legacyTextMessage `bson:"messages"`
+}
+
+func (m legacyTextMessage) toMessage() models.Message {
+	t, _ := time.Parse(time.RFC3339, m.Timestamp)
+	return models.Message{
+		Modality:          models.ModalityText,
+		Sender:            m.Sender,
+		Content:           m.Message,
+		Intent:            m.Intent,
+		Confidence:        m.Confidence,
+		Timestamp:         t,
+		PII:               m.PII,
+		EncryptedOriginal: m.EncryptedOriginal,
+		Flags:             m.Flags,
+	}
+}
+
+func (v legacyVoiceMessage) toMessage() models.Message {
+	return models.Message{
+		ID:                v.ID,
+		ChatID:            v.ChatID,
+		UserID:            v.UserID,
+		Seq:               v.Seq,
+		Modality:          models.ModalityVoice,
+		Sender:            v.Sender,
+		Audio:             &models.AudioAttachment{URL: v.AudioURL, Format: "mp3"},
+		Transcript:        v.Transcript,
+		Intent:            v.Intent,
+		Timestamp:         v.Timestamp,
+		PII:               v.PII,
+		EncryptedOriginal: v.EncryptedOriginal,
+	}
+}
+
+// MigrateEmbeddedMessages memindahkan Conversation.messages dan voice_messages milik chat
+// tersebut ke collection messages dalam skema terpadu. Semua pesan dalam satu chat diberi
+// ulang nomor urut 1..N berdasarkan waktu. Jalankan saat server tidak menerima trafik.
+func MigrateEmbeddedMessages(ctx context.Context, dryRun bool) (MigrationStats, error) {
+	var stats MigrationStats
+
+	conversations := config.MongoDB.Collection("conversations")
+	cursor, err := conversations.Find(ctx, bson.M{"messages": bson.M{"$exists": true}})
+	if err != nil {
+		return stats, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var convo legacyConversation
+		if err := cursor.Decode(&convo); err != nil {
+			return stats, fmt.Errorf("gagal membaca percakapan: %v", err)
+		}
+
+		// Chat yang sudah memiliki pesan di collection baru tidak diproses ulang
+		existing, err := config.MongoDB.Collection("messages").CountDocuments(ctx, bson.M{"chat_id": convo.ChatID})
+		if err != nil {
+			return stats, err
+		}
+		if existing > 0 {
+			stats.Skipped++
+			continue
+		}
+
+		textCount, voiceCount, err := migrateConversation(ctx, convo, dryRun)
+		if err != nil {
+			return stats, fmt.Errorf("gagal migrasi chat %s: %v", convo.ChatID, err)
+		}
+
+		stats.Conversations++
+		stats.Messages += textCount
+		stats.VoiceMessages += voiceCount
+	}
+
+	return stats, cursor.Err()
+}
+
+func migrateConversation(ctx context.Context, convo legacyConversation, dryRun bool) (int, int, error) {
+	type entry struct {
+		msg   models.Message
+		order int
+	}
+	var timeline []entry
+
+	for i, legacy := range convo.Messages {
+		timeline = append(timeline, entry{msg: legacy.toMessage(), order: i})
+	}
+
+	voices, err := findLegacyVoiceMessages(ctx, bson.M{"chat_id": convo.ChatID})
+	if err != nil {
+		return 0, 0, err
+	}
+	for i, v := range voices {
+		timeline = append(timeline, entry{msg: v.toMessage(), order: len(convo.Messages) + i})
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool {
+		a, b := timeline[i].msg.Timestamp, timeline[j].msg.Timestamp
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return timeline[i].order < timeline[j].order
+	})
+
+	if dryRun {
+		return len(convo.Messages), len(voices), nil
+	}
+
+	docs := make([]interface{}, 0, len(timeline))
+	for i := range timeline {
+		msg := &timeline[i].msg
+		if msg.ID.IsZero() {
+			msg.ID = primitive.NewObjectID()
+		}
+		msg.ChatID = convo.ChatID
+		msg.UserID = convo.UserID
+		msg.Seq = int64(i + 1)
+		msg.SearchText = BuildSearchText(msg.Text())
+		docs = append(docs, msg)
+	}
+
+	if len(docs) > 0 {
+		if _, err := config.MongoDB.Collection("messages").InsertMany(ctx, docs); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	set := bson.M{"message_count": int64(len(timeline))}
+	if len(timeline) > 0 {
+		last := timeline[len(timeline)-1].msg
+		preview := NewMessagePreview(last.Sender, last.Text(), last.Timestamp)
+		preview.Seq = last.Seq
+		set["last_message"] = preview
+	}
+	_, err = config.MongoDB.Collection("conversations").UpdateOne(ctx,
+		bson.M{"chat_id": convo.ChatID},
+		bson.M{"$set": set, "$unset": bson.M{"messages": ""}},
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if len(voices) > 0 {
+		if _, err := config.MongoDB.Collection("voice_messages").DeleteMany(ctx, bson.M{"chat_id": convo.ChatID}); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return len(convo.Messages), len(voices), nil
+}
+
+// MigrateUnifiedMessages mengubah sisa data lama ke skema pesan terpadu:
+// dokumen messages dengan field message/timestamp string, dan voice_messages yang tersisa.
+func MigrateUnifiedMessages(ctx context.Context, dryRun bool) (MigrationStats, error) {
+	var stats MigrationStats
+
+	messages := config.MongoDB.Collection("messages")
+
+	// 1. Pesan teks hasil migrasi sebelumnya: rename message -> content, timestamp -> Date
+	legacyFilter := bson.M{"message": bson.M{"$exists": true}}
+	if dryRun {
+		n, err := messages.CountDocuments(ctx, legacyFilter)
+		if err != nil {
+			return stats, err
+		}
+		stats.Messages = int(n)
+	} else {
+		res, err := messages.UpdateMany(ctx, legacyFilter, mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{
+				"modality": bson.M{"$ifNull": bson.A{"$modality", models.ModalityText}},
+				"content":  "$message",
+				"timestamp": bson.M{"$convert": bson.M{
+					"input": "$timestamp", "to": "date", "onError": "$$NOW", "onNull": "$$NOW",
+				}},
+			}}},
+			{{Key: "$unset", Value: "message"}},
+		})
+		if err != nil {
+			return stats, fmt.Errorf("gagal mengubah pesan teks lama: %v", err)
+		}
+		stats.Messages = int(res.ModifiedCount)
+	}
+
+	// 2. Sisa voice_messages: pertahankan seq bila ada, jika tidak pesan seq baru
+	voices, err := findLegacyVoiceMessages(ctx, bson.M{})
+	if err != nil {
+		return stats, err
+	}
+	stats.VoiceMessages = len(voices)
+	if dryRun {
+		return stats, nil
+	}
+
+	chats := map[string]bool{}
+	for _, v := range voices {
+		msg := v.toMessage()
+		if msg.Seq == 0 {
+			seq, err := ReserveMessageSeq(ctx, v.ChatID, v.UserID, "", 1, NewMessagePreview(msg.Sender, msg.Text(), msg.Timestamp), nil)
+			if err != nil {
+				return stats, fmt.Errorf("gagal memesan seq untuk chat %s: %v", v.ChatID, err)
+			}
+			msg.Seq = seq
+		}
+		msg.SearchText = BuildSearchText(msg.Text())
+
+		// _id dipertahankan sehingga migrasi aman dijalankan ulang
+		if _, err := messages.InsertOne(ctx, msg); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return stats, fmt.Errorf("gagal memindahkan pesan suara %s: %v", v.ID.Hex(), err)
+		}
+		if _, err := config.MongoDB.Collection("voice_messages").DeleteOne(ctx, bson.M{"_id": v.ID}); err != nil {
+			return stats, err
+		}
+		chats[v.ChatID] = true
+	}
+	stats.Conversations = len(chats)
+
+	return stats, nil
+}
+
+// BackfillConversationFlags mengisi pinned/archived/starred=false pada percakapan lama
+// agar urutan "disematkan lebih dulu" konsisten dengan paginasi cursor
+func BackfillConversationFlags(ctx context.Context, dryRun bool) (MigrationStats, error) {
+	var stats MigrationStats
+
+	conversations := config.MongoDB.Collection("conversations")
+	for _, field := range []string{"pinned", "archived", "starred"} {
+		filter := bson.M{field: bson.M{"$exists": false}}
+		if dryRun {
+			n, err := conversations.CountDocuments(ctx, filter)
+			if err != nil {
+				return stats, err
+			}
+			stats.Conversations += int(n)
+			continue
+		}
+		res, err := conversations.UpdateMany(ctx, filter, bson.M{"$set": bson.M{field: false}})
+		if err != nil {
+			return stats, fmt.Errorf("gagal mengisi field %s: %v", field, err)
+		}
+		stats.Conversations += int(res.ModifiedCount)
+	}
+	return stats, nil
+}
+
+// BackfillSearchText mengisi field search_text untuk pesan yang disimpan sebelum
+// pencarian tersedia, atau setelah aturan stemming berubah (all=true)
+func BackfillSearchText(ctx context.Context, all, dryRun bool) (MigrationStats, error) {
+	var stats MigrationStats
+
+	messages := config.MongoDB.Collection("messages")
+	filter := bson.M{"search_text": bson.M{"$exists": false}}
+	if all {
+		filter = bson.M{}
+	}
+	if dryRun {
+		n, err := messages.CountDocuments(ctx, filter)
+		stats.Messages = int(n)
+		return stats, err
+	}
+
+	opts := options.Find().SetProjection(bson.M{"content": 1, "transcript": 1})
+	cursor, err := messages.Find(ctx, filter, opts)
+	if err != nil {
+		return stats, err
+	}
+	defer cursor.Close(ctx)
+
+	var batch []mongo.WriteModel
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := messages.BulkWrite(ctx, batch, options.BulkWrite().SetOrdered(false)); err != nil {
+			return err
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var msg models.Message
+		if err := cursor.Decode(&msg); err != nil {
+			return stats, err
+		}
+		batch = append(batch, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": msg.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"search_text": BuildSearchText(msg.Text())}}))
+		stats.Messages++
+
+		if len(batch) >= 500 {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return stats, err
+	}
+	return stats, flush()
+}
+
+// findLegacyVoiceMessages membaca voice_messages urut seq lalu timestamp
+func findLegacyVoiceMessages(ctx context.Context, filter bson.M) ([]legacyVoiceMessage, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "chat_id", Value: 1}, {Key: "seq", Value: 1}, {Key: "timestamp", Value: 1}})
+	cursor, err := config.MongoDB.Collection("voice_messages").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var voices []legacyVoiceMessage
+	if err := cursor.All(ctx, &voices); err != nil {
+		return nil, err
+	}
+	return voices, nil
+}
+
+// duplicateConversation adalah satu dokumen percakapan ganda beserta pesan tertanam lama
+type duplicateConversation struct {
+	models.Conversation `bson:",inline"`
+	Messages            []bson.Raw `bson:"messages,omitempty"`
+}
+
+// DedupeConversations menggabungkan dokumen percakapan ganda per chat_id yang tercipta
+// sebelum indeks unik chat_id ada: dokumen tertua dipertahankan, label/outbox/pesan
+// tertanam digabung, seq pesan diberi ulang 1..N, lalu dokumen lain dihapus. Chat yang
+// dokumennya dimiliki user berbeda dilewati dan harus diselesaikan manual. Jalankan
+// sebelum indeks chat_id_unique dibuat dan saat server tidak menerima trafik.
+func DedupeConversations(ctx context.Context, dryRun bool) (MigrationStats, error) {
+	var stats MigrationStats
+
+	conversations := config.MongoDB.Collection("conversations")
+	cursor, err := conversations.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$chat_id", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return stats, err
+	}
+	var groups []struct {
+		ChatID string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return stats, err
+	}
+
+	for _, g := range groups {
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+		cursor, err := conversations.Find(ctx, bson.M{"chat_id": g.ChatID}, opts)
+		if err != nil {
+			return stats, err
+		}
+		var docs []duplicateConversation
+		if err := cursor.All(ctx, &docs); err != nil {
+			return stats, fmt.Errorf("gagal membaca percakapan ganda %s: %v", g.ChatID, err)
+		}
+		if len(docs) < 2 {
+			continue
+		}
+
+		owner := docs[0].UserID
+		mixed := false
+		for _, d := range docs[1:] {
+			mixed = mixed || d.UserID != owner
+		}
+		if mixed {
+			config.Log.Warnf("Chat %s memiliki dokumen milik user berbeda, lewati dan selesaikan manual", g.ChatID)
+			stats.Skipped++
+			continue
+		}
+
+		renumbered, err := mergeDuplicateConversations(ctx, docs, dryRun)
+		if err != nil {
+			return stats, fmt.Errorf("gagal menggabungkan chat %s: %v", g.ChatID, err)
+		}
+		stats.Conversations++
+		stats.Messages += renumbered
+	}
+	return stats, nil
+}
+
+// mergeDuplicateConversations menggabungkan docs[1:] ke docs[0] dan mengembalikan jumlah
+// pesan yang diberi nomor urut ulang
+func mergeDuplicateConversations(ctx context.Context, docs []duplicateConversation, dryRun bool) (int, error) {
+	keep := docs[0]
+	chatID := keep.ChatID
+
+	messages := config.MongoDB.Collection("messages")
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: 1}, {Key: "seq", Value: 1}, {Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"sender": 1, "content": 1, "transcript": 1, "timestamp": 1})
+	cursor, err := messages.Find(ctx, bson.M{"chat_id": chatID}, opts)
+	if err != nil {
+		return 0, err
+	}
+	var timeline []models.Message
+	if err := cursor.All(ctx, &timeline); err != nil {
+		return 0, err
+	}
+	if dryRun {
+		return len(timeline), nil
+	}
+
+	// Dua tahap (negatif lalu positif) agar indeks unik chat_id+seq, bila sudah ada,
+	// tidak bentrok dengan nomor lama selama penomoran ulang
+	for _, sign := range []int64{-1, 1} {
+		var batch []mongo.WriteModel
+		for i, msg := range timeline {
+			batch = append(batch, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": msg.ID}).
+				SetUpdate(bson.M{"$set": bson.M{"seq": sign * int64(i+1), "user_id": keep.UserID}}))
+			if len(batch) >= 500 {
+				if _, err := messages.BulkWrite(ctx, batch); err != nil {
+					return 0, err
+				}
+				batch = batch[:0]
+			}
+		}
+		if len(batch) > 0 {
+			if _, err := messages.BulkWrite(ctx, batch); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	set := bson.M{"message_count": int64(len(timeline))}
+	if len(timeline) > 0 {
+		last := timeline[len(timeline)-1]
+		preview := NewMessagePreview(last.Sender, last.Text(), last.Timestamp)
+		preview.Seq = int64(len(timeline))
+		set["last_message"] = preview
+	}
+
+	var labels []primitive.ObjectID
+	var outbox []models.OutboxItem
+	var embedded []bson.Raw
+	pinned, starred, archived := false, false, true
+	deleted := true
+	updatedAt := keep.UpdatedAt
+	extras := make([]primitive.ObjectID, 0, len(docs)-1)
+	for i, d := range docs {
+		labels = append(labels, d.Labels...)
+		outbox = append(outbox, d.Outbox...)
+		embedded = append(embedded, d.Messages...)
+		pinned = pinned || d.Pinned
+		starred = starred || d.Starred
+		archived = archived && d.Archived
+		deleted = deleted && d.DeletedAt != nil
+		if d.UpdatedAt.After(updatedAt) {
+			updatedAt = d.UpdatedAt
+		}
+		if i > 0 {
+			extras = append(extras, d.ID)
+		}
+	}
+	set["pinned"] = pinned
+	set["starred"] = starred
+	set["archived"] = archived
+	set["updated_at"] = updatedAt
+	if len(outbox) > 0 {
+		set["outbox"] = outbox
+	}
+	if len(embedded) > 0 {
+		// Pesan tertanam lama ikut digabung; langkah embedded-messages menomori ulang
+		set["messages"] = embedded
+	}
+
+	update := bson.M{"$set": set}
+	if len(labels) > 0 {
+		update["$addToSet"] = bson.M{"labels": bson.M{"$each": labels}}
+	}
+	if !deleted {
+		update["$unset"] = bson.M{"deleted_at": ""}
+	}
+
+	conversations := config.MongoDB.Collection("conversations")
+	if _, err := conversations.UpdateOne(ctx, bson.M{"_id": keep.ID}, update); err != nil {
+		return 0, err
+	}
+	if _, err := conversations.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": extras}}); err != nil {
+		return 0, err
+	}
+	return len(timeline), nil
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"backend-go/config"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// useTestMongo menghubungkan config.MongoDB ke database sementara di MONGO_TEST_URI
+// (mis. mongodb://localhost:27017/?replicaSet=rs0) dan menghapusnya setelah test.
+// Test dilewati bila MONGO_TEST_URI tidak diisi.
+func useTestMongo(t *testing.T) {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI tidak diisi")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatalf("gagal terhubung ke MongoDB: %v", err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatalf("gagal ping MongoDB: %v", err)
+	}
+
+	prevClient, prevDB, prevTx := config.MongoClient, config.MongoDB, config.MongoTransactions
+	config.MongoClient = client
+	config.MongoDB = client.Database(fmt.Sprintf("chat_test_%d", time.Now().UnixNano()))
+	config.MongoTransactions = config.DetectMongoTransactions(ctx, client)
+	if err := config.EnsureMongoIndexes(); err != nil {
+		t.Fatalf("gagal membuat indeks: %v", err)
+	}
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		config.MongoDB.Drop(ctx)
+		client.Disconnect(ctx)
+		config.MongoClient, config.MongoDB, config.MongoTransactions = prevClient, prevDB, prevTx
+	})
+}
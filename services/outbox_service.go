@@ -0,0 +1,365 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// outboxLease menahan item yang sedang dikirim agar tidak diambil dispatcher lain
+	outboxLease      = 2 * time.Minute
+	outboxMaxBackoff = time.Hour
+	outboxBatchSize  = 100
+)
+
+var ErrOutboxItemNotFound = errors.New("item outbox tidak ditemukan")
+
+// outboxHandlers memetakan jenis item ke fungsi pengirimnya
+var outboxHandlers = map[string]func(ctx context.Context, item models.OutboxItem) error{
+	models.OutboxKindLastChatID: dispatchLastChatID,
+	models.OutboxKindWebhook:    dispatchWebhook,
+}
+
+// outboxKick membangunkan dispatcher segera setelah ada item baru
+var outboxKick = make(chan struct{}, 1)
+
+var outboxHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// OutboxEntry adalah item outbox untuk tampilan admin, ditandai jika sudah macet
+type OutboxEntry struct {
+	models.OutboxItem
+	Stuck bool `json:"stuck"`
+}
+
+// messageEvent adalah payload webhook message.created; teks pesan tidak ikut dikirim
+type messageEvent struct {
+	Event    string              `json:"event"`
+	ChatID   string              `json:"chat_id"`
+	UserID   int                 `json:"user_id"`
+	Messages []messageEventEntry `json:"messages"`
+}
+
+type messageEventEntry struct {
+	ID        string    `json:"id"`
+	Sender    string    `json:"sender"`
+	Modality  string    `json:"modality"`
+	Intent    string    `json:"intent,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// lastChatEvent adalah payload item last_chat_id: waktu pesan yang memicunya
+type lastChatEvent struct {
+	At time.Time `json:"at"`
+}
+
+// newOutboxItem membuat item yang siap dikirim secepatnya
+func newOutboxItem(kind, chatID string, userID int, payload string) models.OutboxItem {
+	now := time.Now()
+	return models.OutboxItem{
+		ID:            primitive.NewObjectID(),
+		Kind:          kind,
+		UserID:        userID,
+		ChatID:        chatID,
+		Payload:       payload,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
+
+// messageOutboxItems menyusun efek samping penulisan pesan. Item ini ikut ditulis dalam
+// update atomik ReserveMessageSeq sehingga tidak ada efek samping yang hilang bila
+// server mati setelah pesan tersimpan. ID pesan harus sudah terisi.
+func messageOutboxItems(chatID string, userID int, msgs []*models.Message) ([]models.OutboxItem, error) {
+	lastChat, err := json.Marshal(lastChatEvent{At: msgs[len(msgs)-1].Timestamp})
+	if err != nil {
+		return nil, err
+	}
+	items := []models.OutboxItem{newOutboxItem(models.OutboxKindLastChatID, chatID, userID, string(lastChat))}
+	if config.OutboxWebhookURL == "" {
+		return items, nil
+	}
+
+	event := messageEvent{Event: "message.created", ChatID: chatID, UserID: userID}
+	for _, m := range msgs {
+		event.Messages = append(event.Messages, messageEventEntry{
+			ID:        m.ID.Hex(),
+			Sender:    m.Sender,
+			Modality:  m.Modality,
+			Intent:    m.Intent,
+			Timestamp: m.Timestamp,
+		})
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return append(items, newOutboxItem(models.OutboxKindWebhook, chatID, userID, string(payload))), nil
+}
+
+// kickOutbox meminta dispatcher segera memproses item baru tanpa menunggu interval
+func kickOutbox() {
+	select {
+	case outboxKick <- struct{}{}:
+	default:
+	}
+}
+
+// StartOutboxDispatcher mengirim item outbox secara berkala dan setiap kali ada item baru
+func StartOutboxDispatcher(interval time.Duration) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			dispatchOutbox()
+			select {
+			case <-ticker.C:
+			case <-outboxKick:
+			}
+		}
+	}()
+
+	config.Log.Infof("📮 Dispatcher outbox berjalan setiap %s", interval)
+}
+
+func dispatchOutbox() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	now := time.Now()
+	cursor, err := config.MongoDB.Collection("conversations").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"outbox.next_attempt_at": bson.M{"$lte": now}}}},
+		{{Key: "$unwind", Value: "$outbox"}},
+		{{Key: "$match", Value: bson.M{"outbox.next_attempt_at": bson.M{"$lte": now}}}},
+		{{Key: "$sort", Value: bson.M{"outbox.created_at": 1}}},
+		{{Key: "$limit", Value: outboxBatchSize}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$outbox"}}},
+	})
+	if err != nil {
+		config.Log.Error("Gagal memuat outbox: ", err)
+		return
+	}
+	var items []models.OutboxItem
+	if err := cursor.All(ctx, &items); err != nil {
+		config.Log.Error("Gagal memuat outbox: ", err)
+		return
+	}
+
+	for _, item := range items {
+		dispatchOutboxItem(ctx, item)
+	}
+}
+
+func dispatchOutboxItem(ctx context.Context, item models.OutboxItem) {
+	coll := config.MongoDB.Collection("conversations")
+
+	// Klaim item dengan memajukan next_attempt_at; gagal klaim berarti sudah diambil instance lain
+	claim, err := coll.UpdateOne(ctx,
+		bson.M{"outbox": bson.M{"$elemMatch": bson.M{"_id": item.ID, "next_attempt_at": item.NextAttemptAt}}},
+		bson.M{"$set": bson.M{"outbox.$.next_attempt_at": time.Now().Add(outboxLease)}})
+	if err != nil || claim.ModifiedCount == 0 {
+		return
+	}
+
+	handler, ok := outboxHandlers[item.Kind]
+	if !ok {
+		err = fmt.Errorf("jenis outbox tidak dikenal: %s", item.Kind)
+	} else {
+		err = handler(ctx, item)
+	}
+
+	if err == nil {
+		_, err = coll.UpdateOne(ctx, bson.M{"outbox._id": item.ID}, bson.M{"$pull": bson.M{"outbox": bson.M{"_id": item.ID}}})
+		if err != nil {
+			config.Log.Warn("Gagal menghapus item outbox terkirim: ", err)
+		}
+		return
+	}
+
+	attempts := item.Attempts + 1
+	if attempts == config.OutboxStuckAttempts {
+		config.Log.Errorf("Item outbox %s (%s) macet setelah %d percobaan: %v", item.ID.Hex(), item.Kind, attempts, err)
+	}
+	_, uerr := coll.UpdateOne(ctx, bson.M{"outbox._id": item.ID}, bson.M{"$set": bson.M{
+		"outbox.$.attempts":        attempts,
+		"outbox.$.last_error":      err.Error(),
+		"outbox.$.next_attempt_at": time.Now().Add(outboxBackoff(attempts)),
+	}})
+	if uerr != nil {
+		config.Log.Warn("Gagal menjadwalkan ulang item outbox: ", uerr)
+	}
+}
+
+// outboxBackoff menggandakan jeda tiap percobaan (5 detik, 10 detik, ...) hingga maks. 1 jam
+func outboxBackoff(attempts int) time.Duration {
+	d := 5 * time.Second
+	for i := 1; i < attempts && d < outboxMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, outboxMaxBackoff)
+}
+
+// dispatchLastChatID hanya menimpa last_chat_id bila pesan pemicunya tidak lebih lama
+// dari yang sudah tercatat, karena item dari chat lain bisa terkirim belakangan
+// (retry, backoff, atau dispatcher lain)
+func dispatchLastChatID(ctx context.Context, item models.OutboxItem) error {
+	// Item lama tanpa payload memakai waktu item dibuat
+	event := lastChatEvent{At: item.CreatedAt}
+	if item.Payload != "" {
+		if err := json.Unmarshal([]byte(item.Payload), &event); err != nil {
+			return err
+		}
+	}
+
+	_, err := config.DB.ExecContext(ctx,
+		`UPDATE users SET last_chat_id = $1, last_chat_at = $3
+		 WHERE id = $2 AND (last_chat_at IS NULL OR last_chat_at <= $3)`,
+		item.ChatID, item.UserID, event.At)
+	if err != nil {
+		return fmt.Errorf("gagal update last_chat_id di PostgreSQL: %v", err)
+	}
+	return nil
+}
+
+// dispatchWebhook mengirim payload dengan tanda tangan HMAC-SHA256. ID item dikirim
+// sebagai Idempotency-Key agar penerima dapat membuang kiriman ulang.
+func dispatchWebhook(ctx context.Context, item models.OutboxItem) error {
+	if config.OutboxWebhookURL == "" {
+		return errors.New("OUTBOX_WEBHOOK_URL belum diatur")
+	}
+
+	// Event untuk pesan yang ternyata gagal disimpan tidak dikirim
+	var event messageEvent
+	if err := json.Unmarshal([]byte(item.Payload), &event); err != nil {
+		return err
+	}
+	if event.Event == "message.created" && len(event.Messages) > 0 {
+		id, err := primitive.ObjectIDFromHex(event.Messages[0].ID)
+		if err != nil {
+			return err
+		}
+		n, err := config.MongoDB.Collection("messages").CountDocuments(ctx, bson.M{"_id": id})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			// Pesan mungkin belum selesai ditulis; dibuang hanya jika sudah lewat masa tunggu
+			if time.Since(item.CreatedAt) < outboxLease {
+				return errors.New("pesan untuk event belum tersimpan")
+			}
+			config.Log.Warn("Event webhook dibuang karena pesan tidak tersimpan: ", item.ID.Hex())
+			return nil
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.OutboxWebhookURL, bytes.NewBufferString(item.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", item.ID.Hex())
+	if config.OutboxWebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(config.OutboxWebhookSecret))
+		mac.Write([]byte(item.Payload))
+		req.Header.Set("X-Signature-SHA256", hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := outboxHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook membalas status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ListOutbox menampilkan item outbox tertunda, terlama lebih dulu. stuckOnly membatasi
+// ke item yang sudah gagal sebanyak OUTBOX_STUCK_ATTEMPTS kali atau lebih.
+func ListOutbox(ctx context.Context, stuckOnly bool, req PageRequest) (Page[OutboxEntry], error) {
+	page := Page[OutboxEntry]{Data: []OutboxEntry{}}
+
+	offset := 0
+	if cur := req.After + req.Before; cur != "" {
+		c, err := decodeCursor(cur)
+		if err != nil {
+			return page, err
+		}
+		offset = c.Offset
+	}
+	limit := req.limit()
+
+	match := bson.M{"outbox.0": bson.M{"$exists": true}}
+	itemMatch := bson.M{}
+	if stuckOnly {
+		itemMatch["outbox.attempts"] = bson.M{"$gte": config.OutboxStuckAttempts}
+	}
+
+	cursor, err := config.MongoDB.Collection("conversations").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$unwind", Value: "$outbox"}},
+		{{Key: "$match", Value: itemMatch}},
+		{{Key: "$sort", Value: bson.D{{Key: "outbox.created_at", Value: 1}, {Key: "outbox._id", Value: 1}}}},
+		{{Key: "$skip", Value: offset}},
+		{{Key: "$limit", Value: limit + 1}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$outbox"}}},
+	})
+	if err != nil {
+		return page, err
+	}
+	var items []models.OutboxItem
+	if err := cursor.All(ctx, &items); err != nil {
+		return page, err
+	}
+
+	if len(items) > limit {
+		items = items[:limit]
+		page.HasMore = true
+		page.NextCursor = encodeCursor(pageCursor{Offset: offset + limit})
+	}
+	if offset > 0 {
+		page.PrevCursor = encodeCursor(pageCursor{Offset: max(offset-limit, 0)})
+	}
+	for _, item := range items {
+		page.Data = append(page.Data, OutboxEntry{OutboxItem: item, Stuck: item.Attempts >= config.OutboxStuckAttempts})
+	}
+	return page, nil
+}
+
+// RetryOutboxItem menjadwalkan item untuk segera dikirim ulang
+func RetryOutboxItem(ctx context.Context, itemID string) error {
+	id, err := primitive.ObjectIDFromHex(itemID)
+	if err != nil {
+		return ErrOutboxItemNotFound
+	}
+
+	res, err := config.MongoDB.Collection("conversations").UpdateOne(ctx,
+		bson.M{"outbox._id": id},
+		bson.M{"$set": bson.M{"outbox.$.next_attempt_at": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrOutboxItemNotFound
+	}
+	kickOutbox()
+	return nil
+}
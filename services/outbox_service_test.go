@@ -0,0 +1,32 @@
+package services
+
+import (
+	"backend-go/models"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestLastChatOutboxCarriesMessageTime(t *testing.T) {
+	sent := time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)
+	msgs := []*models.Message{
+		{Sender: "user", Timestamp: sent.Add(-time.Second)},
+		{Sender: "bot", Timestamp: sent},
+	}
+
+	items, err := messageOutboxItems("chat-1", 7, msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) == 0 || items[0].Kind != models.OutboxKindLastChatID {
+		t.Fatalf("item pertama harus last_chat_id, dapat %+v", items)
+	}
+
+	var event lastChatEvent
+	if err := json.Unmarshal([]byte(items[0].Payload), &event); err != nil {
+		t.Fatalf("payload last_chat_id tidak valid: %v", err)
+	}
+	if !event.At.Equal(sent) {
+		t.Errorf("waktu last_chat_id = %s, ingin waktu pesan terakhir %s", event.At, sent)
+	}
+}
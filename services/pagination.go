@@ -0,0 +1,355 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+var ErrInvalidCursor = errors.New("cursor tidak valid")
+
+// PageRequest adalah parameter paginasi berbasis cursor. After mengambil item setelah
+// cursor (sesuai urutan tampilan), Before mengambil item sebelum cursor.
+type PageRequest struct {
+	Before string
+	After  string
+	Limit  int
+}
+
+// Page adalah amplop respons paginasi yang dipakai semua endpoint daftar
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// pageCursor adalah isi cursor; dienkode base64 agar tetap opak bagi klien
+type pageCursor struct {
+	Seq    int64     `json:"s,omitempty"`
+	Time   time.Time `json:"t,omitempty"`
+	ChatID string    `json:"c,omitempty"`
+	Offset int       `json:"o,omitempty"`
+	Title  string    `json:"n,omitempty"`
+	Pinned bool      `json:"p,omitempty"`
+	Sort   string    `json:"k,omitempty"`
+	ID     string    `json:"i,omitempty"`
+}
+
+func (p PageRequest) limit() int {
+	if p.Limit <= 0 {
+		return DefaultPageSize
+	}
+	if p.Limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return p.Limit
+}
+
+// Validate memastikan hanya satu arah cursor yang dipakai dan cursornya dapat dibaca
+func (p PageRequest) Validate() error {
+	if p.Before != "" && p.After != "" {
+		return ErrInvalidCursor
+	}
+	for _, c := range []string{p.Before, p.After} {
+		if c == "" {
+			continue
+		}
+		if _, err := decodeCursor(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeCursor(c pageCursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func reverseSlice[T any](items []T) {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}
+
+// PageMessages mengambil pesan urut seq naik. Tanpa cursor, halaman terbaru dikembalikan;
+// PrevCursor menunjuk ke pesan yang lebih lama, NextCursor ke pesan yang lebih baru.
+func PageMessages(ctx context.Context, filter bson.M, req PageRequest) (Page[models.Message], error) {
+	page := Page[models.Message]{Data: []models.Message{}}
+	limit := req.limit()
+
+	forward := req.After != ""
+	sortDir := -1
+	if forward {
+		sortDir = 1
+		c, err := decodeCursor(req.After)
+		if err != nil {
+			return page, err
+		}
+		filter["seq"] = bson.M{"$gt": c.Seq}
+	} else if req.Before != "" {
+		c, err := decodeCursor(req.Before)
+		if err != nil {
+			return page, err
+		}
+		filter["seq"] = bson.M{"$lt": c.Seq}
+	}
+
+	// Ambil satu ekstra untuk mengetahui apakah masih ada halaman lanjutan
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: sortDir}}).SetLimit(int64(limit + 1))
+	cursor, err := config.MongoDB.Collection("messages").Find(ctx, filter, opts)
+	if err != nil {
+		return page, err
+	}
+	if err := cursor.All(ctx, &page.Data); err != nil {
+		return page, err
+	}
+
+	more := len(page.Data) > limit
+	if more {
+		page.Data = page.Data[:limit]
+	}
+	if !forward {
+		reverseSlice(page.Data)
+	}
+	if len(page.Data) == 0 {
+		page.Data = []models.Message{}
+		return page, nil
+	}
+
+	first := encodeCursor(pageCursor{Seq: page.Data[0].Seq})
+	last := encodeCursor(pageCursor{Seq: page.Data[len(page.Data)-1].Seq})
+	switch {
+	case forward:
+		page.PrevCursor = first
+		if more {
+			page.NextCursor = last
+		}
+	case req.Before != "":
+		page.NextCursor = last
+		if more {
+			page.PrevCursor = first
+		}
+	default:
+		if more {
+			page.PrevCursor = first
+		}
+	}
+	page.HasMore = more
+	return page, nil
+}
+
+// Urutan daftar percakapan yang didukung
+const (
+	ConversationSortUpdated = "updated"
+	ConversationSortCreated = "created"
+	ConversationSortTitle   = "title"
+)
+
+// ConversationSort menentukan urutan daftar percakapan; chat_id selalu menjadi pemutus seri
+type ConversationSort struct {
+	By          string
+	PinnedFirst bool
+}
+
+// ValidConversationSort memeriksa nilai ?sort=
+func ValidConversationSort(by string) bool {
+	switch by {
+	case "", ConversationSortUpdated, ConversationSortCreated, ConversationSortTitle:
+		return true
+	}
+	return false
+}
+
+func (s ConversationSort) key() string {
+	by := s.By
+	if by == "" {
+		by = ConversationSortUpdated
+	}
+	if s.PinnedFirst {
+		return "pinned," + by
+	}
+	return by
+}
+
+// sortField mengembalikan field utama dan arah urutnya
+func (s ConversationSort) sortField() (string, int) {
+	switch s.By {
+	case ConversationSortCreated:
+		return "created_at", -1
+	case ConversationSortTitle:
+		return "chat_title", 1
+	}
+	return "updated_at", -1
+}
+
+func (s ConversationSort) cursorOf(c models.Conversation) pageCursor {
+	cur := pageCursor{ChatID: c.ChatID, Sort: s.key(), Pinned: c.Pinned}
+	switch s.By {
+	case ConversationSortCreated:
+		cur.Time = c.CreatedAt
+	case ConversationSortTitle:
+		cur.Title = c.ChatTitle
+	default:
+		cur.Time = c.UpdatedAt
+	}
+	return cur
+}
+
+// keyset membangun filter "setelah cursor" sesuai urutan. reverse membalik arah
+// (dipakai untuk ?before=). Dokumen lama tanpa field pinned dianggap tidak disematkan.
+func (s ConversationSort) keyset(c pageCursor, reverse bool) bson.M {
+	field, dir := s.sortField()
+	op := func(d int) string {
+		if (d > 0) != reverse {
+			return "$gt"
+		}
+		return "$lt"
+	}
+
+	var value interface{} = c.Time
+	if field == "chat_title" {
+		value = c.Title
+	}
+
+	after := bson.M{"$or": bson.A{
+		bson.M{field: bson.M{op(dir): value}},
+		bson.M{field: value, "chat_id": bson.M{op(dir): c.ChatID}},
+	}}
+	if !s.PinnedFirst {
+		return after
+	}
+
+	pinnedEq := bson.M{"pinned": true}
+	if !c.Pinned {
+		pinnedEq = bson.M{"pinned": bson.M{"$ne": true}}
+	}
+	sameGroup := bson.M{"$and": bson.A{pinnedEq, after}}
+
+	// Urutan grup: disematkan dulu. Maju dari grup disematkan boleh pindah ke grup biasa,
+	// mundur dari grup biasa boleh pindah ke grup disematkan.
+	switch {
+	case c.Pinned && !reverse:
+		return bson.M{"$or": bson.A{sameGroup, bson.M{"pinned": bson.M{"$ne": true}}}}
+	case !c.Pinned && reverse:
+		return bson.M{"$or": bson.A{sameGroup, bson.M{"pinned": true}}}
+	}
+	return sameGroup
+}
+
+func (s ConversationSort) sortSpec(reverse bool) bson.D {
+	flip := 1
+	if reverse {
+		flip = -1
+	}
+	field, dir := s.sortField()
+
+	var spec bson.D
+	if s.PinnedFirst {
+		spec = append(spec, bson.E{Key: "pinned", Value: -1 * flip})
+	}
+	return append(spec, bson.E{Key: field, Value: dir * flip}, bson.E{Key: "chat_id", Value: dir * flip})
+}
+
+// PageConversations mengambil percakapan urut updated_at turun (chat_id sebagai pemutus seri).
+// NextCursor menunjuk ke percakapan yang lebih lama, PrevCursor ke yang lebih baru.
+func PageConversations(ctx context.Context, filter bson.M, req PageRequest) (Page[models.Conversation], error) {
+	return PageConversationsSorted(ctx, filter, ConversationSort{}, req)
+}
+
+// PageConversationsSorted sama dengan PageConversations dengan urutan yang dapat dipilih.
+// NextCursor menunjuk ke halaman berikutnya sesuai urutan tampilan.
+func PageConversationsSorted(ctx context.Context, filter bson.M, sort ConversationSort, req PageRequest) (Page[models.Conversation], error) {
+	page := Page[models.Conversation]{Data: []models.Conversation{}}
+	limit := req.limit()
+
+	backward := req.Before != ""
+	var conditions bson.A
+	for _, raw := range []string{req.After, req.Before} {
+		if raw == "" {
+			continue
+		}
+		c, err := decodeCursor(raw)
+		if err != nil {
+			return page, err
+		}
+		if c.Sort != "" && c.Sort != sort.key() {
+			return page, ErrInvalidCursor
+		}
+		conditions = append(conditions, sort.keyset(c, backward))
+	}
+
+	query := filter
+	if len(conditions) > 0 {
+		query = bson.M{"$and": append(bson.A{filter}, conditions...)}
+	}
+
+	opts := options.Find().SetSort(sort.sortSpec(backward)).SetLimit(int64(limit + 1))
+	cursor, err := config.MongoDB.Collection("conversations").Find(ctx, query, opts)
+	if err != nil {
+		return page, err
+	}
+	if err := cursor.All(ctx, &page.Data); err != nil {
+		return page, err
+	}
+
+	more := len(page.Data) > limit
+	if more {
+		page.Data = page.Data[:limit]
+	}
+	if backward {
+		reverseSlice(page.Data)
+	}
+	if len(page.Data) == 0 {
+		page.Data = []models.Conversation{}
+		return page, nil
+	}
+
+	first := encodeCursor(sort.cursorOf(page.Data[0]))
+	last := encodeCursor(sort.cursorOf(page.Data[len(page.Data)-1]))
+	if backward {
+		page.NextCursor = last
+		if more {
+			page.PrevCursor = first
+		}
+	} else {
+		if more {
+			page.NextCursor = last
+		}
+		if req.After != "" {
+			page.PrevCursor = first
+		}
+	}
+	page.HasMore = more
+	return page, nil
+}
+
+// IsCursorError membantu controller membedakan 400 dari 500
+func IsCursorError(err error) bool {
+	return errors.Is(err, ErrInvalidCursor)
+}
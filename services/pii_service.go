@@ -0,0 +1,315 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Jenis PII yang dikenali detektor
+const (
+	PIITypeEmail         = "email"
+	PIITypePhone         = "phone"
+	PIITypeNIK           = "nik"
+	PIITypeCardNumber    = "card_number"
+	PIITypeAccountNumber = "account_number"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// Nomor HP Indonesia: +62 / 62 / 0 diikuti 8xx, boleh dipisah spasi atau strip
+	phonePattern = regexp.MustCompile(`(?:\+62|62|0)[ \-]?8[1-9](?:[ \-]?\d){6,10}`)
+	// Deretan angka panjang (boleh dipisah spasi/strip) untuk NIK, kartu, dan rekening
+	digitRunPattern = regexp.MustCompile(`\d(?:[ \-]?\d){9,18}`)
+	// Kata yang menandai nomor rekening; angka 10–15 digit tanpa konteks ini (mis.
+	// nominal atau nomor pesanan) tidak dimasking
+	accountContextPattern = regexp.MustCompile(`(?i)\b(?:rekening|rek|norek|account|acc|va|virtual account|bca|bni|bri|btn|bsi|mandiri|cimb|permata|danamon|a/n)\b`)
+)
+
+// accountContextWindow adalah jumlah byte sebelum angka yang diperiksa untuk kata rekening
+const accountContextWindow = 40
+
+// PIIMaskResult adalah hasil deteksi dan masking satu teks
+type PIIMaskResult struct {
+	Masked   string
+	Findings []models.PIIFinding
+	Total    int
+}
+
+// HasPII mengembalikan true jika ada PII yang ditemukan
+func (r PIIMaskResult) HasPII() bool {
+	return r.Total > 0
+}
+
+// MaskPII mendeteksi PII (email, nomor HP +62, NIK 16 digit, nomor kartu
+// dengan validasi Luhn, dan nomor rekening yang didahului kata seperti "rekening"
+// atau nama bank) lalu mengganti nilainya dengan masker.
+func MaskPII(text string) PIIMaskResult {
+	counts := map[string]int{}
+
+	masked := emailPattern.ReplaceAllStringFunc(text, func(m string) string {
+		counts[PIITypeEmail]++
+		return maskEmail(m)
+	})
+
+	masked = replaceBoundedDigits(phonePattern, masked, func(m, _ string) (string, bool) {
+		counts[PIITypePhone]++
+		return maskDigits(m, 3), true
+	})
+
+	masked = replaceBoundedDigits(digitRunPattern, masked, func(m, before string) (string, bool) {
+		digits := onlyDigits(m)
+		switch {
+		// NIK diperiksa lebih dulu karena sebagian NIK juga lolos Luhn dan berawalan 2–6
+		case len(digits) == 16 && looksLikeNIK(digits):
+			counts[PIITypeNIK]++
+			return maskDigits(m, 0), true
+		case len(digits) >= 13 && looksLikeCard(digits):
+			counts[PIITypeCardNumber]++
+			return maskDigits(m, 4), true
+		case len(digits) >= 10 && len(digits) <= 15 && hasAccountContext(before):
+			counts[PIITypeAccountNumber]++
+			return maskDigits(m, 4), true
+		}
+		return m, false
+	})
+
+	result := PIIMaskResult{Masked: masked}
+	for _, t := range []string{PIITypeEmail, PIITypePhone, PIITypeNIK, PIITypeCardNumber, PIITypeAccountNumber} {
+		if n := counts[t]; n > 0 {
+			result.Findings = append(result.Findings, models.PIIFinding{Type: t, Count: n})
+			result.Total += n
+		}
+	}
+	return result
+}
+
+// MaskForLog mengembalikan teks yang aman untuk ditulis ke log
+func MaskForLog(text string) string {
+	return MaskPII(text).Masked
+}
+
+// ProtectText melakukan masking untuk teks yang akan disimpan dan, bila kebijakan
+// mengizinkan, mengenkripsi teks aslinya. Laporan PII hanya dibuat jika ada temuan.
+func ProtectText(text string) (masked string, report *models.PIIReport, encrypted string) {
+	res := MaskPII(text)
+	if !res.HasPII() {
+		return text, nil, ""
+	}
+
+	report = &models.PIIReport{
+		Findings:  res.Findings,
+		Total:     res.Total,
+		ScannedAt: time.Now(),
+	}
+
+	if config.PIIStoreEncryptedOriginal {
+		enc, err := EncryptPII(text)
+		if err != nil {
+			config.Log.Warn("Teks asli PII tidak disimpan: ", err)
+		} else {
+			encrypted = enc
+			report.OriginalStored = true
+		}
+	}
+
+	return res.Masked, report, encrypted
+}
+
+// ProtectMessage menerapkan ProtectText pada konten atau transkrip pesan sebelum disimpan
+func ProtectMessage(msg *models.Message) {
+	if msg.Content != "" {
+		msg.Content, msg.PII, msg.EncryptedOriginal = ProtectText(msg.Content)
+		return
+	}
+	msg.Transcript, msg.PII, msg.EncryptedOriginal = ProtectText(msg.Transcript)
+}
+
+var (
+	piiAEAD     cipher.AEAD
+	piiAEADErr  error
+	piiAEADOnce sync.Once
+)
+
+func loadPIICipher() (cipher.AEAD, error) {
+	piiAEADOnce.Do(func() {
+		if config.PIIEncryptionKey == "" {
+			piiAEADErr = errors.New("PII_ENCRYPTION_KEY belum diatur")
+			return
+		}
+		key, err := base64.StdEncoding.DecodeString(config.PIIEncryptionKey)
+		if err != nil {
+			piiAEADErr = fmt.Errorf("PII_ENCRYPTION_KEY bukan base64 yang valid: %v", err)
+			return
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			piiAEADErr = fmt.Errorf("PII_ENCRYPTION_KEY tidak valid: %v", err)
+			return
+		}
+		piiAEAD, piiAEADErr = cipher.NewGCM(block)
+	})
+	return piiAEAD, piiAEADErr
+}
+
+// EncryptPII mengenkripsi teks dengan AES-GCM, hasilnya base64(nonce || ciphertext)
+func EncryptPII(plain string) (string, error) {
+	aead, err := loadPIICipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptPII membuka teks hasil EncryptPII
+func DecryptPII(encoded string) (string, error) {
+	aead, err := loadPIICipher()
+	if err != nil {
+		return "", err
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < aead.NonceSize() {
+		return "", errors.New("ciphertext PII terlalu pendek")
+	}
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// replaceBoundedDigits mengganti kecocokan yang tidak menempel pada angka lain,
+// sehingga potongan dari deretan angka yang lebih panjang tidak ikut dimasking.
+// fn menerima kecocokan dan teks sebelumnya.
+func replaceBoundedDigits(re *regexp.Regexp, text string, fn func(match, before string) (string, bool)) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		if start > 0 && isDigitByte(text[start-1]) || end < len(text) && isDigitByte(text[end]) {
+			continue
+		}
+		replacement, ok := fn(text[start:end], text[:start])
+		if !ok {
+			continue
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(replacement)
+		last = end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// maskDigits mengganti semua angka dengan '*' kecuali `keep` angka terakhir
+func maskDigits(s string, keep int) string {
+	total := len(onlyDigits(s))
+	out := []byte(s)
+	seen := 0
+	for i := range out {
+		if isDigitByte(out[i]) {
+			seen++
+			if seen <= total-keep {
+				out[i] = '*'
+			}
+		}
+	}
+	return string(out)
+}
+
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return "***"
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// hasAccountContext memeriksa kata penanda rekening tepat sebelum angka
+func hasAccountContext(before string) bool {
+	if len(before) > accountContextWindow {
+		before = before[len(before)-accountContextWindow:]
+	}
+	return accountContextPattern.MatchString(before)
+}
+
+// nikProvinces adalah kode provinsi Dukcapil yang dapat muncul di awal NIK
+var nikProvinces = map[int]bool{
+	11: true, 12: true, 13: true, 14: true, 15: true, 16: true, 17: true, 18: true, 19: true,
+	21: true, 31: true, 32: true, 33: true, 34: true, 35: true, 36: true,
+	51: true, 52: true, 53: true, 61: true, 62: true, 63: true, 64: true, 65: true,
+	71: true, 72: true, 73: true, 74: true, 75: true, 76: true, 81: true, 82: true,
+	91: true, 92: true, 93: true, 94: true, 95: true, 96: true, 97: true,
+}
+
+// looksLikeNIK memeriksa struktur NIK: kode provinsi yang terdaftar, tanggal lahir
+// (perempuan +40), dan bulan lahir yang valid.
+func looksLikeNIK(d string) bool {
+	province := atoi2(d[0:2])
+	day := atoi2(d[6:8])
+	month := atoi2(d[8:10])
+	if !nikProvinces[province] {
+		return false
+	}
+	if day > 40 {
+		day -= 40
+	}
+	return day >= 1 && day <= 31 && month >= 1 && month <= 12
+}
+
+// looksLikeCard memeriksa prefiks jaringan kartu (2–6) dan checksum Luhn
+func looksLikeCard(d string) bool {
+	return d[0] >= '2' && d[0] <= '6' && luhnValid(d)
+}
+
+func luhnValid(d string) bool {
+	sum := 0
+	double := false
+	for i := len(d) - 1; i >= 0; i-- {
+		n := int(d[i] - '0')
+		if double {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func onlyDigits(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if isDigitByte(s[i]) {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func isDigitByte(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func atoi2(s string) int {
+	return int(s[0]-'0')*10 + int(s[1]-'0')
+}
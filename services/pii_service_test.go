@@ -0,0 +1,119 @@
+package services
+
+import (
+	"backend-go/models"
+	"reflect"
+	"testing"
+)
+
+func TestMaskPII(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		text     string
+		masked   string
+		findings []models.PIIFinding
+	}{
+		{
+			name:     "email",
+			text:     "kirim ke budi.santoso@example.co.id ya",
+			masked:   "kirim ke b***@example.co.id ya",
+			findings: []models.PIIFinding{{Type: PIITypeEmail, Count: 1}},
+		},
+		{
+			name:     "HP awalan 0",
+			text:     "hubungi 081234567890",
+			masked:   "hubungi *********890",
+			findings: []models.PIIFinding{{Type: PIITypePhone, Count: 1}},
+		},
+		{
+			name:     "HP +62 dengan pemisah",
+			text:     "WA +62 812-3456-7890",
+			masked:   "WA +** ***-****-*890",
+			findings: []models.PIIFinding{{Type: PIITypePhone, Count: 1}},
+		},
+		{
+			name:     "NIK",
+			text:     "NIK saya 3273014508900001",
+			masked:   "NIK saya ****************",
+			findings: []models.PIIFinding{{Type: PIITypeNIK, Count: 1}},
+		},
+		{
+			// Lolos Luhn dan berawalan 3, tetapi strukturnya NIK
+			name:     "NIK yang lolos Luhn",
+			text:     "3171011508900002",
+			masked:   "****************",
+			findings: []models.PIIFinding{{Type: PIITypeNIK, Count: 1}},
+		},
+		{
+			name:     "kartu dengan spasi",
+			text:     "kartu 4111 1111 1111 1111",
+			masked:   "kartu **** **** **** 1111",
+			findings: []models.PIIFinding{{Type: PIITypeCardNumber, Count: 1}},
+		},
+		{
+			name:   "16 digit gagal Luhn bukan kartu",
+			text:   "kode 4111111111111112",
+			masked: "kode 4111111111111112",
+		},
+		{
+			name:     "rekening dengan nama bank",
+			text:     "transfer ke rekening BCA 1234567890",
+			masked:   "transfer ke rekening BCA ******7890",
+			findings: []models.PIIFinding{{Type: PIITypeAccountNumber, Count: 1}},
+		},
+		{
+			name:     "norek dengan strip",
+			text:     "norek: 123-456-789-012",
+			masked:   "norek: ***-***-**9-012",
+			findings: []models.PIIFinding{{Type: PIITypeAccountNumber, Count: 1}},
+		},
+		{
+			name:   "nominal tanpa konteks rekening",
+			text:   "total tagihan 1500000000 rupiah",
+			masked: "total tagihan 1500000000 rupiah",
+		},
+		{
+			name:   "nomor pesanan",
+			text:   "pesanan 202610190001 sudah dikirim",
+			masked: "pesanan 202610190001 sudah dikirim",
+		},
+		{
+			name:   "potongan deretan angka yang lebih panjang",
+			text:   "rekening 12345678901234567890123",
+			masked: "rekening 12345678901234567890123",
+		},
+		{
+			name:   "tanpa PII",
+			text:   "jadwal kuliah hari ini",
+			masked: "jadwal kuliah hari ini",
+		},
+	} {
+		got := MaskPII(tc.text)
+		if got.Masked != tc.masked {
+			t.Errorf("%s: masked = %q, ingin %q", tc.name, got.Masked, tc.masked)
+		}
+		if !reflect.DeepEqual(got.Findings, tc.findings) {
+			t.Errorf("%s: findings = %v, ingin %v", tc.name, got.Findings, tc.findings)
+		}
+		if got.Total != len(tc.findings) {
+			t.Errorf("%s: total = %d, ingin %d", tc.name, got.Total, len(tc.findings))
+		}
+	}
+}
+
+func TestLuhnValid(t *testing.T) {
+	for _, tc := range []struct {
+		digits string
+		want   bool
+	}{
+		{"4111111111111111", true},
+		{"5500005555555559", true},
+		{"378282246310005", true},
+		{"4111111111111112", false},
+		{"1234567890123", false},
+	} {
+		if got := luhnValid(tc.digits); got != tc.want {
+			t.Errorf("luhnValid(%s) = %v, ingin %v", tc.digits, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"errors"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrEmptySearchQuery = errors.New("kata kunci pencarian kosong")
+
+// SearchQuery adalah parameter pencarian; UserID nil berarti lintas semua user (admin)
+type SearchQuery struct {
+	Text   string
+	UserID *int
+	Page   PageRequest
+}
+
+// SearchHit adalah satu pesan yang cocok beserta posisinya di riwayat chat
+type SearchHit struct {
+	MessageID string    `json:"message_id"`
+	ChatID    string    `json:"chat_id"`
+	UserID    int       `json:"user_id"`
+	Seq       int64     `json:"seq"`
+	Modality  string    `json:"modality"`
+	Sender    string    `json:"sender"`
+	Snippet   string    `json:"snippet"` // HTML-escaped, kata yang cocok dibungkus <mark>
+	Score     float64   `json:"score"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// searchBackend adalah mesin pencarian yang dipakai; dipilih lewat SEARCH_BACKEND
+type searchBackend interface {
+	Index(msgs []*models.Message)
+	RemoveChat(chatID string)
+	Remove(ids []primitive.ObjectID)
+	Search(ctx context.Context, stems []string, userID *int, offset, limit int) ([]SearchHit, error)
+}
+
+var (
+	activeSearch searchBackend = mongoSearch{}
+	memoryIndex  *memorySearchIndex
+)
+
+// InitSearch memilih backend pencarian; backend "memory" memuat semua pesan ke indeks di proses
+func InitSearch() error {
+	if config.SearchBackend != "memory" {
+		activeSearch = mongoSearch{}
+		config.Log.Info("🔎 Pencarian memakai indeks teks MongoDB")
+		return nil
+	}
+
+	memoryIndex = newMemorySearchIndex()
+	if err := memoryIndex.load(context.Background()); err != nil {
+		return err
+	}
+	activeSearch = memoryIndex
+	config.Log.Infof("🔎 Pencarian memakai indeks di memori (%d pesan)", memoryIndex.size())
+	return nil
+}
+
+// BuildSearchText menghasilkan stem yang disimpan di field search_text
+func BuildSearchText(text string) string {
+	return strings.Join(SearchStems(text), " ")
+}
+
+// indexMessages dipanggil dari SaveMessages agar backend di memori ikut diperbarui
+func indexMessages(msgs []*models.Message) {
+	activeSearch.Index(msgs)
+}
+
+// unindexChat dipanggil saat chat dihapus
+func unindexChat(chatID string) {
+	activeSearch.RemoveChat(chatID)
+}
+
+// unindexMessages dipanggil saat pesan digantikan hasil suntingan
+func unindexMessages(ids []primitive.ObjectID) {
+	activeSearch.Remove(ids)
+}
+
+// SearchMessages mencari pesan teks, balasan bot, dan transkrip suara
+func SearchMessages(q SearchQuery) (Page[SearchHit], error) {
+	page := Page[SearchHit]{Data: []SearchHit{}}
+
+	stems := SearchStems(q.Text)
+	if len(stems) == 0 {
+		return page, ErrEmptySearchQuery
+	}
+
+	// Cursor pencarian menyimpan offset absolut sehingga before/after diperlakukan sama
+	offset := 0
+	if cur := q.Page.After + q.Page.Before; cur != "" {
+		c, err := decodeCursor(cur)
+		if err != nil {
+			return page, err
+		}
+		offset = c.Offset
+	}
+	limit := q.Page.limit()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	hits, err := activeSearch.Search(ctx, stems, q.UserID, offset, limit+1)
+	if err != nil {
+		return page, err
+	}
+
+	if len(hits) > limit {
+		hits = hits[:limit]
+		page.HasMore = true
+		page.NextCursor = encodeCursor(pageCursor{Offset: offset + limit})
+	}
+	if offset > 0 {
+		page.PrevCursor = encodeCursor(pageCursor{Offset: max(offset-limit, 0)})
+	}
+	page.Data = hits
+	return page, nil
+}
+
+// HighlightSnippet memotong teks di sekitar kata pertama yang cocok lalu menandai
+// semua kata yang stem-nya ada di kueri
+func HighlightSnippet(text string, stems []string) string {
+	const contextBytes = 80
+
+	wanted := make(map[string]bool, len(stems))
+	for _, s := range stems {
+		wanted[s] = true
+	}
+
+	var matches []TextToken
+	for _, tok := range TokenizeIndonesian(text) {
+		if wanted[tok.Stem] {
+			matches = append(matches, tok)
+		}
+	}
+
+	start, end := 0, len(text)
+	if len(matches) > 0 {
+		start = max(matches[0].Start-contextBytes, 0)
+		end = min(matches[0].End+contextBytes*2, len(text))
+	} else if end > contextBytes*3 {
+		end = contextBytes * 3
+	}
+	// Jangan memotong di tengah karakter multi-byte atau kata
+	for start > 0 && !isWordBoundary(text, start) {
+		start--
+	}
+	for end < len(text) && !isWordBoundary(text, end) {
+		end++
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range matches {
+		if m.Start < start || m.End > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:m.Start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[m.Start:m.End]))
+		b.WriteString("</mark>")
+		pos = m.End
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func isWordBoundary(text string, i int) bool {
+	return i <= 0 || i >= len(text) || text[i] == ' ' || text[i] == '\n'
+}
+
+func newSearchHit(msg models.Message, stems []string, score float64) SearchHit {
+	return SearchHit{
+		MessageID: msg.ID.Hex(),
+		ChatID:    msg.ChatID,
+		UserID:    msg.UserID,
+		Seq:       msg.Seq,
+		Modality:  msg.Modality,
+		Sender:    msg.Sender,
+		Snippet:   HighlightSnippet(msg.Text(), stems),
+		Score:     score,
+		Timestamp: msg.Timestamp,
+	}
+}
+
+// ==== Backend: indeks teks MongoDB ====
+
+type mongoSearch struct{}
+
+func (mongoSearch) Index([]*models.Message) {}
+
+func (mongoSearch) RemoveChat(string) {}
+
+func (mongoSearch) Remove([]primitive.ObjectID) {}
+
+func (mongoSearch) Search(ctx context.Context, stems []string, userID *int, offset, limit int) ([]SearchHit, error) {
+	filter := bson.M{
+		"$text":         bson.M{"$search": strings.Join(stems, " ")},
+		"deleted_at":    notDeleted,
+		"superseded_by": bson.M{"$exists": false},
+	}
+	if userID != nil {
+		filter["user_id"] = *userID
+	}
+
+	opts := options.Find().
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}, "encrypted_original": 0}).
+		SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "timestamp", Value: -1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+
+	cursor, err := config.MongoDB.Collection("messages").Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	hits := []SearchHit{}
+	for cursor.Next(ctx) {
+		var doc struct {
+			models.Message `bson:",inline"`
+			Score          float64 `bson:"score"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		hits = append(hits, newSearchHit(doc.Message, stems, doc.Score))
+	}
+	return hits, cursor.Err()
+}
+
+// ==== Backend: indeks terbalik di memori ====
+
+// memorySearchIndex cocok untuk deployment kecil tanpa indeks teks MongoDB;
+// isinya dibangun ulang dari collection messages setiap startup
+type memorySearchIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[primitive.ObjectID]int // stem -> pesan -> frekuensi
+	docs     map[primitive.ObjectID]models.Message
+	chats    map[string][]primitive.ObjectID
+}
+
+func newMemorySearchIndex() *memorySearchIndex {
+	return &memorySearchIndex{
+		postings: map[string]map[primitive.ObjectID]int{},
+		docs:     map[primitive.ObjectID]models.Message{},
+		chats:    map[string][]primitive.ObjectID{},
+	}
+}
+
+func (idx *memorySearchIndex) load(ctx context.Context) error {
+	opts := options.Find().SetProjection(bson.M{"encrypted_original": 0, "search_text": 0})
+	cursor, err := config.MongoDB.Collection("messages").Find(ctx,
+		bson.M{"deleted_at": notDeleted, "superseded_by": bson.M{"$exists": false}}, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var msg models.Message
+		if err := cursor.Decode(&msg); err != nil {
+			return err
+		}
+		idx.Index([]*models.Message{&msg})
+	}
+	return cursor.Err()
+}
+
+func (idx *memorySearchIndex) size() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+func (idx *memorySearchIndex) Index(msgs []*models.Message) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, msg := range msgs {
+		if _, exists := idx.docs[msg.ID]; exists {
+			continue
+		}
+		doc := *msg
+		doc.EncryptedOriginal = ""
+		doc.SearchText = ""
+		idx.docs[msg.ID] = doc
+		idx.chats[msg.ChatID] = append(idx.chats[msg.ChatID], msg.ID)
+
+		for _, tok := range TokenizeIndonesian(msg.Text()) {
+			if stopWordsID[tok.Word] || len(tok.Stem) < 2 {
+				continue
+			}
+			if idx.postings[tok.Stem] == nil {
+				idx.postings[tok.Stem] = map[primitive.ObjectID]int{}
+			}
+			idx.postings[tok.Stem][msg.ID]++
+		}
+	}
+}
+
+func (idx *memorySearchIndex) RemoveChat(chatID string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, id := range idx.chats[chatID] {
+		idx.removeDoc(id)
+	}
+	delete(idx.chats, chatID)
+}
+
+func (idx *memorySearchIndex) Remove(ids []primitive.ObjectID) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, id := range ids {
+		doc, ok := idx.docs[id]
+		if !ok {
+			continue
+		}
+		idx.removeDoc(id)
+
+		remaining := idx.chats[doc.ChatID][:0]
+		for _, other := range idx.chats[doc.ChatID] {
+			if other != id {
+				remaining = append(remaining, other)
+			}
+		}
+		idx.chats[doc.ChatID] = remaining
+	}
+}
+
+// removeDoc menghapus satu pesan dari postings; pemanggil memegang lock tulis
+func (idx *memorySearchIndex) removeDoc(id primitive.ObjectID) {
+	for _, stem := range SearchStems(idx.docs[id].Text()) {
+		delete(idx.postings[stem], id)
+		if len(idx.postings[stem]) == 0 {
+			delete(idx.postings, stem)
+		}
+	}
+	delete(idx.docs, id)
+}
+
+// Search memberi skor TF-IDF sederhana: jumlah frekuensi × log(1 + N/df)
+func (idx *memorySearchIndex) Search(_ context.Context, stems []string, userID *int, offset, limit int) ([]SearchHit, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	scores := map[primitive.ObjectID]float64{}
+	total := float64(len(idx.docs))
+	for _, stem := range stems {
+		posting := idx.postings[stem]
+		if len(posting) == 0 {
+			continue
+		}
+		idf := math.Log(1 + total/float64(len(posting)))
+		for id, tf := range posting {
+			if userID != nil && idx.docs[id].UserID != *userID {
+				continue
+			}
+			scores[id] += float64(tf) * idf
+		}
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return idx.docs[ids[i]].Timestamp.After(idx.docs[ids[j]].Timestamp)
+	})
+
+	hits := []SearchHit{}
+	for i := offset; i < len(ids) && len(hits) < limit; i++ {
+		hits = append(hits, newSearchHit(idx.docs[ids[i]], stems, scores[ids[i]]))
+	}
+	return hits, nil
+}
@@ -0,0 +1,382 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// presignedAudioTTL adalah masa berlaku URL audio pada halaman berbagi
+const presignedAudioTTL = 15 * time.Minute
+
+var (
+	ErrShareNotFound = errors.New("tautan berbagi tidak ditemukan")
+	ErrShareExpired  = errors.New("tautan berbagi sudah kedaluwarsa atau dicabut")
+	ErrShareTTL      = errors.New("masa berlaku tautan tidak valid")
+)
+
+// CreatedShare dikembalikan sekali saat tautan dibuat; token tidak dapat diambil lagi
+type CreatedShare struct {
+	models.ShareLink
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// SharedMessage adalah bentuk pesan di halaman berbagi, tanpa metadata internal
+type SharedMessage struct {
+	Seq       int64     `json:"seq"`
+	Sender    string    `json:"sender"`
+	Modality  string    `json:"modality"`
+	Text      string    `json:"text"`
+	AudioURL  string    `json:"audio_url,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// SharedTranscript adalah transkrip baca-saja yang dapat dibuka tanpa login
+type SharedTranscript struct {
+	ChatTitle string          `json:"chat_title"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	Messages  []SharedMessage `json:"messages"`
+
+	transcript ChatTranscript
+}
+
+// Transcript mengembalikan data untuk renderer ekspor (HTML) dengan URL audio yang sudah ditandatangani
+func (s *SharedTranscript) Transcript() ChatTranscript {
+	return s.transcript
+}
+
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateShareLink membuat tautan baca-saja untuk chat sampai pesan terakhir saat ini
+func CreateShareLink(chatID string, userID int, ttlHours int) (*CreatedShare, error) {
+	if ttlHours == 0 {
+		ttlHours = config.ShareDefaultTTLHours
+	}
+	if ttlHours < 1 || ttlHours > config.ShareMaxTTLHours {
+		return nil, ErrShareTTL
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var convo models.Conversation
+	err := config.MongoDB.Collection("conversations").FindOne(ctx,
+		bson.M{"chat_id": chatID, "user_id": userID, "deleted_at": notDeleted}).Decode(&convo)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrChatNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	link := models.ShareLink{
+		ID:        primitive.NewObjectID(),
+		ChatID:    chatID,
+		UserID:    userID,
+		TokenHash: hashShareToken(token),
+		TokenHint: token[:6],
+		MaxSeq:    convo.MessageCount,
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Duration(ttlHours) * time.Hour),
+	}
+	if _, err := config.MongoDB.Collection("share_links").InsertOne(ctx, link); err != nil {
+		return nil, err
+	}
+
+	return &CreatedShare{
+		ShareLink: link,
+		Token:     token,
+		URL:       strings.TrimSuffix(config.ShareBaseURL, "/") + "/shared/" + token,
+	}, nil
+}
+
+// ListShareLinks mengembalikan semua tautan sebuah chat, terbaru lebih dulu
+func ListShareLinks(chatID string, userID int) ([]models.ShareLink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := config.MongoDB.Collection("share_links").Find(ctx,
+		bson.M{"chat_id": chatID, "user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	links := []models.ShareLink{}
+	if err := cursor.All(ctx, &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// RevokeShareLink mencabut tautan; tautan yang sudah dicabut tetap tercatat beserta lognya
+func RevokeShareLink(chatID string, userID int, shareID string) error {
+	id, err := primitive.ObjectIDFromHex(shareID)
+	if err != nil {
+		return ErrShareNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := config.MongoDB.Collection("share_links").UpdateOne(ctx,
+		bson.M{"_id": id, "chat_id": chatID, "user_id": userID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+// ListShareAccess mengambil log akses sebuah tautan per halaman, terbaru lebih dulu
+func ListShareAccess(chatID string, userID int, shareID string, req PageRequest) (Page[models.ShareAccess], error) {
+	page := Page[models.ShareAccess]{Data: []models.ShareAccess{}}
+
+	id, err := primitive.ObjectIDFromHex(shareID)
+	if err != nil {
+		return page, ErrShareNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	n, err := config.MongoDB.Collection("share_links").CountDocuments(ctx, bson.M{"_id": id, "chat_id": chatID, "user_id": userID})
+	if err != nil {
+		return page, err
+	}
+	if n == 0 {
+		return page, ErrShareNotFound
+	}
+
+	filter := bson.M{"share_id": id}
+	if req.After != "" {
+		c, err := decodeCursor(req.After)
+		if err != nil {
+			return page, err
+		}
+		after, err := primitive.ObjectIDFromHex(c.ID)
+		if err != nil {
+			return page, ErrInvalidCursor
+		}
+		filter["_id"] = bson.M{"$lt": after}
+	}
+
+	limit := req.limit()
+	cursor, err := config.MongoDB.Collection("share_access_log").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(limit+1)))
+	if err != nil {
+		return page, err
+	}
+	if err := cursor.All(ctx, &page.Data); err != nil {
+		return page, err
+	}
+
+	if len(page.Data) > limit {
+		page.Data = page.Data[:limit]
+		page.HasMore = true
+		page.NextCursor = encodeCursor(pageCursor{ID: page.Data[limit-1].ID.Hex()})
+	}
+	if page.Data == nil {
+		page.Data = []models.ShareAccess{}
+	}
+	return page, nil
+}
+
+// messagesAtSnapshot membuang pesan yang sudah digantikan suntingan di dalam snapshot.
+// Pesan yang penggantinya berada di luar snapshot (seq > maxSeq) tetap ditampilkan agar
+// giliran yang disunting setelah tautan dibuat tidak hilang.
+func messagesAtSnapshot(ctx context.Context, messages []models.Message, maxSeq int64) ([]models.Message, error) {
+	var replacementIDs []primitive.ObjectID
+	for _, msg := range messages {
+		if msg.SupersededBy != nil {
+			replacementIDs = append(replacementIDs, *msg.SupersededBy)
+		}
+	}
+	if len(replacementIDs) == 0 {
+		return messages, nil
+	}
+
+	cursor, err := config.MongoDB.Collection("messages").Find(ctx,
+		bson.M{"_id": bson.M{"$in": replacementIDs}, "seq": bson.M{"$lte": maxSeq}},
+		options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var inSnapshot []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &inSnapshot); err != nil {
+		return nil, err
+	}
+	replaced := make(map[primitive.ObjectID]bool, len(inSnapshot))
+	for _, r := range inSnapshot {
+		replaced[r.ID] = true
+	}
+
+	visible := messages[:0]
+	for _, msg := range messages {
+		if msg.SupersededBy == nil || !replaced[*msg.SupersededBy] {
+			visible = append(visible, msg)
+		}
+	}
+	return visible, nil
+}
+
+// OpenSharedTranscript memvalidasi token, mencatat akses, lalu mengembalikan transkrip
+// baca-saja. Teks sudah tersamarkan PII sejak disimpan; audio memakai URL bertanda tangan.
+func OpenSharedTranscript(token, ip, userAgent, format string) (*SharedTranscript, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var link models.ShareLink
+	err := config.MongoDB.Collection("share_links").FindOne(ctx, bson.M{"token_hash": hashShareToken(token)}).Decode(&link)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrShareNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if link.RevokedAt != nil || now.After(link.ExpiresAt) {
+		return nil, ErrShareExpired
+	}
+
+	var convo models.Conversation
+	err = config.MongoDB.Collection("conversations").FindOne(ctx,
+		bson.M{"chat_id": link.ChatID, "user_id": link.UserID, "deleted_at": notDeleted}).Decode(&convo)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrShareNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := config.MongoDB.Collection("messages").Find(ctx,
+		bson.M{"chat_id": link.ChatID, "seq": bson.M{"$lte": link.MaxSeq}},
+		options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}).SetProjection(bson.M{"encrypted_original": 0, "search_text": 0}))
+	if err != nil {
+		return nil, err
+	}
+	var messages []models.Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	messages, err = messagesAtSnapshot(ctx, messages, link.MaxSeq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Audio hanya berlaku singkat dan tidak melewati masa berlaku tautan
+	audioTTL := presignedAudioTTL
+	if remaining := time.Until(link.ExpiresAt); remaining < audioTTL {
+		audioTTL = remaining
+	}
+
+	shared := &SharedTranscript{
+		ChatTitle: convo.ChatTitle,
+		ExpiresAt: link.ExpiresAt,
+		Messages:  make([]SharedMessage, 0, len(messages)),
+	}
+	for i := range messages {
+		msg := &messages[i]
+		signed := ""
+		if msg.Audio != nil {
+			signed = presignAudioURL(ctx, msg.Audio.URL, audioTTL)
+			msg.Audio = &models.AudioAttachment{URL: signed, Format: msg.Audio.Format, DurationMs: msg.Audio.DurationMs, SpeechMs: msg.Audio.SpeechMs}
+		}
+		shared.Messages = append(shared.Messages, SharedMessage{
+			Seq:       msg.Seq,
+			Sender:    msg.Sender,
+			Modality:  msg.Modality,
+			Text:      msg.Text(),
+			AudioURL:  signed,
+			Timestamp: msg.Timestamp,
+		})
+	}
+
+	// Renderer HTML tidak boleh menampilkan identitas pemilik chat
+	convo.Username = ""
+	shared.transcript = ChatTranscript{Conversation: convo, Messages: messages}
+
+	logShareAccess(ctx, link, ip, userAgent, format, now)
+	return shared, nil
+}
+
+func logShareAccess(ctx context.Context, link models.ShareLink, ip, userAgent, format string, at time.Time) {
+	_, err := config.MongoDB.Collection("share_access_log").InsertOne(ctx, models.ShareAccess{
+		ShareID:   link.ID,
+		ChatID:    link.ChatID,
+		IP:        ip,
+		UserAgent: userAgent,
+		Format:    format,
+		At:        at,
+	})
+	if err != nil {
+		config.Log.Warn("Gagal mencatat akses tautan berbagi: ", err)
+		return
+	}
+
+	_, err = config.MongoDB.Collection("share_links").UpdateOne(ctx, bson.M{"_id": link.ID},
+		bson.M{"$inc": bson.M{"access_count": 1}, "$set": bson.M{"last_accessed_at": at}})
+	if err != nil {
+		config.Log.Warn("Gagal memperbarui statistik tautan berbagi: ", err)
+	}
+}
+
+// presignAudioURL membuat URL GET bertanda tangan untuk objek audio di bucket aplikasi.
+// URL di luar bucket tidak dibagikan.
+func presignAudioURL(ctx context.Context, audioURL string, ttl time.Duration) string {
+	key, ok := s3KeyFromURL(audioURL)
+	if !ok || ttl <= 0 || s3Client == nil {
+		return ""
+	}
+
+	req, err := s3.NewPresignClient(s3Client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(config.AWSBucketName),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		config.Log.Warn("Gagal membuat URL audio bertanda tangan: ", err)
+		return ""
+	}
+	return req.URL
+}
+
+// deleteShareLinks dipanggil purger saat chat dihapus permanen
+func deleteShareLinks(ctx context.Context, chatID string) (int64, error) {
+	if _, err := config.MongoDB.Collection("share_access_log").DeleteMany(ctx, bson.M{"chat_id": chatID}); err != nil {
+		return 0, err
+	}
+	res, err := config.MongoDB.Collection("share_links").DeleteMany(ctx, bson.M{"chat_id": chatID})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
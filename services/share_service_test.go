@@ -0,0 +1,66 @@
+package services
+
+import (
+	"backend-go/models"
+	"context"
+	"testing"
+	"time"
+)
+
+func sharedTexts(t *testing.T, token string) []string {
+	t.Helper()
+	shared, err := OpenSharedTranscript(token, "127.0.0.1", "test", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, m := range shared.Messages {
+		texts = append(texts, m.Text)
+	}
+	return texts
+}
+
+func TestSharedSnapshotKeepsTurnsEditedLater(t *testing.T) {
+	useTestMongo(t)
+	withoutAWS(t)
+	useFakeNLP(t, "jadwal besok", "Kuliah mulai pukul sembilan.")
+
+	user := models.Message{Sender: "user", Content: "jadwal hari ini", Timestamp: time.Now()}
+	bot := models.Message{Sender: "bot", Content: "Kuliah mulai pukul delapan.", Timestamp: time.Now()}
+	if err := SaveMessages(context.Background(), "chat-berbagi", 1, "a", &user, &bot); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := CreateShareLink("chat-berbagi", 1, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EditUserMessage("chat-berbagi", 1, "a", user.ID.Hex(), "jadwal besok"); err != nil {
+		t.Fatal(err)
+	}
+	after, err := CreateShareLink("chat-berbagi", 1, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		want  []string
+	}{
+		{"tautan sebelum suntingan", before.Token, []string{"jadwal hari ini", "Kuliah mulai pukul delapan."}},
+		{"tautan setelah suntingan", after.Token, []string{"jadwal besok", "Kuliah mulai pukul sembilan."}},
+	} {
+		got := sharedTexts(t, tc.token)
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: pesan = %q, ingin %q", tc.name, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: pesan = %q, ingin %q", tc.name, got, tc.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SpeechText adalah teks balasan bot yang sudah disiapkan untuk TTS: SSML untuk
+// provider yang mendukungnya dan teks biasa (angka sudah dieja) untuk yang tidak
+type SpeechText struct {
+	SSML  string
+	Plain string
+}
+
+// speechSegment adalah potongan teks yang pelafalannya diganti
+type speechSegment struct {
+	start, end int
+	ssml       string // isi SSML pengganti
+	plain      string // ejaan untuk provider tanpa SSML
+}
+
+var (
+	// Rp1.500.000, Rp 250000, Rp.10.000,50, Rp5.000,-
+	rupiahPattern = regexp.MustCompile(`\bRp\.?\s?(\d{1,3}(?:\.\d{3})+|\d+)(?:,(\d{1,2})|,-)?`)
+	// 17/08/2026, 17-8-2026
+	dateDMYPattern = regexp.MustCompile(`\b(\d{1,2})[/-](\d{1,2})[/-](\d{4})\b`)
+	// 2026-08-17
+	dateISOPattern = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+	// Nomor rekening/kartu/telepon: minimal 8 digit, boleh dipisah spasi atau strip
+	spokenDigitPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){7,}\b`)
+)
+
+var monthNamesID = []string{"", "Januari", "Februari", "Maret", "April", "Mei", "Juni",
+	"Juli", "Agustus", "September", "Oktober", "November", "Desember"}
+
+var digitWordsID = []string{"nol", "satu", "dua", "tiga", "empat", "lima", "enam", "tujuh", "delapan", "sembilan"}
+
+// defaultLexicon adalah singkatan perbankan bawaan; entri admin dengan istilah yang
+// sama menggantikannya
+var defaultLexicon = map[string]string{
+	"KPR":  "ka pe er",
+	"KTA":  "ka te a",
+	"ATM":  "a te em",
+	"BI":   "Bank Indonesia",
+	"OJK":  "o je ka",
+	"LPS":  "el pe es",
+	"OTP":  "o te pe",
+	"PIN":  "pin",
+	"NPWP": "en pe we pe",
+	"KTP":  "ka te pe",
+	"CS":   "ce es",
+}
+
+// BuildSpeechText mengubah teks balasan menjadi SSML dan teks biasa yang enak
+// didengar: nominal rupiah dibaca sebagai kata, nomor rekening/kartu dieja per digit
+// dalam kelompok, tanggal dibaca lengkap, dan istilah di leksikon diganti aliasnya.
+func BuildSpeechText(text string, lexicon map[string]string) SpeechText {
+	var segments []speechSegment
+	taken := func(start, end int) bool {
+		for _, s := range segments {
+			if start < s.end && end > s.start {
+				return true
+			}
+		}
+		return false
+	}
+	add := func(pattern *regexp.Regexp, build func(m []string) (speechSegment, bool)) {
+		for _, loc := range pattern.FindAllStringSubmatchIndex(text, -1) {
+			if taken(loc[0], loc[1]) {
+				continue
+			}
+			groups := make([]string, len(loc)/2)
+			for i := range groups {
+				if loc[2*i] >= 0 {
+					groups[i] = text[loc[2*i]:loc[2*i+1]]
+				}
+			}
+			seg, ok := build(groups)
+			if !ok {
+				continue
+			}
+			seg.start, seg.end = loc[0], loc[1]
+			segments = append(segments, seg)
+		}
+	}
+
+	// Urutan menentukan prioritas: nominal dan tanggal lebih dulu dari deret digit
+	add(rupiahPattern, speakRupiah)
+	add(dateISOPattern, func(m []string) (speechSegment, bool) { return speakDate(m[0], m[3], m[2], m[1]) })
+	add(dateDMYPattern, func(m []string) (speechSegment, bool) { return speakDate(m[0], m[1], m[2], m[3]) })
+	add(spokenDigitPattern, speakDigits)
+	if pattern := lexiconPattern(lexicon); pattern != nil {
+		add(pattern, func(m []string) (speechSegment, bool) {
+			alias := lexicon[m[0]]
+			return speechSegment{ssml: ssmlSub(m[0], alias), plain: alias}, true
+		})
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].start < segments[j].start })
+
+	var ssml, plain strings.Builder
+	ssml.WriteString("<speak>")
+	last := 0
+	for _, s := range segments {
+		ssml.WriteString(escapeSSML(text[last:s.start]))
+		plain.WriteString(text[last:s.start])
+		ssml.WriteString(s.ssml)
+		plain.WriteString(s.plain)
+		last = s.end
+	}
+	ssml.WriteString(escapeSSML(text[last:]))
+	plain.WriteString(text[last:])
+	ssml.WriteString("</speak>")
+
+	return SpeechText{SSML: ssml.String(), Plain: plain.String()}
+}
+
+// ssmlBody mengembalikan isi dokumen SSML tanpa elemen <speak>
+func ssmlBody(ssml string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(ssml, "<speak>"), "</speak>")
+}
+
+func ssmlSub(original, alias string) string {
+	return `<sub alias="` + escapeSSML(alias) + `">` + escapeSSML(original) + `</sub>`
+}
+
+func speakRupiah(m []string) (speechSegment, bool) {
+	amount, err := strconv.ParseInt(strings.ReplaceAll(m[1], ".", ""), 10, 64)
+	if err != nil {
+		return speechSegment{}, false
+	}
+	spoken := NumberToWordsID(amount) + " rupiah"
+	if cents, _ := strconv.ParseInt(m[2], 10, 64); cents > 0 {
+		if len(m[2]) == 1 {
+			cents *= 10
+		}
+		spoken += " " + NumberToWordsID(cents) + " sen"
+	}
+	return speechSegment{ssml: ssmlSub(m[0], spoken), plain: spoken}, true
+}
+
+// speakDate membaca tanggal lengkap; tanggal yang tidak ada (mis. 31/02) dibiarkan
+// karena time.Date menggesernya ke bulan berikutnya
+func speakDate(original, day, month, year string) (speechSegment, bool) {
+	d, _ := strconv.Atoi(day)
+	mo, _ := strconv.Atoi(month)
+	y, _ := strconv.Atoi(year)
+	if mo < 1 || mo > 12 {
+		return speechSegment{}, false
+	}
+	if t := time.Date(y, time.Month(mo), d, 0, 0, 0, 0, time.UTC); t.Day() != d || int(t.Month()) != mo {
+		return speechSegment{}, false
+	}
+	spoken := NumberToWordsID(int64(d)) + " " + monthNamesID[mo] + " " + NumberToWordsID(int64(y))
+	return speechSegment{ssml: ssmlSub(original, spoken), plain: spoken}, true
+}
+
+// speakDigits mengeja deret digit per angka. Pemisah dari teks asli dipakai sebagai
+// batas kelompok; tanpa pemisah, digit dikelompokkan per empat (kartu) atau per tiga
+// (rekening 10 digit dibaca 3-3-4).
+func speakDigits(m []string) (speechSegment, bool) {
+	groups := strings.FieldsFunc(m[0], func(r rune) bool { return r == ' ' || r == '-' })
+	if len(groups) == 1 {
+		groups = groupDigits(groups[0])
+	}
+
+	ssmlParts := make([]string, len(groups))
+	plainParts := make([]string, len(groups))
+	for i, g := range groups {
+		ssmlParts[i] = `<say-as interpret-as="characters">` + g + `</say-as>`
+		words := make([]string, len(g))
+		for j, r := range g {
+			words[j] = digitWordsID[r-'0']
+		}
+		plainParts[i] = strings.Join(words, " ")
+	}
+	return speechSegment{
+		ssml:  strings.Join(ssmlParts, `<break time="300ms"/>`),
+		plain: strings.Join(plainParts, ", "),
+	}, true
+}
+
+func groupDigits(s string) []string {
+	size := 3
+	if len(s)%4 == 0 {
+		size = 4
+	}
+	var groups []string
+	for len(s) > size {
+		groups = append(groups, s[:size])
+		s = s[size:]
+	}
+	if len(s) == 1 && len(groups) > 0 {
+		// Satu digit tersisa digabung ke kelompok sebelumnya
+		groups[len(groups)-1] += s
+		return groups
+	}
+	return append(groups, s)
+}
+
+// lexiconPattern mencocokkan istilah leksikon sebagai kata utuh; istilah terpanjang
+// didahulukan agar "KPR Syariah" menang atas "KPR"
+func lexiconPattern(lexicon map[string]string) *regexp.Regexp {
+	if len(lexicon) == 0 {
+		return nil
+	}
+	terms := make([]string, 0, len(lexicon))
+	for term := range lexicon {
+		terms = append(terms, regexp.QuoteMeta(term))
+	}
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	return regexp.MustCompile(`\b(?:` + strings.Join(terms, "|") + `)\b`)
+}
+
+// NumberToWordsID mengubah bilangan bulat menjadi kata bahasa Indonesia (terbilang)
+func NumberToWordsID(n int64) string {
+	if n == 0 {
+		return "nol"
+	}
+	if n < 0 {
+		return "minus " + NumberToWordsID(-n)
+	}
+
+	scales := []struct {
+		value int64
+		name  string
+	}{
+		{1_000_000_000_000, "triliun"},
+		{1_000_000_000, "miliar"},
+		{1_000_000, "juta"},
+		{1_000, "ribu"},
+	}
+
+	var parts []string
+	for _, s := range scales {
+		if n < s.value {
+			continue
+		}
+		q := n / s.value
+		n %= s.value
+		if s.value == 1_000 && q == 1 {
+			parts = append(parts, "seribu")
+		} else {
+			parts = append(parts, NumberToWordsID(q)+" "+s.name)
+		}
+	}
+	if n > 0 {
+		parts = append(parts, numberBelowThousandID(n))
+	}
+	return strings.Join(parts, " ")
+}
+
+func numberBelowThousandID(n int64) string {
+	var parts []string
+	if h := n / 100; h > 0 {
+		if h == 1 {
+			parts = append(parts, "seratus")
+		} else {
+			parts = append(parts, digitWordsID[h]+" ratus")
+		}
+		n %= 100
+	}
+	switch {
+	case n == 0:
+	case n == 10:
+		parts = append(parts, "sepuluh")
+	case n == 11:
+		parts = append(parts, "sebelas")
+	case n < 10:
+		parts = append(parts, digitWordsID[n])
+	case n < 20:
+		parts = append(parts, digitWordsID[n-10]+" belas")
+	default:
+		tens := digitWordsID[n/10] + " puluh"
+		if n%10 > 0 {
+			tens += " " + digitWordsID[n%10]
+		}
+		parts = append(parts, tens)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildSpeechText(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		text    string
+		lexicon map[string]string
+		plain   string
+		ssml    string
+	}{
+		{
+			name:  "rupiah dengan titik ribuan",
+			text:  "Cicilan Rp1.500.000 per bulan",
+			plain: "Cicilan satu juta lima ratus ribu rupiah per bulan",
+			ssml:  `<speak>Cicilan <sub alias="satu juta lima ratus ribu rupiah">Rp1.500.000</sub> per bulan</speak>`,
+		},
+		{
+			name:  "rupiah dengan ,-",
+			text:  "Biaya Rp5.000,-",
+			plain: "Biaya lima ribu rupiah",
+		},
+		{
+			name:  "rupiah dengan satu digit sen",
+			text:  "Rp10.000,5",
+			plain: "sepuluh ribu rupiah lima puluh sen",
+		},
+		{
+			name:  "rupiah dengan spasi dan titik",
+			text:  "Rp. 250000",
+			plain: "dua ratus lima puluh ribu rupiah",
+		},
+		{
+			name:  "rekening 10 digit",
+			text:  "Rekening 1234567890",
+			plain: "Rekening satu dua tiga, empat lima enam, tujuh delapan sembilan nol",
+			ssml: `<speak>Rekening <say-as interpret-as="characters">123</say-as><break time="300ms"/>` +
+				`<say-as interpret-as="characters">456</say-as><break time="300ms"/>` +
+				`<say-as interpret-as="characters">7890</say-as></speak>`,
+		},
+		{
+			name:  "kartu dengan pemisah asli",
+			text:  "4111-1111-1111-1111",
+			plain: "empat satu satu satu, satu satu satu satu, satu satu satu satu, satu satu satu satu",
+		},
+		{
+			name:  "tanggal ISO",
+			text:  "Jatuh tempo 2026-08-17.",
+			plain: "Jatuh tempo tujuh belas Agustus dua ribu dua puluh enam.",
+		},
+		{
+			name:  "tanggal DMY",
+			text:  "Jatuh tempo 1/2/2026",
+			plain: "Jatuh tempo satu Februari dua ribu dua puluh enam",
+		},
+		{
+			name:  "tanggal kabisat",
+			text:  "29/02/2028",
+			plain: "dua puluh sembilan Februari dua ribu dua puluh delapan",
+		},
+		{
+			name:  "tanggal yang tidak ada dibiarkan",
+			text:  "31/02/2026",
+			plain: "31/02/2026",
+		},
+		{
+			name:  "bulan tidak valid dibiarkan",
+			text:  "17/13/2026",
+			plain: "17/13/2026",
+		},
+		{
+			name:    "leksikon memilih istilah terpanjang",
+			text:    "Ajukan KPR Syariah atau KPR & KTA",
+			lexicon: map[string]string{"KPR": "ka pe er", "KPR Syariah": "ka pe er syariah", "KTA": "ka te a"},
+			plain:   "Ajukan ka pe er syariah atau ka pe er & ka te a",
+			ssml: `<speak>Ajukan <sub alias="ka pe er syariah">KPR Syariah</sub> atau ` +
+				`<sub alias="ka pe er">KPR</sub> &amp; <sub alias="ka te a">KTA</sub></speak>`,
+		},
+		{
+			name:    "leksikon hanya kata utuh",
+			text:    "KPRS bukan istilah",
+			lexicon: map[string]string{"KPR": "ka pe er"},
+			plain:   "KPRS bukan istilah",
+		},
+	} {
+		got := BuildSpeechText(tc.text, tc.lexicon)
+		if got.Plain != tc.plain {
+			t.Errorf("%s: plain = %q, ingin %q", tc.name, got.Plain, tc.plain)
+		}
+		if tc.ssml != "" && got.SSML != tc.ssml {
+			t.Errorf("%s: ssml = %q, ingin %q", tc.name, got.SSML, tc.ssml)
+		}
+	}
+}
+
+func TestGroupDigits(t *testing.T) {
+	for _, tc := range []struct {
+		digits string
+		want   []string
+	}{
+		{"1234567890", []string{"123", "456", "7890"}},
+		{"4111111111111111", []string{"4111", "1111", "1111", "1111"}},
+		{"12345678", []string{"1234", "5678"}},
+		{"123456789", []string{"123", "456", "789"}},
+	} {
+		if got := groupDigits(tc.digits); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("groupDigits(%s) = %q, ingin %q", tc.digits, got, tc.want)
+		}
+	}
+}
+
+func TestNumberToWordsID(t *testing.T) {
+	for n, want := range map[int64]string{
+		0:             "nol",
+		11:            "sebelas",
+		100:           "seratus",
+		1000:          "seribu",
+		1_500_000:     "satu juta lima ratus ribu",
+		2026:          "dua ribu dua puluh enam",
+		1_000_000_000: "satu miliar",
+	} {
+		if got := NumberToWordsID(n); got != want {
+			t.Errorf("NumberToWordsID(%d) = %q, ingin %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"backend-go/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	speech "cloud.google.com/go/speech/apiv1"
+	"cloud.google.com/go/speech/apiv1/speechpb"
+)
+
+// voiceSampleRate adalah sample rate audio hasil konversi yang dikirim ke STT
+const voiceSampleRate = 16000
+
+var ErrUnknownSTTProvider = errors.New("provider STT tidak dikenal")
+
+// STTRequest adalah audio yang akan ditranskripsi
+type STTRequest struct {
+	Audio    []byte // mono 16 kHz
+	Format   string // mp3, ogg, atau wav (VOICE_STT_FORMAT)
+	Language string // kode BCP-47, mis. id-ID
+	MediaURL string // URL S3 audio; wajib untuk AWS Transcribe
+	JobName  string // nama unik untuk provider berbasis job (AWS Transcribe)
+}
+
+// STTResult adalah hasil transkripsi beserta provider yang menghasilkannya
+type STTResult struct {
+	Transcript string
+	Provider   string
+}
+
+// STTProvider adalah mesin speech-to-text
+type STTProvider interface {
+	Name() string
+	// NeedsMediaURL bernilai true bila provider membaca audio dari S3 (STTRequest.MediaURL)
+	// alih-alih dari STTRequest.Audio
+	NeedsMediaURL() bool
+	Transcribe(ctx context.Context, req STTRequest) (string, error)
+}
+
+// sttProviders memetakan nama di konfigurasi ke konstruktor provider
+var sttProviders = map[string]func() (STTProvider, error){
+	"aws":    newAWSTranscribeSTT,
+	"http":   newHTTPSTT,
+	"google": newGoogleSTT,
+	"fake":   func() (STTProvider, error) { return fakeSTT{transcript: config.STTFakeTranscript}, nil },
+}
+
+// sttChain berisi provider utama lalu cadangannya
+var sttChain []STTProvider
+
+// InitSTT menyusun provider STT dari STT_PROVIDER dan STT_FALLBACK_PROVIDER
+func InitSTT() error {
+	sttChain = nil
+	for _, name := range []string{config.STTProvider, config.STTFallbackProvider} {
+		if name == "" {
+			continue
+		}
+		newProvider, ok := sttProviders[name]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownSTTProvider, name)
+		}
+		p, err := newProvider()
+		if err != nil {
+			return fmt.Errorf("gagal menginisialisasi STT %s: %v", name, err)
+		}
+		sttChain = append(sttChain, p)
+	}
+	if len(sttChain) == 0 {
+		return fmt.Errorf("%w: STT_PROVIDER kosong", ErrUnknownSTTProvider)
+	}
+
+	names := make([]string, len(sttChain))
+	for i, p := range sttChain {
+		names[i] = p.Name()
+	}
+	config.Log.Info("🗣️ Provider STT: ", strings.Join(names, " → "))
+	return nil
+}
+
+// STTNeedsMediaURL bernilai true bila salah satu provider di rantai STT membutuhkan
+// audio di S3, sehingga tahap unggah harus menyiapkan MediaURL dan nama job
+func STTNeedsMediaURL() bool {
+	for _, p := range sttChain {
+		if p.NeedsMediaURL() {
+			return true
+		}
+	}
+	return false
+}
+
+// TranscribeSpeech menjalankan provider utama dan beralih ke cadangan bila gagal atau
+// melewati STT_TIMEOUT
+func TranscribeSpeech(ctx context.Context, req STTRequest) (*STTResult, error) {
+	if req.Language == "" {
+		req.Language = "id-ID"
+	}
+	if req.Format == "" {
+		req.Format = AudioFormatMP3
+	}
+	if !ValidAudioFormat(req.Format) {
+		return nil, fmt.Errorf("%w: %s", ErrAudioFormatUnsupported, req.Format)
+	}
+
+	var errs []error
+	for _, p := range sttChain {
+		pctx, cancel := context.WithTimeout(ctx, config.STTTimeout)
+		transcript, err := p.Transcribe(pctx, req)
+		cancel()
+		if err == nil {
+			return &STTResult{Transcript: transcript, Provider: p.Name()}, nil
+		}
+
+		config.Log.Warnf("STT %s gagal: %v", p.Name(), err)
+		errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// ==== AWS Transcribe (batch lewat S3) ====
+
+type awsTranscribeSTT struct{}
+
+func newAWSTranscribeSTT() (STTProvider, error) {
+	if !config.AWSEnabled() {
+		return nil, errors.New("AWS belum dikonfigurasi (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_BUCKET_NAME)")
+	}
+	return awsTranscribeSTT{}, nil
+}
+
+func (awsTranscribeSTT) Name() string { return "aws" }
+
+func (awsTranscribeSTT) NeedsMediaURL() bool { return true }
+
+func (awsTranscribeSTT) Transcribe(ctx context.Context, req STTRequest) (string, error) {
+	if req.MediaURL == "" || req.JobName == "" {
+		return "", errors.New("AWS Transcribe membutuhkan audio di S3")
+	}
+	if err := ensureTranscriptionJob(ctx, req.JobName, req.MediaURL, audioOutputFormats[req.Format].transcribe); err != nil {
+		return "", err
+	}
+	return GetTranscriptionResult(ctx, req.JobName)
+}
+
+// ==== Server HTTP lokal (Whisper/Vosk) ====
+
+// httpSTT memanggil server transkripsi lokal dengan API yang kompatibel dengan
+// POST /v1/audio/transcriptions (faster-whisper-server, whisper.cpp server, atau
+// pembungkus Vosk). Respons JSON dibaca dari field "text" atau "transcript".
+type httpSTT struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPSTT() (STTProvider, error) {
+	if config.STTHTTPURL == "" {
+		return nil, errors.New("STT_HTTP_URL belum diatur")
+	}
+	return httpSTT{url: config.STTHTTPURL, client: &http.Client{}}, nil
+}
+
+func (httpSTT) Name() string { return "http" }
+
+func (httpSTT) NeedsMediaURL() bool { return false }
+
+func (p httpSTT) Transcribe(ctx context.Context, req STTRequest) (string, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	fw, err := w.CreateFormFile("file", "audio."+req.Format)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fw.Write(req.Audio); err != nil {
+		return "", err
+	}
+	// Server Whisper memakai kode bahasa ISO-639-1 ("id"), bukan "id-ID"
+	_ = w.WriteField("language", strings.SplitN(req.Language, "-", 2)[0])
+	_ = w.WriteField("response_format", "json")
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, &body)
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", w.FormDataContentType())
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("gagal menghubungi server STT: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("server STT membalas %s: %s", resp.Status, msg)
+	}
+
+	var result struct {
+		Text       string `json:"text"`
+		Transcript string `json:"transcript"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("gagal mendekode respons STT: %v", err)
+	}
+	transcript := strings.TrimSpace(result.Text + result.Transcript)
+	if transcript == "" {
+		return "", errors.New("server STT mengembalikan transkrip kosong")
+	}
+	return transcript, nil
+}
+
+// ==== Google Speech-to-Text ====
+
+// googleSTT memakai Recognize sinkron sehingga audio dibatasi sekitar satu menit,
+// cukup untuk pesan suara chatbot
+type googleSTT struct {
+	client *speech.Client
+}
+
+func newGoogleSTT() (STTProvider, error) {
+	client, err := speech.NewClient(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return googleSTT{client: client}, nil
+}
+
+func (googleSTT) Name() string { return "google" }
+
+func (googleSTT) NeedsMediaURL() bool { return false }
+
+func (p googleSTT) Transcribe(ctx context.Context, req STTRequest) (string, error) {
+	resp, err := p.client.Recognize(ctx, &speechpb.RecognizeRequest{
+		Config: &speechpb.RecognitionConfig{
+			Encoding:                   audioOutputFormats[req.Format].google,
+			SampleRateHertz:            voiceSampleRate,
+			LanguageCode:               req.Language,
+			EnableAutomaticPunctuation: true,
+		},
+		Audio: &speechpb.RecognitionAudio{
+			AudioSource: &speechpb.RecognitionAudio_Content{Content: req.Audio},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("gagal mentranskrip dengan Google: %v", err)
+	}
+
+	var parts []string
+	for _, r := range resp.Results {
+		if len(r.Alternatives) > 0 {
+			parts = append(parts, strings.TrimSpace(r.Alternatives[0].Transcript))
+		}
+	}
+	transcript := strings.TrimSpace(strings.Join(parts, " "))
+	if transcript == "" {
+		return "", errors.New("tidak ditemukan transkrip dalam hasil")
+	}
+	return transcript, nil
+}
+
+// ==== Fake untuk pengembangan dan pengujian ====
+
+// fakeSTT selalu mengembalikan transkrip yang sama tanpa memanggil layanan apa pun
+type fakeSTT struct {
+	transcript string
+}
+
+func (fakeSTT) Name() string { return "fake" }
+
+func (fakeSTT) NeedsMediaURL() bool { return false }
+
+func (p fakeSTT) Transcribe(ctx context.Context, req STTRequest) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if len(req.Audio) == 0 {
+		return "", errors.New("audio kosong")
+	}
+	return p.transcript, nil
+}
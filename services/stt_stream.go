@@ -0,0 +1,218 @@
+package services
+
+import (
+	"backend-go/config"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/speech/apiv1/speechpb"
+)
+
+// Encoding audio yang diterima endpoint suara streaming
+const (
+	StreamEncodingPCM16    = "pcm16"     // PCM signed 16-bit little-endian mono
+	StreamEncodingWebMOpus = "webm_opus" // potongan MediaRecorder browser
+)
+
+// STTStreamConfig adalah parameter satu ucapan yang di-stream
+type STTStreamConfig struct {
+	Language   string
+	Encoding   string
+	SampleRate int
+}
+
+// STTStreamResult adalah transkrip sementara (Final=false) atau final dari stream
+type STTStreamResult struct {
+	Text  string
+	Final bool
+	Err   error
+}
+
+// STTStream adalah satu ucapan yang sedang ditranskripsi. Results ditutup setelah
+// hasil terakhir terkirim, yaitu setelah CloseSend atau saat terjadi error.
+type STTStream interface {
+	Send(chunk []byte) error
+	CloseSend() error
+	Results() <-chan STTStreamResult
+}
+
+// StreamingSTTProvider adalah mesin speech-to-text yang menerima audio sepotong demi sepotong
+type StreamingSTTProvider interface {
+	Name() string
+	StartStream(ctx context.Context, cfg STTStreamConfig) (STTStream, error)
+}
+
+// sttStreamProviders memetakan STT_STREAM_PROVIDER ke konstruktor provider
+var sttStreamProviders = map[string]func() (StreamingSTTProvider, error){
+	"google": func() (StreamingSTTProvider, error) {
+		p, err := newGoogleSTT()
+		if err != nil {
+			return nil, err
+		}
+		return p.(googleSTT), nil
+	},
+	"fake": func() (StreamingSTTProvider, error) { return fakeSTT{transcript: config.STTFakeTranscript}, nil },
+}
+
+// sttStream adalah provider untuk endpoint suara streaming
+var sttStream StreamingSTTProvider
+
+// InitStreamingSTT menyiapkan provider STT_STREAM_PROVIDER
+func InitStreamingSTT() error {
+	newProvider, ok := sttStreamProviders[config.STTStreamProvider]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownSTTProvider, config.STTStreamProvider)
+	}
+	p, err := newProvider()
+	if err != nil {
+		return fmt.Errorf("gagal menginisialisasi STT streaming %s: %v", config.STTStreamProvider, err)
+	}
+	sttStream = p
+	config.Log.Info("🎧 Provider STT streaming: ", p.Name())
+	return nil
+}
+
+// ==== Google Speech-to-Text (StreamingRecognize) ====
+
+func (p googleSTT) StartStream(ctx context.Context, cfg STTStreamConfig) (STTStream, error) {
+	stream, err := p.client.StreamingRecognize(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuka stream Google: %v", err)
+	}
+
+	recognition := &speechpb.RecognitionConfig{
+		Encoding:                   speechpb.RecognitionConfig_LINEAR16,
+		SampleRateHertz:            int32(cfg.SampleRate),
+		LanguageCode:               cfg.Language,
+		EnableAutomaticPunctuation: true,
+	}
+	if cfg.Encoding == StreamEncodingWebMOpus {
+		recognition.Encoding = speechpb.RecognitionConfig_WEBM_OPUS
+		recognition.SampleRateHertz = 48000
+	}
+	err = stream.Send(&speechpb.StreamingRecognizeRequest{
+		StreamingRequest: &speechpb.StreamingRecognizeRequest_StreamingConfig{
+			StreamingConfig: &speechpb.StreamingRecognitionConfig{
+				Config:         recognition,
+				InterimResults: true,
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengirim konfigurasi stream Google: %v", err)
+	}
+
+	s := &googleSTTStream{stream: stream, results: make(chan STTStreamResult, 16)}
+	go s.receive()
+	return s, nil
+}
+
+type googleSTTStream struct {
+	stream  speechpb.Speech_StreamingRecognizeClient
+	results chan STTStreamResult
+}
+
+func (s *googleSTTStream) Send(chunk []byte) error {
+	return s.stream.Send(&speechpb.StreamingRecognizeRequest{
+		StreamingRequest: &speechpb.StreamingRecognizeRequest_AudioContent{AudioContent: chunk},
+	})
+}
+
+func (s *googleSTTStream) CloseSend() error { return s.stream.CloseSend() }
+
+func (s *googleSTTStream) Results() <-chan STTStreamResult { return s.results }
+
+func (s *googleSTTStream) receive() {
+	defer close(s.results)
+	for {
+		resp, err := s.stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			s.results <- STTStreamResult{Err: fmt.Errorf("stream Google gagal: %v", err)}
+			return
+		}
+		for _, r := range resp.Results {
+			if len(r.Alternatives) == 0 {
+				continue
+			}
+			s.results <- STTStreamResult{
+				Text:  strings.TrimSpace(r.Alternatives[0].Transcript),
+				Final: r.IsFinal,
+			}
+		}
+	}
+}
+
+// ==== Fake untuk pengembangan dan pengujian ====
+
+// fakeStreamBytesPerWord menentukan seberapa cepat transkrip sementara bertambah:
+// satu kata per seperempat detik PCM 16 kHz
+const fakeStreamBytesPerWord = voiceSampleRate / 2
+
+// StartStream pada fakeSTT mengeluarkan transkrip tetap kata demi kata seiring audio
+// masuk, lalu transkrip final saat CloseSend, tanpa layanan eksternal
+func (p fakeSTT) StartStream(ctx context.Context, cfg STTStreamConfig) (STTStream, error) {
+	return &fakeSTTStream{
+		ctx:     ctx,
+		words:   strings.Fields(p.transcript),
+		results: make(chan STTStreamResult, 64),
+	}, nil
+}
+
+type fakeSTTStream struct {
+	mu       sync.Mutex
+	ctx      context.Context
+	words    []string
+	received int
+	emitted  int
+	closed   bool
+	results  chan STTStreamResult
+}
+
+func (s *fakeSTTStream) Send(chunk []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("stream sudah ditutup")
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	s.received += len(chunk)
+	if n := min(len(s.words), s.received/fakeStreamBytesPerWord+1); n > s.emitted {
+		s.emitted = n
+		select {
+		case s.results <- STTStreamResult{Text: strings.Join(s.words[:n], " ")}:
+		default: // hasil sementara boleh dilewati bila pembaca lambat
+		}
+	}
+	return nil
+}
+
+func (s *fakeSTTStream) CloseSend() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	go func(received int) {
+		defer close(s.results)
+		if received == 0 {
+			s.results <- STTStreamResult{Err: errors.New("audio kosong")}
+			return
+		}
+		s.results <- STTStreamResult{Text: strings.Join(s.words, " "), Final: true}
+	}(s.received)
+	return nil
+}
+
+func (s *fakeSTTStream) Results() <-chan STTStreamResult { return s.results }
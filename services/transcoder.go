@@ -0,0 +1,278 @@
+package services
+
+import (
+	"backend-go/config"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAudioUnsupported = errors.New("berkas bukan audio yang dapat dibaca")
+	ErrAudioTooLarge    = errors.New("ukuran berkas audio melebihi batas")
+	ErrAudioTooLong     = errors.New("durasi audio melebihi batas")
+)
+
+// ffmpegStderrLimit membatasi potongan stderr ffmpeg yang dimasukkan ke pesan error
+const ffmpegStderrLimit = 500
+
+// AudioProbe adalah hasil ffprobe untuk stream audio pertama
+type AudioProbe struct {
+	Container  string        // mis. "matroska,webm", "mp3", "wav"
+	Codec      string        // mis. "opus", "mp3", "pcm_s16le"
+	Duration   time.Duration // 0 bila tidak diketahui (umum pada webm MediaRecorder)
+	SampleRate int
+	Channels   int
+}
+
+// TranscodeOptions mengatur satu konversi ffmpeg
+type TranscodeOptions struct {
+	InputArgs   []string      // argumen sebelum -i, mis. "-f s16le -ar 16000 -ac 1" untuk PCM mentah
+	Format      string        // format keluaran ffmpeg (-f), mis. "mp3"
+	Codec       string        // encoder audio (-c:a), kosong = default muxer
+	SampleRate  int           // 0 = ikuti masukan
+	Channels    int           // 0 = ikuti masukan
+	Filter      string        // filter audio (-af), opsional
+	MaxDuration time.Duration // keluaran dipotong pada durasi ini; 0 = tanpa batas
+}
+
+// Transcoder menjalankan ffmpeg dan ffprobe dengan batas proses bersamaan, batas waktu,
+// dan direktori kerja unik per panggilan yang selalu dihapus setelah selesai
+type Transcoder struct {
+	ffmpeg  string
+	ffprobe string
+	slots   chan struct{}
+	timeout time.Duration
+	tempDir string
+}
+
+// transcoder dipakai semua konversi audio di proses ini
+var transcoder *Transcoder
+
+// InitTranscoder memastikan ffmpeg dan ffprobe tersedia lalu menyiapkan transcoder
+func InitTranscoder() error {
+	for _, format := range []string{config.VoiceOutputFormat, config.VoiceSTTFormat} {
+		if !ValidAudioFormat(format) {
+			return fmt.Errorf("%w: %s", ErrAudioFormatUnsupported, format)
+		}
+	}
+	t, err := NewTranscoder(config.FFmpegPath, config.FFprobePath,
+		config.TranscodeConcurrency, config.TranscodeTimeout, config.TranscodeTempDir)
+	if err != nil {
+		return err
+	}
+	transcoder = t
+	config.Log.Infof("🎚️ Transcoder siap (%d proses bersamaan)", cap(t.slots))
+	return nil
+}
+
+// NewTranscoder membuat transcoder setelah memeriksa kedua biner bisa dijalankan
+func NewTranscoder(ffmpeg, ffprobe string, concurrency int, timeout time.Duration, tempDir string) (*Transcoder, error) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	if err := os.MkdirAll(tempDir, 0o700); err != nil {
+		return nil, fmt.Errorf("gagal membuat direktori kerja transcoder: %v", err)
+	}
+
+	t := &Transcoder{
+		slots:   make(chan struct{}, concurrency),
+		timeout: timeout,
+		tempDir: tempDir,
+	}
+	for _, bin := range []struct {
+		name string
+		dst  *string
+	}{{ffmpeg, &t.ffmpeg}, {ffprobe, &t.ffprobe}} {
+		path, err := exec.LookPath(bin.name)
+		if err != nil {
+			return nil, fmt.Errorf("%s tidak ditemukan: %v", bin.name, err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = exec.CommandContext(ctx, path, "-version").Run()
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("%s tidak dapat dijalankan: %v", bin.name, err)
+		}
+		*bin.dst = path
+	}
+	return t, nil
+}
+
+// ProbeFile membaca format, codec, dan durasi berkas audio. Berkas tanpa stream audio
+// menghasilkan ErrAudioUnsupported.
+func (t *Transcoder) ProbeFile(ctx context.Context, path string) (*AudioProbe, error) {
+	var probe *AudioProbe
+	err := t.run(ctx, func(ctx context.Context, _ string) error {
+		out, _, err := t.command(ctx, t.ffprobe,
+			"-v", "error", "-print_format", "json", "-show_format", "-show_streams", "-select_streams", "a:0", path)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			return fmt.Errorf("%w: %v", ErrAudioUnsupported, err)
+		}
+		probe, err = parseProbe(out)
+		return err
+	})
+	return probe, err
+}
+
+// Transcode mengonversi audio di memori. Masukan ditulis ke direktori kerja unik, bukan
+// ke nama yang berasal dari klien, dan direktori itu dihapus di semua jalur keluar.
+func (t *Transcoder) Transcode(ctx context.Context, input []byte, opts TranscodeOptions) ([]byte, error) {
+	var output []byte
+	err := t.run(ctx, func(ctx context.Context, dir string) error {
+		in := filepath.Join(dir, "input")
+		out := filepath.Join(dir, "output")
+		if err := os.WriteFile(in, input, 0o600); err != nil {
+			return fmt.Errorf("gagal menulis audio sementara: %v", err)
+		}
+
+		args := append([]string{"-hide_banner", "-nostdin", "-loglevel", "error"}, opts.InputArgs...)
+		args = append(args, "-i", in, "-vn")
+		if opts.MaxDuration > 0 {
+			args = append(args, "-t", strconv.FormatFloat(opts.MaxDuration.Seconds(), 'f', -1, 64))
+		}
+		if opts.Channels > 0 {
+			args = append(args, "-ac", strconv.Itoa(opts.Channels))
+		}
+		if opts.SampleRate > 0 {
+			args = append(args, "-ar", strconv.Itoa(opts.SampleRate))
+		}
+		if opts.Filter != "" {
+			args = append(args, "-af", opts.Filter)
+		}
+		if opts.Codec != "" {
+			args = append(args, "-c:a", opts.Codec)
+		}
+		args = append(args, "-f", opts.Format, "-y", out)
+
+		if _, _, err := t.command(ctx, t.ffmpeg, args...); err != nil {
+			return fmt.Errorf("konversi audio gagal: %w", err)
+		}
+		data, err := os.ReadFile(out)
+		if err != nil {
+			return fmt.Errorf("gagal membaca hasil konversi: %v", err)
+		}
+		if len(data) == 0 {
+			return errors.New("konversi audio menghasilkan berkas kosong")
+		}
+		output = data
+		return nil
+	})
+	return output, err
+}
+
+// Analyze menjalankan filter analisis (mis. silencedetect) tanpa menghasilkan berkas dan
+// mengembalikan log ffmpeg, tempat filter semacam itu menulis hasilnya
+func (t *Transcoder) Analyze(ctx context.Context, input []byte, filter string) (string, error) {
+	var log string
+	err := t.run(ctx, func(ctx context.Context, dir string) error {
+		in := filepath.Join(dir, "input")
+		if err := os.WriteFile(in, input, 0o600); err != nil {
+			return fmt.Errorf("gagal menulis audio sementara: %v", err)
+		}
+		_, stderr, err := t.command(ctx, t.ffmpeg,
+			"-hide_banner", "-nostdin", "-nostats", "-loglevel", "info", "-i", in, "-vn", "-af", filter, "-f", "null", "-")
+		if err != nil {
+			return fmt.Errorf("analisis audio gagal: %w", err)
+		}
+		log = stderr
+		return nil
+	})
+	return log, err
+}
+
+// run menunggu slot proses, memasang batas waktu, dan menyediakan direktori kerja
+// yang dihapus apa pun hasilnya
+func (t *Transcoder) run(ctx context.Context, fn func(ctx context.Context, dir string) error) error {
+	select {
+	case t.slots <- struct{}{}:
+		defer func() { <-t.slots }()
+	case <-ctx.Done():
+		return fmt.Errorf("menunggu antrean transcoder: %w", ctx.Err())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	dir, err := os.MkdirTemp(t.tempDir, "transcode-*")
+	if err != nil {
+		return fmt.Errorf("gagal membuat direktori kerja transcoder: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	return fn(ctx, dir)
+}
+
+// command menjalankan biner dan mengembalikan stdout serta stderr; potongan stderr
+// disertakan pada error
+func (t *Transcoder) command(ctx context.Context, bin string, args ...string) ([]byte, string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, args...)
+	// Proses anak yang masih memegang pipe tidak boleh menahan Wait setelah dibatalkan
+	cmd.WaitDelay = 2 * time.Second
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, "", fmt.Errorf("%s dihentikan: %w", filepath.Base(bin), ctx.Err())
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > ffmpegStderrLimit {
+			msg = msg[len(msg)-ffmpegStderrLimit:]
+		}
+		return nil, "", fmt.Errorf("%s: %v: %s", filepath.Base(bin), err, msg)
+	}
+	return stdout.Bytes(), stderr.String(), nil
+}
+
+func parseProbe(out []byte) (*AudioProbe, error) {
+	var raw struct {
+		Streams []struct {
+			CodecName  string `json:"codec_name"`
+			SampleRate string `json:"sample_rate"`
+			Channels   int    `json:"channels"`
+			Duration   string `json:"duration"`
+		} `json:"streams"`
+		Format struct {
+			FormatName string `json:"format_name"`
+			Duration   string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out, &raw); err != nil {
+		return nil, fmt.Errorf("%w: keluaran ffprobe tidak valid", ErrAudioUnsupported)
+	}
+	if len(raw.Streams) == 0 {
+		return nil, ErrAudioUnsupported
+	}
+
+	stream := raw.Streams[0]
+	probe := &AudioProbe{
+		Container: raw.Format.FormatName,
+		Codec:     stream.CodecName,
+		Channels:  stream.Channels,
+	}
+	probe.SampleRate, _ = strconv.Atoi(stream.SampleRate)
+
+	// Durasi container lebih andal untuk webm dari MediaRecorder yang sering tanpa durasi stream
+	for _, d := range []string{raw.Format.Duration, stream.Duration} {
+		if secs, err := strconv.ParseFloat(d, 64); err == nil && secs > 0 {
+			probe.Duration = time.Duration(secs * float64(time.Second))
+			break
+		}
+	}
+	return probe, nil
+}
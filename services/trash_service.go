@@ -0,0 +1,395 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/transcribe"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrChatNotInTrash = errors.New("chat tidak ada di tong sampah atau masa simpannya sudah lewat")
+
+// notDeleted adalah filter untuk dokumen yang belum masuk tong sampah
+var notDeleted = bson.M{"$exists": false}
+
+// TrashItem adalah chat di tong sampah beserta waktu penghapusan permanennya
+type TrashItem struct {
+	ChatListItem
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// PurgeReport mencatat apa saja yang dihapus permanen untuk satu chat
+type PurgeReport struct {
+	ChatID         string `json:"chat_id"`
+	Messages       int64  `json:"messages"`
+	VoiceMessages  int64  `json:"voice_messages"`
+	S3Objects      int    `json:"s3_objects"`
+	TranscribeJobs int    `json:"transcribe_jobs"`
+	ShareLinks     int64  `json:"share_links"`
+	VoiceJobs      int64  `json:"voice_jobs"`
+	ExportJobs     int64  `json:"export_jobs"`
+	ExportFiles    int    `json:"export_files"`
+	IdempotentKeys int64  `json:"idempotency_keys"`
+	UsersCleared   int64  `json:"users_cleared"`
+}
+
+func trashRetention() time.Duration {
+	return time.Duration(config.TrashRetentionDays) * 24 * time.Hour
+}
+
+// DeleteChat memindahkan chat ke tong sampah. Pesan ikut ditandai agar hilang dari
+// pencarian; penghapusan permanen dilakukan purger setelah masa simpan habis.
+func DeleteChat(chatID string, userID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{"chat_id": chatID, "user_id": userID, "deleted_at": notDeleted}
+
+	result, err := config.MongoDB.Collection("conversations").UpdateOne(ctx, filter, bson.M{"$set": bson.M{"deleted_at": now}})
+	if err != nil {
+		return err
+	}
+	// Kepemilikan sudah diperiksa ChatOwnerOnly; tidak cocok berarti chat sudah di tong sampah
+	if result.MatchedCount == 0 {
+		return ErrChatNotFound
+	}
+
+	_, err = config.MongoDB.Collection("messages").UpdateMany(ctx,
+		bson.M{"chat_id": chatID, "user_id": userID},
+		bson.M{"$set": bson.M{"deleted_at": now}})
+	if err != nil {
+		return err
+	}
+	unindexChat(chatID)
+
+	return nil
+}
+
+// ListTrash mengambil chat user yang ada di tong sampah per halaman
+func ListTrash(userID int, req PageRequest) (Page[TrashItem], error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	convos, err := PageConversations(ctx, bson.M{"user_id": userID, "deleted_at": bson.M{"$exists": true}}, req)
+	if err != nil {
+		return Page[TrashItem]{}, err
+	}
+
+	page := Page[TrashItem]{
+		Data:       make([]TrashItem, 0, len(convos.Data)),
+		NextCursor: convos.NextCursor,
+		PrevCursor: convos.PrevCursor,
+		HasMore:    convos.HasMore,
+	}
+	labels, err := userLabelMap(ctx, userID)
+	if err != nil {
+		return Page[TrashItem]{}, err
+	}
+
+	for _, conv := range convos.Data {
+		page.Data = append(page.Data, TrashItem{
+			ChatListItem: newChatListItem(conv, labels),
+			DeletedAt:    *conv.DeletedAt,
+			PurgeAt:      conv.DeletedAt.Add(trashRetention()),
+		})
+	}
+	return page, nil
+}
+
+// RestoreChat mengembalikan chat dari tong sampah selama masa simpan belum habis
+func RestoreChat(chatID string, userID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"chat_id":    chatID,
+		"user_id":    userID,
+		"deleted_at": bson.M{"$gt": time.Now().Add(-trashRetention())},
+	}
+	result, err := config.MongoDB.Collection("conversations").UpdateOne(ctx, filter, bson.M{"$unset": bson.M{"deleted_at": ""}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrChatNotInTrash
+	}
+
+	_, err = config.MongoDB.Collection("messages").UpdateMany(ctx,
+		bson.M{"chat_id": chatID, "user_id": userID},
+		bson.M{"$unset": bson.M{"deleted_at": ""}})
+	if err != nil {
+		return err
+	}
+
+	return reindexChat(ctx, chatID)
+}
+
+// PurgeChat menghapus permanen chat yang sudah ada di tong sampah tanpa menunggu masa simpan
+func PurgeChat(chatID string, userID int) (*PurgeReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var convo models.Conversation
+	err := config.MongoDB.Collection("conversations").FindOne(ctx, bson.M{
+		"chat_id":    chatID,
+		"user_id":    userID,
+		"deleted_at": bson.M{"$exists": true},
+	}).Decode(&convo)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrChatNotInTrash
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return purgeConversation(ctx, convo)
+}
+
+// StartTrashPurger menjalankan penghapusan permanen berkala untuk chat yang masa simpannya habis
+func StartTrashPurger(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	go func() {
+		for {
+			purgeExpiredChats()
+			time.Sleep(interval)
+		}
+	}()
+
+	config.Log.Infof("🗑️ Purger tong sampah berjalan setiap %s (masa simpan %d hari)", interval, config.TrashRetentionDays)
+}
+
+func purgeExpiredChats() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cutoff := time.Now().Add(-trashRetention())
+	cursor, err := config.MongoDB.Collection("conversations").Find(ctx, bson.M{"deleted_at": bson.M{"$lte": cutoff}})
+	if err != nil {
+		config.Log.Error("Purger gagal membaca tong sampah: ", err)
+		return
+	}
+	var convos []models.Conversation
+	if err := cursor.All(ctx, &convos); err != nil {
+		config.Log.Error("Purger gagal membaca tong sampah: ", err)
+		return
+	}
+
+	for _, convo := range convos {
+		if _, err := purgeConversation(ctx, convo); err != nil {
+			config.Log.Errorf("Purger gagal menghapus chat %s: %v", convo.ChatID, err)
+		}
+	}
+}
+
+// purgeConversation menghapus chat beserta semua jejaknya: pesan, voice_messages lama, tautan berbagi,
+// job suara dan ekspor (termasuk file exports/ di S3), respons Idempotency-Key tersimpan,
+// objek audio dan hasil Transcribe di S3, serta users.last_chat_id di PostgreSQL.
+// Dokumen percakapan dihapus terakhir supaya kegagalan di tengah dapat diulang.
+func purgeConversation(ctx context.Context, convo models.Conversation) (*PurgeReport, error) {
+	report := &PurgeReport{ChatID: convo.ChatID}
+	filter := bson.M{"chat_id": convo.ChatID}
+
+	audioURLs, err := chatAudioURLs(ctx, convo.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	for _, audioURL := range audioURLs {
+		objects, jobs := deleteAudioArtifacts(ctx, convo.ChatID, audioURL)
+		report.S3Objects += objects
+		report.TranscribeJobs += jobs
+	}
+
+	res, err := config.MongoDB.Collection("messages").DeleteMany(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	report.Messages = res.DeletedCount
+
+	res, err = config.MongoDB.Collection("voice_messages").DeleteMany(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	report.VoiceMessages = res.DeletedCount
+
+	report.ShareLinks, err = deleteShareLinks(ctx, convo.ChatID)
+	if err != nil {
+		return nil, err
+	}
+
+	report.VoiceJobs, err = deleteVoiceJobs(ctx, convo.ChatID)
+	if err != nil {
+		return nil, err
+	}
+
+	report.ExportJobs, report.ExportFiles, err = deleteExportJobs(ctx, convo)
+	if err != nil {
+		return nil, err
+	}
+
+	report.IdempotentKeys, err = deleteIdempotencyKeys(ctx, convo.ChatID)
+	if err != nil {
+		return nil, err
+	}
+
+	cleared, err := config.DB.ExecContext(ctx, `UPDATE users SET last_chat_id = NULL WHERE last_chat_id = $1`, convo.ChatID)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengosongkan last_chat_id: %v", err)
+	}
+	report.UsersCleared, _ = cleared.RowsAffected()
+
+	if _, err := config.MongoDB.Collection("conversations").DeleteOne(ctx, filter); err != nil {
+		return nil, err
+	}
+	unindexChat(convo.ChatID)
+
+	config.Log.Infof("🗑️ Chat %s dihapus permanen: %d pesan, %d voice_messages lama, %d objek S3, %d job Transcribe, %d tautan berbagi, %d job suara, %d job ekspor (%d file), %d Idempotency-Key, %d user last_chat_id dikosongkan",
+		report.ChatID, report.Messages, report.VoiceMessages, report.S3Objects, report.TranscribeJobs, report.ShareLinks,
+		report.VoiceJobs, report.ExportJobs, report.ExportFiles, report.IdempotentKeys, report.UsersCleared)
+	return report, nil
+}
+
+// chatAudioURLs mengumpulkan URL audio dari pesan terpadu dan voice_messages lama
+func chatAudioURLs(ctx context.Context, chatID string) ([]string, error) {
+	seen := map[string]bool{}
+	var urls []string
+	add := func(u string) {
+		if u != "" && !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+
+	cursor, err := config.MongoDB.Collection("messages").Find(ctx,
+		bson.M{"chat_id": chatID, "audio.url": bson.M{"$exists": true}},
+		options.Find().SetProjection(bson.M{"audio": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var msgs []models.Message
+	if err := cursor.All(ctx, &msgs); err != nil {
+		return nil, err
+	}
+	for _, m := range msgs {
+		add(audioURL(m))
+	}
+
+	voices, err := findLegacyVoiceMessages(ctx, bson.M{"chat_id": chatID})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range voices {
+		add(v.AudioURL)
+	}
+	return urls, nil
+}
+
+// s3KeyFromURL mengubah URL objek di bucket aplikasi menjadi key; URL lain diabaikan
+func s3KeyFromURL(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || !strings.HasPrefix(u.Host, config.AWSBucketName+".s3.") {
+		return "", false
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	return key, key != ""
+}
+
+// deleteAudioArtifacts menghapus objek audio dan, untuk audio pengguna, hasil Transcribe-nya.
+// Objek yang masih dipakai chat lain tidak disentuh. Kegagalan hanya dicatat agar purge tetap jalan.
+func deleteAudioArtifacts(ctx context.Context, chatID, audioURL string) (objects, jobs int) {
+	local, isLocal := localAudioFromURL(audioURL)
+	key, ok := s3KeyFromURL(audioURL)
+	if !isLocal && (!ok || s3Client == nil) {
+		return 0, 0
+	}
+
+	shared, err := config.MongoDB.Collection("messages").CountDocuments(ctx,
+		bson.M{"audio.url": audioURL, "chat_id": bson.M{"$ne": chatID}})
+	if err != nil || shared > 0 {
+		return 0, 0
+	}
+
+	if isLocal {
+		if err := os.Remove(local); err != nil && !errors.Is(err, os.ErrNotExist) {
+			config.Log.Warnf("Gagal menghapus audio lokal %s: %v", local, err)
+			return 0, 0
+		}
+		return 1, 0
+	}
+
+	keys := []string{key}
+	var jobName string
+	if strings.HasPrefix(key, "user/") {
+		jobName = TranscribeJobName(key)
+		keys = append(keys, TranscriptOutputKey(jobName))
+		// Data lama: hasil Transcribe ditulis di root bucket sebagai transcribe-job-<unix>.json
+		if ts := strings.TrimPrefix(jobName, "user-audio-"); ts != jobName && len(ts) <= 10 {
+			keys = append(keys, "transcribe-job-"+ts+".json")
+		}
+	}
+
+	for _, k := range keys {
+		_, err := s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(config.AWSBucketName),
+			Key:    aws.String(k),
+		})
+		if err != nil {
+			config.Log.Warnf("Gagal menghapus objek S3 %s: %v", k, err)
+			continue
+		}
+		objects++
+	}
+
+	if jobName != "" && transcribeClient != nil {
+		_, err := transcribeClient.DeleteTranscriptionJob(ctx, &transcribe.DeleteTranscriptionJobInput{
+			TranscriptionJobName: aws.String(jobName),
+		})
+		if err == nil {
+			jobs++
+		}
+	}
+
+	return objects, jobs
+}
+
+// reindexChat memasukkan kembali pesan chat yang dipulihkan ke indeks pencarian di memori.
+// Pesan yang sudah digantikan hasil edit tidak diindeks, sama seperti pencarian Mongo.
+func reindexChat(ctx context.Context, chatID string) error {
+	if memoryIndex == nil {
+		return nil
+	}
+
+	filter := bson.M{"chat_id": chatID, "superseded_by": bson.M{"$exists": false}}
+	cursor, err := config.MongoDB.Collection("messages").Find(ctx, filter,
+		options.Find().SetProjection(bson.M{"encrypted_original": 0, "search_text": 0}))
+	if err != nil {
+		return err
+	}
+	var msgs []models.Message
+	if err := cursor.All(ctx, &msgs); err != nil {
+		return err
+	}
+
+	batch := make([]*models.Message, len(msgs))
+	for i := range msgs {
+		batch[i] = &msgs[i]
+	}
+	indexMessages(batch)
+	return nil
+}
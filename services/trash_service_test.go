@@ -0,0 +1,99 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+)
+
+func TestDeleteChatReportsNotFound(t *testing.T) {
+	useTestMongo(t)
+	ctx := context.Background()
+
+	msg := models.Message{Sender: "user", Content: "halo", Timestamp: time.Now()}
+	if err := SaveMessages(ctx, "chat-hapus", 1, "a", &msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteChat("chat-hapus", 1); err != nil {
+		t.Fatalf("hapus pertama gagal: %v", err)
+	}
+	if err := DeleteChat("chat-hapus", 1); !errors.Is(err, ErrChatNotFound) {
+		t.Errorf("hapus kedua = %v, ingin ErrChatNotFound", err)
+	}
+}
+
+func TestPurgeCascadeRemovesChatJobsAndKeys(t *testing.T) {
+	useTestMongo(t)
+	ctx := context.Background()
+	db := config.MongoDB
+	convo := models.Conversation{ChatID: "chat-purge", UserID: 1}
+	owner, other := 1, 2
+
+	spool := filepath.Join(t.TempDir(), "input.webm")
+	if err := os.WriteFile(spool, []byte("audio"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	voiceJob := models.VoiceJob{ID: primitive.NewObjectID(), ChatID: convo.ChatID, ConvertedPath: spool}
+	if err := storeVoiceInput(voiceJob.ID, spool); err != nil {
+		t.Fatal(err)
+	}
+	insert := func(coll string, docs ...interface{}) {
+		if _, err := db.Collection(coll).InsertMany(ctx, docs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	insert("voice_jobs",
+		voiceJob,
+		models.VoiceJob{ID: primitive.NewObjectID(), ChatID: "chat-lain"})
+	insert("export_jobs",
+		models.ExportJob{ID: primitive.NewObjectID(), RequestedBy: 1, ChatIDs: []string{convo.ChatID}},
+		models.ExportJob{ID: primitive.NewObjectID(), RequestedBy: 1},                              // semua chat milik user 1
+		models.ExportJob{ID: primitive.NewObjectID(), RequestedBy: 9, Admin: true, UserID: &owner}, // ekspor massal user 1
+		models.ExportJob{ID: primitive.NewObjectID(), RequestedBy: 9, Admin: true},                 // semua user, tidak terkait chat
+		models.ExportJob{ID: primitive.NewObjectID(), RequestedBy: 9, Admin: true, UserID: &other}, // user lain
+		models.ExportJob{ID: primitive.NewObjectID(), RequestedBy: 2, ChatIDs: []string{"chat-lain"}})
+	insert("idempotency_keys",
+		models.IdempotencyRecord{ID: "chat:1:a", UserID: 1, ChatID: convo.ChatID, ExpiresAt: time.Now().Add(time.Hour)},
+		models.IdempotencyRecord{ID: "chat:1:b", UserID: 1, ChatID: "chat-lain", ExpiresAt: time.Now().Add(time.Hour)})
+
+	voiceJobs, err := deleteVoiceJobs(ctx, convo.ChatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exportJobs, _, err := deleteExportJobs(ctx, convo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := deleteIdempotencyKeys(ctx, convo.ChatID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if voiceJobs != 1 || exportJobs != 3 || keys != 1 {
+		t.Errorf("terhapus: %d job suara, %d job ekspor, %d Idempotency-Key; ingin 1, 3, 1", voiceJobs, exportJobs, keys)
+	}
+	if _, err := os.Stat(spool); !os.IsNotExist(err) {
+		t.Errorf("berkas spool job suara masih ada: %v", err)
+	}
+	if _, err := readVoiceInput(voiceJob.ID); !errors.Is(err, gridfs.ErrFileNotFound) {
+		t.Errorf("rekaman asli job suara masih ada: %v", err)
+	}
+	for coll, want := range map[string]int64{"voice_jobs": 1, "export_jobs": 3, "idempotency_keys": 1} {
+		n, err := db.Collection(coll).CountDocuments(ctx, bson.M{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Errorf("%s tersisa %d dokumen, ingin %d", coll, n, want)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"backend-go/config"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ttsCacheVersion diganti bila format audio atau kunci cache berubah
+const ttsCacheVersion = "v1"
+
+var ErrPrewarmRunning = errors.New("pre-warm cache TTS sedang berjalan")
+
+// TTSAudio adalah audio balasan bot yang sudah tersimpan di S3 atau AUDIO_LOCAL_DIR
+type TTSAudio struct {
+	URL      string
+	Provider string
+	Format   string // mp3, ogg, atau wav
+	Cached   bool   // true bila audio dipakai ulang dari cache
+	Audio    []byte // hanya diisi SynthesizeAudio
+}
+
+// PrewarmReport adalah hasil satu kali pre-warm katalog balasan
+type PrewarmReport struct {
+	Texts       int       `json:"texts"`
+	Synthesized int       `json:"synthesized"`
+	AlreadyWarm int       `json:"already_warm"`
+	Failed      int       `json:"failed"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+}
+
+// ttsCacheStats dihitung sejak proses mulai dan ditampilkan di /admin/metrics
+var ttsCacheStats struct {
+	hits, misses, errors, prewarmed atomic.Int64
+}
+
+var prewarmRunning atomic.Bool
+
+// SynthesizeToS3 mengembalikan URL audio balasan. Audio disimpan di S3 (atau
+// AUDIO_LOCAL_DIR bila AWS tidak dikonfigurasi) dengan kunci
+// hash dari teks yang sudah dinormalkan, pengaturan suara, dan provider, sehingga
+// balasan yang sama (mis. FAQ statis) cukup disintesis sekali.
+func SynthesizeToS3(ctx context.Context, req TTSRequest) (*TTSAudio, error) {
+	return synthesizeCached(ctx, req, false)
+}
+
+// SynthesizeAudio sama dengan SynthesizeToS3 tetapi juga mengembalikan isi audio,
+// untuk diputar langsung (mis. lewat WebSocket)
+func SynthesizeAudio(ctx context.Context, req TTSRequest) (*TTSAudio, error) {
+	return synthesizeCached(ctx, req, true)
+}
+
+func synthesizeCached(ctx context.Context, req TTSRequest, withAudio bool) (*TTSAudio, error) {
+	req = prepareTTSRequest(ctx, req)
+	chain := ttsChainFor(req)
+
+	// Cache hanya dicek untuk provider utama; audio cadangan disimpan dengan kunci
+	// provider cadangan agar tidak menggantikan audio provider utama
+	if config.TTSCacheEnabled {
+		key := ttsCacheKey(req.forProvider(chain[0]))
+		found, err := audioObjectExists(ctx, key)
+		switch {
+		case err != nil:
+			ttsCacheStats.errors.Add(1)
+			config.Log.Warnf("Gagal memeriksa cache TTS %s: %v", key, err)
+		case found:
+			ttsCacheStats.hits.Add(1)
+			audio := &TTSAudio{URL: audioObjectURL(key), Provider: chain[0], Format: req.Format, Cached: true}
+			if !withAudio {
+				return audio, nil
+			}
+			if audio.Audio, err = readAudioObject(ctx, key); err == nil {
+				return audio, nil
+			}
+			ttsCacheStats.errors.Add(1)
+			config.Log.Warnf("Gagal membaca cache TTS %s: %v", key, err)
+		default:
+			ttsCacheStats.misses.Add(1)
+		}
+	}
+
+	result, err := synthesizeChain(ctx, req, chain)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengonversi teks menjadi suara: %v", err)
+	}
+
+	data := result.Audio
+	if req.Format != AudioFormatMP3 {
+		opts, err := transcodeOptionsFor(req.Format)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = transcoder.Transcode(ctx, data, opts); err != nil {
+			return nil, fmt.Errorf("gagal mengubah audio balasan ke %s: %v", req.Format, err)
+		}
+	}
+
+	key := ttsCacheKey(req.forProvider(result.Provider))
+	url, err := StoreBotVoice(strings.TrimPrefix(key, "bot/"), data)
+	if err != nil {
+		return nil, err
+	}
+	audio := &TTSAudio{URL: url, Provider: result.Provider, Format: req.Format}
+	if withAudio {
+		audio.Audio = data
+	}
+	return audio, nil
+}
+
+// ttsCacheKey menghasilkan key S3 bot/cache/<sha256>.<format> dari permintaan yang sudah
+// disiapkan untuk satu provider. Format cukup dibedakan lewat ekstensi sehingga kunci
+// mp3 yang sudah ada tetap berlaku.
+func ttsCacheKey(req TTSRequest) string {
+	v := req.Voice
+	h := sha256.New()
+	for _, part := range []string{
+		ttsCacheVersion, v.Provider, req.Language, v.Gender, v.VoiceName,
+		strconv.FormatFloat(v.SpeakingRate, 'f', -1, 64),
+		strconv.FormatFloat(v.Pitch, 'f', -1, 64),
+		req.SSML, req.Text,
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return "bot/cache/" + hex.EncodeToString(h.Sum(nil)) + "." + req.Format
+}
+
+// TTSCacheMetrics mengembalikan statistik cache TTS untuk dashboard admin
+func TTSCacheMetrics() map[string]int64 {
+	hits, misses := ttsCacheStats.hits.Load(), ttsCacheStats.misses.Load()
+	var hitRate int64
+	if hits+misses > 0 {
+		hitRate = hits * 100 / (hits + misses)
+	}
+	return map[string]int64{
+		"tts_cache_hits":         hits,
+		"tts_cache_misses":       misses,
+		"tts_cache_errors":       ttsCacheStats.errors.Load(),
+		"tts_cache_hit_rate_pct": hitRate,
+		"tts_cache_prewarmed":    ttsCacheStats.prewarmed.Load(),
+	}
+}
+
+// StartTTSPrewarm menjalankan pre-warm di latar belakang; hanya satu yang boleh berjalan
+func StartTTSPrewarm() error {
+	if !prewarmRunning.CompareAndSwap(false, true) {
+		return ErrPrewarmRunning
+	}
+	go func() {
+		defer prewarmRunning.Store(false)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		defer cancel()
+
+		report, err := PrewarmTTSCache(ctx)
+		if err != nil {
+			config.Log.Error("Pre-warm cache TTS gagal: ", err)
+			return
+		}
+		config.Log.Infof("🔥 Pre-warm cache TTS: %d teks, %d disintesis, %d sudah ada, %d gagal",
+			report.Texts, report.Synthesized, report.AlreadyWarm, report.Failed)
+	}()
+	return nil
+}
+
+// PrewarmTTSCache mensintesis katalog balasan dengan suara default deployment agar
+// giliran suara pertama untuk balasan tersebut langsung mendapat cache hit
+func PrewarmTTSCache(ctx context.Context) (*PrewarmReport, error) {
+	report := &PrewarmReport{StartedAt: time.Now()}
+
+	texts, err := ttsResponseCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Texts = len(texts)
+
+	for _, text := range texts {
+		if ctx.Err() != nil {
+			break
+		}
+		audio, err := SynthesizeToS3(ctx, TTSRequest{Text: text})
+		switch {
+		case err != nil:
+			report.Failed++
+			config.Log.Warn("Pre-warm TTS gagal: ", err)
+		case audio.Cached:
+			report.AlreadyWarm++
+		default:
+			report.Synthesized++
+			ttsCacheStats.prewarmed.Add(1)
+		}
+	}
+
+	report.FinishedAt = time.Now()
+	return report, ctx.Err()
+}
+
+// ttsResponseCatalog menggabungkan teks dari TTS_PREWARM_FILE (satu balasan per baris,
+// mis. hasil ekspor template respons NLP) dengan balasan bot yang paling sering muncul
+// dalam 30 hari terakhir. Balasan yang jarang muncul biasanya bersifat pribadi
+// (saldo, nama) sehingga hanya balasan dengan minimal TTS_PREWARM_MIN_COUNT kemunculan
+// yang diambil.
+func ttsResponseCatalog(ctx context.Context) ([]string, error) {
+	var texts []string
+	seen := map[string]bool{}
+	add := func(text string) {
+		text = strings.Join(strings.Fields(text), " ")
+		if text != "" && !seen[text] {
+			seen[text] = true
+			texts = append(texts, text)
+		}
+	}
+
+	if config.TTSPrewarmFile != "" {
+		f, err := os.Open(config.TTSPrewarmFile)
+		if err != nil {
+			return nil, fmt.Errorf("gagal membuka katalog pre-warm: %v", err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			add(scanner.Text())
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("gagal membaca katalog pre-warm: %v", err)
+		}
+	}
+
+	if config.TTSPrewarmTop > 0 {
+		frequent, err := frequentBotReplies(ctx, config.TTSPrewarmTop, config.TTSPrewarmMinCount)
+		if err != nil {
+			return nil, err
+		}
+		for _, text := range frequent {
+			add(text)
+		}
+	}
+	return texts, nil
+}
+
+func frequentBotReplies(ctx context.Context, limit, minCount int) ([]string, error) {
+	match := bson.M{
+		"sender":    "bot",
+		"timestamp": bson.M{"$gte": time.Now().AddDate(0, 0, -30)},
+	}
+	for k, v := range finalMessagesFilter {
+		match[k] = v
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$content", "$transcript"}}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$match", Value: bson.M{"_id": bson.M{"$ne": nil}, "count": bson.M{"$gte": minCount}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	cursor, err := config.MongoDB.Collection("messages").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []struct {
+		Text string `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	texts := make([]string, 0, len(rows))
+	for _, row := range rows {
+		texts = append(texts, row.Text)
+	}
+	return texts, nil
+}
@@ -0,0 +1,523 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	texttospeech "cloud.google.com/go/texttospeech/apiv1"
+	texttospeechpb "cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/polly"
+	pollyTypes "github.com/aws/aws-sdk-go-v2/service/polly/types"
+	"google.golang.org/api/option"
+)
+
+var ErrUnknownTTSProvider = errors.New("provider TTS tidak dikenal")
+
+// ttsVoiceListTTL adalah lama daftar suara provider disimpan di memori
+const ttsVoiceListTTL = time.Hour
+
+// TTSRequest adalah teks yang akan disintesis. Voice.Provider kosong berarti memakai
+// urutan provider deployment (TTS_PROVIDER lalu TTS_FALLBACK_PROVIDER).
+type TTSRequest struct {
+	Text     string
+	SSML     string               // Diisi Synthesize; provider tanpa dukungan SSML memakai Text
+	Language string               // kode BCP-47, mis. id-ID
+	Voice    models.VoiceSettings // Voice.Provider dicoba lebih dulu
+	Format   string               // format audio hasil akhir (mp3, ogg, wav); provider selalu menghasilkan mp3
+}
+
+// TTSResult adalah audio mp3 beserta provider yang menghasilkannya
+type TTSResult struct {
+	Audio    []byte
+	Provider string
+}
+
+// TTSVoice adalah satu suara yang ditawarkan provider
+type TTSVoice struct {
+	Name     string `json:"name"`
+	Language string `json:"language"`
+	Gender   string `json:"gender,omitempty"`
+}
+
+// TTSProvider adalah mesin text-to-speech yang menghasilkan mp3
+type TTSProvider interface {
+	Name() string
+	Synthesize(ctx context.Context, req TTSRequest) ([]byte, error)
+	Voices(ctx context.Context) ([]TTSVoice, error)
+}
+
+// ttsProviders memetakan nama di konfigurasi ke konstruktor provider
+var ttsProviders = map[string]func() (TTSProvider, error){
+	"google": newGoogleTTS,
+	"polly":  newPollyTTS,
+	"local":  newLocalTTS,
+	"fake":   func() (TTSProvider, error) { return fakeTTS{}, nil },
+}
+
+// ttsCache menyimpan provider yang sudah dibuat; provider per permintaan dibuat saat pertama dipakai
+var ttsCache = struct {
+	sync.Mutex
+	m map[string]TTSProvider
+}{m: map[string]TTSProvider{}}
+
+// ttsVoiceCache menyimpan daftar suara per provider agar validasi preferensi tidak
+// memanggil API provider di setiap permintaan
+var ttsVoiceCache = struct {
+	sync.Mutex
+	m map[string]ttsVoiceList
+}{m: map[string]ttsVoiceList{}}
+
+type ttsVoiceList struct {
+	voices    []TTSVoice
+	fetchedAt time.Time
+}
+
+// InitTTS memastikan provider deployment dapat dibuat sejak server mulai
+func InitTTS() error {
+	if config.TTSProvider == "" {
+		return fmt.Errorf("%w: TTS_PROVIDER kosong", ErrUnknownTTSProvider)
+	}
+	for _, name := range ttsDefaultChain() {
+		if _, err := getTTSProvider(name); err != nil {
+			return err
+		}
+	}
+	config.Log.Info("🔊 Provider TTS: ", strings.Join(ttsDefaultChain(), " → "))
+	return nil
+}
+
+// ValidTTSProvider memeriksa nama provider dari permintaan klien
+func ValidTTSProvider(name string) bool {
+	_, ok := ttsProviders[name]
+	return name == "" || ok
+}
+
+func ttsDefaultChain() []string {
+	chain := []string{config.TTSProvider}
+	if config.TTSFallbackProvider != "" && config.TTSFallbackProvider != config.TTSProvider {
+		chain = append(chain, config.TTSFallbackProvider)
+	}
+	return chain
+}
+
+// ListTTSVoices mengembalikan daftar suara provider; provider kosong berarti provider deployment
+func ListTTSVoices(ctx context.Context, name string) ([]TTSVoice, error) {
+	if name == "" {
+		name = config.TTSProvider
+	}
+	p, err := getTTSProvider(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ttsVoiceCache.Lock()
+	cached, ok := ttsVoiceCache.m[name]
+	ttsVoiceCache.Unlock()
+	if ok && time.Since(cached.fetchedAt) < ttsVoiceListTTL {
+		return cached.voices, nil
+	}
+
+	voices, err := p.Voices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ttsVoiceCache.Lock()
+	ttsVoiceCache.m[name] = ttsVoiceList{voices: voices, fetchedAt: time.Now()}
+	ttsVoiceCache.Unlock()
+	return voices, nil
+}
+
+func getTTSProvider(name string) (TTSProvider, error) {
+	ttsCache.Lock()
+	defer ttsCache.Unlock()
+
+	if p, ok := ttsCache.m[name]; ok {
+		return p, nil
+	}
+	newProvider, ok := ttsProviders[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTTSProvider, name)
+	}
+	p, err := newProvider()
+	if err != nil {
+		return nil, fmt.Errorf("gagal menginisialisasi TTS %s: %v", name, err)
+	}
+	ttsCache.m[name] = p
+	return p, nil
+}
+
+// Synthesize mencoba provider permintaan lalu provider deployment secara berurutan
+// sampai salah satunya berhasil
+func Synthesize(ctx context.Context, req TTSRequest) (*TTSResult, error) {
+	req = prepareTTSRequest(ctx, req)
+	return synthesizeChain(ctx, req, ttsChainFor(req))
+}
+
+// prepareTTSRequest merapikan spasi lalu menyiapkan nominal, nomor rekening, tanggal,
+// dan singkatan sekali untuk semua provider
+func prepareTTSRequest(ctx context.Context, req TTSRequest) TTSRequest {
+	if req.Language == "" {
+		req.Language = "id-ID"
+	}
+	if req.Format == "" {
+		req.Format = AudioFormatMP3
+	}
+	if req.SSML == "" {
+		req.Text = strings.Join(strings.Fields(req.Text), " ")
+		if config.TTSSSMLEnabled {
+			speech := BuildSpeechText(req.Text, activeLexicon(ctx))
+			req.SSML, req.Text = speech.SSML, speech.Plain
+		}
+	}
+	return req
+}
+
+// ttsChainFor mengembalikan urutan provider unik: pilihan permintaan lalu provider deployment
+func ttsChainFor(req TTSRequest) []string {
+	var chain []string
+	seen := map[string]bool{}
+	for _, name := range append([]string{req.Voice.Provider}, ttsDefaultChain()...) {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		chain = append(chain, name)
+	}
+	return chain
+}
+
+// forProvider menyesuaikan permintaan untuk satu provider. Nama suara hanya berlaku
+// untuk provider pemiliknya; provider cadangan memakai gender, kecepatan, dan pitch saja.
+func (req TTSRequest) forProvider(name string) TTSRequest {
+	if name != req.Voice.Provider {
+		req.Voice.VoiceName = ""
+	}
+	req.Voice.Provider = name
+	return req
+}
+
+func synthesizeChain(ctx context.Context, req TTSRequest, chain []string) (*TTSResult, error) {
+	var errs []error
+	for _, name := range chain {
+		p, err := getTTSProvider(name)
+		if err == nil {
+			pctx, cancel := context.WithTimeout(ctx, config.TTSTimeout)
+			var audio []byte
+			audio, err = p.Synthesize(pctx, req.forProvider(name))
+			cancel()
+			if err == nil && len(audio) == 0 {
+				err = errors.New("TTS menghasilkan audio kosong")
+			}
+			if err == nil {
+				return &TTSResult{Audio: audio, Provider: name}, nil
+			}
+		}
+
+		config.Log.Warnf("TTS %s gagal: %v", name, err)
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// escapeSSML meloloskan teks biasa agar aman disisipkan ke dalam SSML
+func escapeSSML(text string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
+
+// ==== Google Cloud Text-to-Speech ====
+
+// googleTTS memakai satu klien gRPC seumur proses dengan pool koneksi TTS_GRPC_POOL
+type googleTTS struct {
+	client *texttospeech.Client
+}
+
+func newGoogleTTS() (TTSProvider, error) {
+	client, err := texttospeech.NewClient(context.Background(), option.WithGRPCConnectionPool(config.TTSGRPCPool))
+	if err != nil {
+		return nil, err
+	}
+	return googleTTS{client: client}, nil
+}
+
+func (googleTTS) Name() string { return "google" }
+
+// googleGenders memetakan gender preferensi ke enum Google; default tetap MALE
+var googleGenders = map[string]texttospeechpb.SsmlVoiceGender{
+	"":                        texttospeechpb.SsmlVoiceGender_MALE,
+	models.VoiceGenderMale:    texttospeechpb.SsmlVoiceGender_MALE,
+	models.VoiceGenderFemale:  texttospeechpb.SsmlVoiceGender_FEMALE,
+	models.VoiceGenderNeutral: texttospeechpb.SsmlVoiceGender_NEUTRAL,
+}
+
+func (p googleTTS) Synthesize(ctx context.Context, req TTSRequest) ([]byte, error) {
+	input := &texttospeechpb.SynthesisInput{InputSource: &texttospeechpb.SynthesisInput_Text{Text: req.Text}}
+	if req.SSML != "" {
+		input.InputSource = &texttospeechpb.SynthesisInput_Ssml{Ssml: req.SSML}
+	}
+
+	resp, err := p.client.SynthesizeSpeech(ctx, &texttospeechpb.SynthesizeSpeechRequest{
+		Input: input,
+		Voice: &texttospeechpb.VoiceSelectionParams{
+			LanguageCode: req.Language,
+			Name:         req.Voice.VoiceName,
+			SsmlGender:   googleGenders[req.Voice.Gender],
+		},
+		AudioConfig: &texttospeechpb.AudioConfig{
+			AudioEncoding: texttospeechpb.AudioEncoding_MP3,
+			SpeakingRate:  req.Voice.SpeakingRate,
+			Pitch:         req.Voice.Pitch,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gagal sintesis dengan Google: %v", err)
+	}
+	return resp.AudioContent, nil
+}
+
+// Voices mengembalikan suara Google untuk bahasa Indonesia
+func (p googleTTS) Voices(ctx context.Context) ([]TTSVoice, error) {
+	resp, err := p.client.ListVoices(ctx, &texttospeechpb.ListVoicesRequest{LanguageCode: "id-ID"})
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil daftar suara Google: %v", err)
+	}
+	voices := make([]TTSVoice, 0, len(resp.Voices))
+	for _, v := range resp.Voices {
+		voice := TTSVoice{Name: v.Name, Gender: strings.ToLower(v.SsmlGender.String())}
+		if len(v.LanguageCodes) > 0 {
+			voice.Language = v.LanguageCodes[0]
+		}
+		voices = append(voices, voice)
+	}
+	return voices, nil
+}
+
+// ==== Amazon Polly ====
+
+// pollyTTS memakai suara dari TTS_POLLY_VOICE. Polly belum memiliki suara Bahasa
+// Indonesia, sehingga provider ini paling cocok sebagai cadangan dan suaranya harus
+// dipilih sendiri oleh operator; tidak ada nilai bawaan.
+type pollyTTS struct {
+	client *polly.Client
+}
+
+func newPollyTTS() (TTSProvider, error) {
+	if !config.AWSEnabled() {
+		return nil, errors.New("AWS belum dikonfigurasi (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_BUCKET_NAME)")
+	}
+	if config.TTSPollyVoice == "" {
+		return nil, errors.New("TTS_POLLY_VOICE belum diatur; Polly tidak memiliki suara Bahasa Indonesia sehingga suaranya harus dipilih")
+	}
+	return pollyTTS{client: polly.NewFromConfig(config.AWSConfig)}, nil
+}
+
+func (pollyTTS) Name() string { return "polly" }
+
+func (p pollyTTS) Synthesize(ctx context.Context, req TTSRequest) ([]byte, error) {
+	voiceID, err := p.voiceFor(ctx, req.Voice)
+	if err != nil {
+		return nil, err
+	}
+
+	// Kecepatan dan pitch di Polly hanya bisa lewat SSML <prosody>
+	text, textType := req.Text, pollyTypes.TextTypeText
+	if req.SSML != "" {
+		text, textType = req.SSML, pollyTypes.TextTypeSsml
+	}
+	if prosody := pollyProsody(req.Voice); prosody != "" {
+		body := escapeSSML(req.Text)
+		if req.SSML != "" {
+			body = ssmlBody(req.SSML)
+		}
+		text = "<speak><prosody" + prosody + ">" + body + "</prosody></speak>"
+		textType = pollyTypes.TextTypeSsml
+	}
+
+	out, err := p.client.SynthesizeSpeech(ctx, &polly.SynthesizeSpeechInput{
+		Text:         aws.String(text),
+		TextType:     textType,
+		OutputFormat: pollyTypes.OutputFormatMp3,
+		VoiceId:      pollyTypes.VoiceId(voiceID),
+		Engine:       pollyTypes.Engine(config.TTSPollyEngine),
+		SampleRate:   aws.String("22050"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gagal sintesis dengan Polly: %v", err)
+	}
+	defer out.AudioStream.Close()
+	return io.ReadAll(out.AudioStream)
+}
+
+// Voices mengembalikan semua suara Polly untuk engine yang dikonfigurasi
+func (p pollyTTS) Voices(ctx context.Context) ([]TTSVoice, error) {
+	var voices []TTSVoice
+	input := &polly.DescribeVoicesInput{Engine: pollyTypes.Engine(config.TTSPollyEngine)}
+	for {
+		out, err := p.client.DescribeVoices(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("gagal mengambil daftar suara Polly: %v", err)
+		}
+		for _, v := range out.Voices {
+			voices = append(voices, TTSVoice{
+				Name:     string(v.Id),
+				Language: string(v.LanguageCode),
+				Gender:   strings.ToLower(string(v.Gender)),
+			})
+		}
+		if out.NextToken == nil {
+			return voices, nil
+		}
+		input.NextToken = out.NextToken
+	}
+}
+
+// voiceFor memilih suara Polly: nama suara preferensi, lalu TTS_POLLY_VOICE, lalu suara
+// lain berbahasa sama dengan gender yang diminta
+func (p pollyTTS) voiceFor(ctx context.Context, s models.VoiceSettings) (string, error) {
+	if s.VoiceName != "" {
+		return s.VoiceName, nil
+	}
+	if s.Gender == "" || s.Gender == models.VoiceGenderNeutral {
+		return config.TTSPollyVoice, nil
+	}
+
+	voices, err := ListTTSVoices(ctx, "polly")
+	if err != nil {
+		return "", err
+	}
+	var language string
+	for _, v := range voices {
+		if v.Name == config.TTSPollyVoice {
+			if v.Gender == s.Gender {
+				return v.Name, nil
+			}
+			language = v.Language
+		}
+	}
+	for _, v := range voices {
+		if v.Language == language && v.Gender == s.Gender {
+			return v.Name, nil
+		}
+	}
+	return config.TTSPollyVoice, nil
+}
+
+// pollyProsody menerjemahkan kecepatan dan pitch (semitone) ke atribut <prosody>.
+// Engine neural tidak mendukung pitch sehingga pitch hanya dikirim untuk standard.
+func pollyProsody(s models.VoiceSettings) string {
+	var attrs string
+	if s.SpeakingRate != 0 && s.SpeakingRate != 1 {
+		attrs += fmt.Sprintf(` rate="%d%%"`, int(math.Round(s.SpeakingRate*100)))
+	}
+	if s.Pitch != 0 && config.TTSPollyEngine == string(pollyTypes.EngineStandard) {
+		attrs += fmt.Sprintf(` pitch="%+d%%"`, int(math.Round((math.Pow(2, s.Pitch/12)-1)*100)))
+	}
+	return attrs
+}
+
+// ==== Mesin lokal (espeak-ng / Piper) ====
+
+// localTTS menjalankan TTS_LOCAL_COMMAND dengan teks biasa (tanpa SSML) di stdin dan WAV di stdout,
+// lalu mengubahnya ke mp3 lewat transcoder. Contoh: "espeak-ng -v id --stdout" atau
+// "piper --model id_ID-news_tts-medium.onnx --output_file -". Suara ditentukan oleh
+// perintah itu sendiri; dari preferensi hanya kecepatan dan pitch yang diterapkan,
+// lewat filter ffmpeg.
+type localTTS struct {
+	args []string
+}
+
+func newLocalTTS() (TTSProvider, error) {
+	args := strings.Fields(config.TTSLocalCommand)
+	if len(args) == 0 {
+		return nil, errors.New("TTS_LOCAL_COMMAND belum diatur")
+	}
+	if _, err := exec.LookPath(args[0]); err != nil {
+		return nil, fmt.Errorf("perintah TTS lokal tidak ditemukan: %v", err)
+	}
+	return localTTS{args: args}, nil
+}
+
+func (localTTS) Name() string { return "local" }
+
+func (p localTTS) Synthesize(ctx context.Context, req TTSRequest) ([]byte, error) {
+	var wav, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.args[0], p.args[1:]...)
+	cmd.Stdin = strings.NewReader(req.Text)
+	cmd.Stdout = &wav
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("TTS lokal gagal: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	mp3, err := transcoder.Transcode(ctx, wav.Bytes(), TranscodeOptions{
+		Format: "mp3",
+		Filter: localVoiceFilter(req.Voice),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("konversi audio TTS lokal gagal: %v", err)
+	}
+	return mp3, nil
+}
+
+// Voices kosong karena suara mesin lokal ditentukan oleh TTS_LOCAL_COMMAND
+func (localTTS) Voices(ctx context.Context) ([]TTSVoice, error) {
+	return nil, nil
+}
+
+// localVoiceFilter menyusun filter ffmpeg: pitch dengan rubberband (ffmpeg harus
+// dibangun dengan librubberband), kecepatan dengan atempo (min. 0.5 per filter)
+func localVoiceFilter(s models.VoiceSettings) string {
+	var filters []string
+	if s.Pitch != 0 {
+		filters = append(filters, "rubberband=pitch="+strconv.FormatFloat(math.Pow(2, s.Pitch/12), 'f', 4, 64))
+	}
+	if s.SpeakingRate != 0 && s.SpeakingRate != 1 {
+		rate := s.SpeakingRate
+		for rate < 0.5 {
+			filters = append(filters, "atempo=0.5")
+			rate /= 0.5
+		}
+		filters = append(filters, "atempo="+strconv.FormatFloat(rate, 'f', 4, 64))
+	}
+	return strings.Join(filters, ",")
+}
+
+// ==== Fake untuk pengembangan dan pengujian ====
+
+// fakeTTS mengembalikan byte deterministik dari teks tanpa memanggil layanan apa pun
+type fakeTTS struct{}
+
+func (fakeTTS) Name() string { return "fake" }
+
+func (fakeTTS) Synthesize(ctx context.Context, req TTSRequest) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%+v\n%s", req.Language, req.Voice, req.Text)))
+	return append([]byte("FAKE-MP3:"), sum[:]...), nil
+}
+
+func (fakeTTS) Voices(ctx context.Context) ([]TTSVoice, error) {
+	return []TTSVoice{
+		{Name: "fake-male", Language: "id-ID", Gender: models.VoiceGenderMale},
+		{Name: "fake-female", Language: "id-ID", Gender: models.VoiceGenderFemale},
+	}, nil
+}
@@ -0,0 +1,797 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	minVoiceUploadBytes = 1000
+	// voiceSpoolMaxAge adalah umur maksimum file sementara job yang gagal dan tidak diulang
+	voiceSpoolMaxAge = 24 * time.Hour
+	// voiceInputBucket menyimpan rekaman asli job di GridFS dengan _id = ID job
+	voiceInputBucket  = "voice_inputs"
+	voiceInputTimeout = 30 * time.Second
+)
+
+var (
+	ErrVoiceJobNotFound     = errors.New("job suara tidak ditemukan")
+	errVoiceJobLeaseLost    = errors.New("klaim job suara sudah diambil worker lain")
+	ErrVoiceJobNotRetryable = errors.New("job suara tidak gagal atau tidak dapat diulang")
+	ErrAudioTooSmall        = errors.New("File audio terlalu kecil atau corrupt")
+)
+
+// voiceStageFatal menandai kegagalan yang tidak akan berhasil meskipun diulang
+type voiceStageFatal struct {
+	err     error
+	reasons []string
+}
+
+func (e *voiceStageFatal) Error() string { return e.err.Error() }
+
+// voiceJobWake membangunkan worker saat ada job baru atau job yang diulang
+var voiceJobWake = newJobWaker()
+
+// voiceJobSubs adalah pelanggan pembaruan job di instance ini (SSE)
+var voiceJobSubs = struct {
+	sync.Mutex
+	m map[primitive.ObjectID]map[chan models.VoiceJob]struct{}
+}{m: map[primitive.ObjectID]map[chan models.VoiceJob]struct{}{}}
+
+// voiceStageRunners menjalankan satu tahap dan mengisi artefaknya ke job
+var voiceStageRunners = map[string]func(ctx context.Context, job *models.VoiceJob) error{
+	models.VoiceStageConvert:    runVoiceConvert,
+	models.VoiceStagePreprocess: runVoicePreprocess,
+	models.VoiceStageUpload:     runVoiceUpload,
+	models.VoiceStageTranscribe: runVoiceTranscribe,
+	models.VoiceStageNLP:        runVoiceNLP,
+	models.VoiceStageTTS:        runVoiceTTS,
+	models.VoiceStageSave:       runVoiceSave,
+}
+
+// StartVoiceWorkers menjalankan worker pipeline suara. Worker mengambil job pending dari
+// MongoDB, termasuk job yang belum selesai sebelum server terakhir kali berhenti setelah
+// sewanya habis.
+func StartVoiceWorkers(n int) {
+	if n <= 0 {
+		n = 1
+	}
+	if err := os.MkdirAll(config.VoiceJobDir, 0o700); err != nil {
+		config.Log.Error("Gagal membuat direktori job suara: ", err)
+	}
+	for i := 0; i < n; i++ {
+		owner := jobWorkerID("voice", i)
+		go runJobWorker(voiceJobWake, config.JobPollInterval, func() (bool, error) {
+			return claimAndRunVoiceJob(owner)
+		})
+	}
+
+	go cleanVoiceSpool()
+
+	config.Log.Infof("🎙️ %d worker job suara berjalan", n)
+}
+
+// CreateVoiceJob menyimpan rekaman ke direktori spool, memeriksa isinya dengan ffprobe,
+// lalu mengunggahnya ke GridFS dan mengantrekan job-nya. Berkas yang terlalu besar,
+// bukan audio, atau terlalu panjang ditolak sebelum job dibuat. outputFormat adalah
+// format audio balasan.
+func CreateVoiceJob(ctx context.Context, chatID string, userID int, outputFormat string, voice models.VoiceSettings, audio io.Reader) (*models.VoiceJob, error) {
+	// Format ditentukan dari isi berkas, bukan dari nama atau Content-Type kiriman klien
+	src := bufio.NewReader(audio)
+	head, _ := src.Peek(audioSniffBytes)
+	inputFormat := SniffAudioFormat(head)
+	if inputFormat == "" {
+		return nil, ErrAudioUnsupported
+	}
+
+	now := time.Now()
+	job := models.VoiceJob{
+		ID:            primitive.NewObjectID(),
+		ChatID:        chatID,
+		UserID:        userID,
+		Voice:         voice,
+		InputFormat:   inputFormat,
+		OutputFormat:  outputFormat,
+		Status:        models.VoiceJobPending,
+		Retryable:     true,
+		UserMessageID: primitive.NewObjectID(),
+		BotMessageID:  primitive.NewObjectID(),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	for _, name := range models.VoiceStages {
+		job.Stages = append(job.Stages, models.VoiceJobStage{Name: name, Status: models.VoiceJobPending})
+	}
+
+	// Nama file dari server, bukan dari klien. Berkas lokal hanya untuk ffprobe; job bisa
+	// diklaim worker di instance lain sehingga worker membaca rekaman dari GridFS.
+	spool := filepath.Join(config.VoiceJobDir, job.ID.Hex()+"."+inputFormat)
+	f, err := os.OpenFile(spool, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("gagal menyimpan berkas audio: %v", err)
+	}
+	size, err := io.Copy(f, io.LimitReader(src, config.VoiceMaxUploadBytes+1))
+	f.Close()
+	defer os.Remove(spool)
+
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("gagal menyimpan berkas audio: %v", err)
+	case size > config.VoiceMaxUploadBytes:
+		return nil, ErrAudioTooLarge
+	case size < minVoiceUploadBytes:
+		return nil, ErrAudioTooSmall
+	}
+
+	probe, err := transcoder.ProbeFile(ctx, spool)
+	if err != nil {
+		return nil, err
+	}
+	// Durasi 0 berarti tidak diketahui; tahap konversi tetap memotong di VOICE_MAX_DURATION
+	if config.VoiceMaxDuration > 0 && probe.Duration > config.VoiceMaxDuration {
+		return nil, ErrAudioTooLong
+	}
+
+	if err := storeVoiceInput(job.ID, spool); err != nil {
+		return nil, fmt.Errorf("gagal menyimpan berkas audio: %v", err)
+	}
+
+	insertCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := config.MongoDB.Collection("voice_jobs").InsertOne(insertCtx, job); err != nil {
+		deleteVoiceInput(insertCtx, job.ID)
+		return nil, err
+	}
+
+	voiceJobWake.wake()
+	return &job, nil
+}
+
+// GetVoiceJob mengambil job milik user
+func GetVoiceJob(ctx context.Context, jobID string, userID int) (*models.VoiceJob, error) {
+	id, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return nil, ErrVoiceJobNotFound
+	}
+
+	var job models.VoiceJob
+	err = config.MongoDB.Collection("voice_jobs").FindOne(ctx, bson.M{"_id": id, "user_id": userID}).Decode(&job)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrVoiceJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RetryVoiceJob mengantrekan ulang job yang gagal; tahap yang sudah selesai tidak diulang
+func RetryVoiceJob(jobID string, userID int) (*models.VoiceJob, error) {
+	id, err := primitive.ObjectIDFromHex(jobID)
+	if err != nil {
+		return nil, ErrVoiceJobNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var job models.VoiceJob
+	err = config.MongoDB.Collection("voice_jobs").FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "user_id": userID, "status": models.VoiceJobFailed, "retryable": true},
+		bson.M{
+			"$set":   bson.M{"status": models.VoiceJobPending, "updated_at": time.Now()},
+			"$unset": bson.M{"error": "", "completed_at": "", "owner": "", "locked_until": ""},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&job)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if _, err := GetVoiceJob(ctx, jobID, userID); err != nil {
+			return nil, err
+		}
+		return nil, ErrVoiceJobNotRetryable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	voiceJobWake.wake()
+	publishVoiceJob(job)
+	return &job, nil
+}
+
+// SubscribeVoiceJob mendaftarkan penerima pembaruan job; panggil fungsi kembaliannya untuk berhenti
+func SubscribeVoiceJob(id primitive.ObjectID) (<-chan models.VoiceJob, func()) {
+	ch := make(chan models.VoiceJob, 8)
+
+	voiceJobSubs.Lock()
+	if voiceJobSubs.m[id] == nil {
+		voiceJobSubs.m[id] = map[chan models.VoiceJob]struct{}{}
+	}
+	voiceJobSubs.m[id][ch] = struct{}{}
+	voiceJobSubs.Unlock()
+
+	return ch, func() {
+		voiceJobSubs.Lock()
+		delete(voiceJobSubs.m[id], ch)
+		if len(voiceJobSubs.m[id]) == 0 {
+			delete(voiceJobSubs.m, id)
+		}
+		voiceJobSubs.Unlock()
+	}
+}
+
+func publishVoiceJob(job models.VoiceJob) {
+	// Salin tahap agar worker dapat terus mengubah job tanpa balapan dengan pelanggan
+	job.Stages = append([]models.VoiceJobStage(nil), job.Stages...)
+
+	voiceJobSubs.Lock()
+	defer voiceJobSubs.Unlock()
+	for ch := range voiceJobSubs.m[job.ID] {
+		// Pelanggan yang lambat cukup melewatkan pembaruan antara; status akhir tetap dikirim lewat polling
+		select {
+		case ch <- job:
+		default:
+		}
+	}
+}
+
+// saveVoiceJob menyimpan progres job dan memperpanjang sewanya. Transkrip dan balasan
+// disimpan bermasker (plus salinan terenkripsi bila diizinkan); teks polos tetap di
+// memori worker. Penyimpanan gagal dengan errVoiceJobLeaseLost bila job sudah diklaim
+// worker lain setelah sewa ini habis.
+func saveVoiceJob(job *models.VoiceJob) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	job.UpdatedAt = now
+	if job.Terminal() {
+		job.LockedUntil = nil
+	} else {
+		lease := now.Add(config.VoiceJobLease)
+		job.LockedUntil = &lease
+	}
+
+	stored := *job
+	stored.Transcript, _, stored.TranscriptEncrypted = ProtectText(job.Transcript)
+	stored.Response, _, stored.ResponseEncrypted = ProtectText(job.Response)
+
+	res, err := config.MongoDB.Collection("voice_jobs").ReplaceOne(ctx, bson.M{"_id": job.ID, "owner": job.Owner}, stored)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errVoiceJobLeaseLost
+	}
+	publishVoiceJob(stored)
+	return nil
+}
+
+// restoreVoiceJobText membuka salinan terenkripsi transkrip dan balasan ke memori.
+// Tanpa salinan (atau kunci), tahap berikutnya memakai teks bermasker.
+func restoreVoiceJobText(job *models.VoiceJob) {
+	for _, f := range []struct {
+		text      *string
+		encrypted string
+	}{{&job.Transcript, job.TranscriptEncrypted}, {&job.Response, job.ResponseEncrypted}} {
+		if f.encrypted == "" {
+			continue
+		}
+		plain, err := DecryptPII(f.encrypted)
+		if err != nil {
+			config.Log.Warn("Gagal membuka teks asli job suara ", job.ID.Hex(), ": ", err)
+			continue
+		}
+		*f.text = plain
+	}
+	job.TranscriptEncrypted, job.ResponseEncrypted = "", ""
+}
+
+// claimAndRunVoiceJob mengklaim satu job lalu menjalankannya; false bila tidak ada job
+func claimAndRunVoiceJob(owner string) (bool, error) {
+	job, err := claimVoiceJob(owner)
+	if err != nil || job == nil {
+		return false, err
+	}
+	if err := runVoiceJob(job); err != nil {
+		config.Log.Errorf("Job suara %s gagal: %v", job.ID.Hex(), err)
+	}
+	return true, nil
+}
+
+// claimVoiceJob mengklaim job tertua yang bisa diproses secara atomik. Job running hanya
+// diambil alih setelah sewanya habis. Mengembalikan nil bila tidak ada job.
+func claimVoiceJob(owner string) (*models.VoiceJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var job models.VoiceJob
+	err := config.MongoDB.Collection("voice_jobs").FindOneAndUpdate(ctx,
+		claimableJobFilter(models.VoiceJobPending, models.VoiceJobRunning, now),
+		bson.M{"$set": bson.M{
+			"status":       models.VoiceJobRunning,
+			"owner":        owner,
+			"locked_until": now.Add(config.VoiceJobLease),
+			"updated_at":   now,
+		}},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "created_at", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&job)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func runVoiceJob(job *models.VoiceJob) error {
+	// Pelanggan menerima versi tersimpan (bermasker) sebelum teks asli dibuka
+	publishVoiceJob(*job)
+	restoreVoiceJobText(job)
+	resetMissingVoiceSpool(job)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var err error
+	for i := range job.Stages {
+		stage := &job.Stages[i]
+		if stage.Status == models.VoiceJobDone {
+			continue
+		}
+
+		started := time.Now()
+		stage.Status = models.VoiceJobRunning
+		stage.Attempts++
+		stage.Error = ""
+		stage.StartedAt = &started
+		stage.CompletedAt = nil
+		job.Stage = stage.Name
+		if err := saveVoiceJob(job); err != nil {
+			return err
+		}
+
+		runner, ok := voiceStageRunners[stage.Name]
+		if !ok {
+			err = &voiceStageFatal{err: fmt.Errorf("tahap tidak dikenal: %s", stage.Name)}
+		} else {
+			err = runner(ctx, job)
+		}
+
+		completed := time.Now()
+		stage.CompletedAt = &completed
+		if err != nil {
+			stage.Status = models.VoiceJobFailed
+			stage.Error = err.Error()
+			job.Status = models.VoiceJobFailed
+			job.Error = err.Error()
+			job.CompletedAt = &completed
+
+			var fatal *voiceStageFatal
+			job.Retryable = !errors.As(err, &fatal)
+			if fatal != nil {
+				job.Reasons = fatal.reasons
+				removeVoiceSpool(job)
+			}
+			if serr := saveVoiceJob(job); serr != nil {
+				config.Log.Error("Gagal menyimpan status job suara: ", serr)
+			}
+			return fmt.Errorf("tahap %s: %v", stage.Name, err)
+		}
+		stage.Status = models.VoiceJobDone
+	}
+
+	now := time.Now()
+	job.Status = models.VoiceJobDone
+	job.Stage = ""
+	job.CompletedAt = &now
+	removeVoiceSpool(job)
+	return saveVoiceJob(job)
+}
+
+// resetMissingVoiceSpool mengulang konversi bila hasilnya tidak ada di instance ini
+// (job diklaim worker lain setelah retry atau sewa habis) padahal tahap sesudahnya
+// masih membutuhkannya. Rekaman asli selalu tersedia di GridFS.
+func resetMissingVoiceSpool(job *models.VoiceJob) {
+	if job.ConvertedPath == "" {
+		return
+	}
+	if _, err := os.Stat(job.ConvertedPath); err == nil {
+		return
+	}
+
+	needed := false
+	for _, stage := range job.Stages {
+		switch stage.Name {
+		case models.VoiceStagePreprocess, models.VoiceStageUpload, models.VoiceStageTranscribe:
+			needed = needed || stage.Status != models.VoiceJobDone
+		}
+	}
+	if !needed {
+		return
+	}
+
+	job.ConvertedPath = ""
+	for i := range job.Stages {
+		switch job.Stages[i].Name {
+		case models.VoiceStageConvert, models.VoiceStagePreprocess:
+			job.Stages[i].Status = models.VoiceJobPending
+		}
+	}
+}
+
+func runVoiceConvert(ctx context.Context, job *models.VoiceJob) error {
+	input, err := readVoiceInput(job.ID)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return &voiceStageFatal{err: errors.New("berkas audio asli sudah tidak tersedia, silakan unggah ulang")}
+	}
+	if err != nil {
+		return fmt.Errorf("gagal membaca berkas audio: %v", err)
+	}
+
+	// Salinan untuk STT dan riwayat selalu mono 16 kHz dalam VOICE_STT_FORMAT
+	opts, err := transcodeOptionsFor(config.VoiceSTTFormat)
+	if err != nil {
+		return &voiceStageFatal{err: err}
+	}
+	opts.SampleRate = voiceSampleRate
+	opts.Channels = 1
+	opts.MaxDuration = config.VoiceMaxDuration
+
+	converted, err := transcoder.Transcode(ctx, input, opts)
+	if err != nil {
+		// Batas waktu atau antrean penuh bisa berhasil bila diulang; berkas rusak tidak
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return err
+		}
+		config.Log.Warn("Konversi audio job ", job.ID.Hex(), " gagal: ", err)
+		return &voiceStageFatal{err: errors.New("konversi audio gagal, berkas audio tidak dapat dibaca")}
+	}
+
+	outputPath := filepath.Join(config.VoiceJobDir, job.ID.Hex()+"_converted."+config.VoiceSTTFormat)
+	if err := os.WriteFile(outputPath, converted, 0o600); err != nil {
+		return fmt.Errorf("gagal menyimpan hasil konversi: %v", err)
+	}
+	job.ConvertedPath = outputPath
+	return nil
+}
+
+// runVoicePreprocess membersihkan salinan STT dan menolak rekaman tanpa ucapan sebelum
+// diunggah dan ditranskripsi. Hasilnya ditulis ke berkas baru agar retry tahap ini
+// tidak memproses audio yang sudah diproses.
+func runVoicePreprocess(ctx context.Context, job *models.VoiceJob) error {
+	audio, err := os.ReadFile(job.ConvertedPath)
+	if err != nil {
+		return fmt.Errorf("gagal membaca audio hasil konversi: %v", err)
+	}
+
+	format := AudioFormatOf(job.ConvertedPath)
+	processed, analysis, err := PreprocessVoice(ctx, audio, format)
+	if errors.Is(err, ErrNoSpeech) {
+		return &voiceStageFatal{err: err}
+	}
+	if err != nil {
+		return err
+	}
+	if analysis != nil {
+		job.SpeechMs = analysis.Speech.Milliseconds()
+	}
+
+	outputPath := filepath.Join(config.VoiceJobDir, job.ID.Hex()+"_processed."+format)
+	if err := os.WriteFile(outputPath, processed, 0o600); err != nil {
+		return fmt.Errorf("gagal menyimpan hasil pra-pemrosesan: %v", err)
+	}
+	if outputPath != job.ConvertedPath {
+		os.Remove(job.ConvertedPath)
+		job.ConvertedPath = outputPath
+	}
+
+	// Durasi hanya informasi tambahan; kegagalan probe tidak menggagalkan tahap
+	if probe, err := transcoder.ProbeFile(ctx, outputPath); err == nil {
+		job.AudioMs = probe.Duration.Milliseconds()
+	}
+	return nil
+}
+
+// runVoiceUpload menyimpan salinan rekaman untuk riwayat chat. Unggahan ke S3 dan nama
+// job Transcribe hanya disiapkan bila rantai STT membutuhkan MediaURL; provider lain
+// membaca audio langsung sehingga rekaman cukup disimpan di penyimpanan aktif (lokal
+// bila AWS tidak dikonfigurasi).
+func runVoiceUpload(_ context.Context, job *models.VoiceJob) error {
+	audio, err := os.ReadFile(job.ConvertedPath)
+	if err != nil {
+		return fmt.Errorf("gagal membaca audio hasil konversi: %v", err)
+	}
+
+	fileName := fmt.Sprintf("user-audio-%s.%s", job.ID.Hex(), AudioFormatOf(job.ConvertedPath))
+	if !STTNeedsMediaURL() {
+		url, err := StoreUserVoice(fileName, audio)
+		if err != nil {
+			return err
+		}
+		job.UserAudioURL = url
+		return nil
+	}
+
+	url, err := UploadUserVoiceToS3(fileName, audio)
+	if err != nil {
+		return err
+	}
+	job.UserAudioURL = url
+	job.TranscribeJob = TranscribeJobName(fileName)
+	return nil
+}
+
+func runVoiceTranscribe(ctx context.Context, job *models.VoiceJob) error {
+	audio, err := os.ReadFile(job.ConvertedPath)
+	if err != nil {
+		return fmt.Errorf("gagal membaca audio hasil konversi: %v", err)
+	}
+
+	result, err := TranscribeSpeech(ctx, STTRequest{
+		Audio:    audio,
+		Format:   AudioFormatOf(job.ConvertedPath),
+		MediaURL: job.UserAudioURL,
+		JobName:  job.TranscribeJob,
+	})
+	if err != nil {
+		return err
+	}
+	job.Transcript = result.Transcript
+	job.STTProvider = result.Provider
+	config.Log.Info("📝 Transkrip pengguna: ", MaskForLog(result.Transcript))
+	return nil
+}
+
+func runVoiceNLP(_ context.Context, job *models.VoiceJob) error {
+	// Filter kata kasar dan spam sebelum transkrip diteruskan ke NLP
+	moderation := ModerateMessage(job.UserID, job.Transcript)
+	if moderation.Action == ModerationReject {
+		return &voiceStageFatal{err: errors.New("Pesan ditolak"), reasons: moderation.Reasons}
+	}
+	job.Flags = nil
+	if moderation.Action == ModerationFlag {
+		job.Flags = moderation.Reasons
+		config.Log.Warn("Transkrip suara ditandai filter: ", moderation.Reasons, " chatID: ", job.ChatID)
+	}
+
+	nlpResp, err := CallNLPService(job.Transcript)
+	if err != nil {
+		return fmt.Errorf("gagal mendapatkan respons NLP: %v", err)
+	}
+	job.Intent = nlpResp.Intent
+	job.Confidence = nlpResp.Confidence
+	job.Response = nlpResp.ResponseMessage
+	return nil
+}
+
+func runVoiceTTS(ctx context.Context, job *models.VoiceJob) error {
+	audio, err := SynthesizeToS3(ctx, TTSRequest{Text: job.Response, Voice: job.Voice, Format: job.OutputFormat})
+	if err != nil {
+		return err
+	}
+	job.BotAudioURL = audio.URL
+	job.TTSProvider = audio.Provider
+	return nil
+}
+
+// runVoiceSave menyimpan giliran suara dengan ID tetap sehingga retry setelah
+// penyimpanan berhasil tidak membuat pesan ganda
+func runVoiceSave(ctx context.Context, job *models.VoiceJob) error {
+	var existing models.Message
+	err := config.MongoDB.Collection("messages").FindOne(ctx, bson.M{"_id": job.UserMessageID}).Decode(&existing)
+	if err == nil {
+		job.Transcript = existing.Transcript
+		return nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	now := time.Now()
+	userMsg := models.Message{
+		ID:         job.UserMessageID,
+		Modality:   models.ModalityVoice,
+		Sender:     "user",
+		Transcript: job.Transcript,
+		Intent:     job.Intent,
+		Flags:      job.Flags,
+		Timestamp:  now,
+	}
+	// Giliran streaming bisa tersimpan tanpa audio bila unggahannya gagal
+	if job.UserAudioURL != "" {
+		userMsg.Audio = &models.AudioAttachment{
+			URL:        job.UserAudioURL,
+			Format:     AudioFormatOf(job.UserAudioURL),
+			DurationMs: job.AudioMs,
+			SpeechMs:   job.SpeechMs,
+		}
+	}
+	botMsg := models.Message{
+		ID:         job.BotMessageID,
+		Modality:   models.ModalityVoice,
+		Sender:     "bot",
+		Audio:      &models.AudioAttachment{URL: job.BotAudioURL, Format: AudioFormatOf(job.BotAudioURL)},
+		Transcript: job.Response,
+		Intent:     job.Intent,
+		Confidence: job.Confidence,
+		Timestamp:  now.Add(time.Millisecond),
+	}
+
+	// Masking PII pada transkrip sebelum disimpan
+	ProtectMessage(&userMsg)
+	ProtectMessage(&botMsg)
+
+	if err := SaveMessages(ctx, job.ChatID, job.UserID, "", &userMsg, &botMsg); err != nil {
+		return fmt.Errorf("gagal menyimpan pesan suara: %v", err)
+	}
+
+	// Job ikut menyimpan versi yang sudah disamarkan, bukan transkrip mentah
+	job.Transcript = userMsg.Transcript
+	job.Response = botMsg.Transcript
+	return nil
+}
+
+func newVoiceInputBucket() (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(config.MongoDB, options.GridFSBucket().SetName(voiceInputBucket))
+}
+
+// storeVoiceInput mengunggah rekaman asli ke GridFS dengan ID job sebagai _id berkas
+func storeVoiceInput(jobID primitive.ObjectID, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bucket, err := newVoiceInputBucket()
+	if err != nil {
+		return err
+	}
+	bucket.SetWriteDeadline(time.Now().Add(voiceInputTimeout))
+	return bucket.UploadFromStreamWithID(jobID, jobID.Hex(), f)
+}
+
+func readVoiceInput(jobID primitive.ObjectID) ([]byte, error) {
+	bucket, err := newVoiceInputBucket()
+	if err != nil {
+		return nil, err
+	}
+	bucket.SetReadDeadline(time.Now().Add(voiceInputTimeout))
+
+	var buf bytes.Buffer
+	if _, err := bucket.DownloadToStream(jobID, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// deleteVoiceInput menghapus rekaman asli job; berkas yang sudah tidak ada diabaikan
+func deleteVoiceInput(ctx context.Context, jobID primitive.ObjectID) error {
+	bucket, err := newVoiceInputBucket()
+	if err != nil {
+		return err
+	}
+	if err := bucket.DeleteContext(ctx, jobID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+		return err
+	}
+	return nil
+}
+
+// removeVoiceSpool membuang rekaman asli dan berkas sementara job yang tidak akan diulang
+func removeVoiceSpool(job *models.VoiceJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := deleteVoiceInput(ctx, job.ID); err != nil {
+		config.Log.Warn("Gagal menghapus rekaman asli job ", job.ID.Hex(), ": ", err)
+	}
+	if job.ConvertedPath != "" {
+		os.Remove(job.ConvertedPath)
+	}
+}
+
+// deleteVoiceJobs menghapus job suara milik chat beserta rekaman asli dan berkas
+// spool-nya. Worker yang masih memegang job akan kehilangan klaimnya saat menyimpan
+// tahap berikutnya.
+func deleteVoiceJobs(ctx context.Context, chatID string) (int64, error) {
+	filter := bson.M{"chat_id": chatID}
+	cursor, err := config.MongoDB.Collection("voice_jobs").Find(ctx, filter,
+		options.Find().SetProjection(bson.M{"converted_path": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var jobs []models.VoiceJob
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return 0, err
+	}
+	for _, job := range jobs {
+		if err := deleteVoiceInput(ctx, job.ID); err != nil {
+			return 0, err
+		}
+		if job.ConvertedPath != "" {
+			os.Remove(job.ConvertedPath)
+		}
+	}
+
+	res, err := config.MongoDB.Collection("voice_jobs").DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+// cleanVoiceSpool menghapus file sementara job gagal yang tidak pernah diulang, serta
+// rekaman asli di GridFS yang job-nya sudah dibuang (TTL completed_at)
+func cleanVoiceSpool() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for ; ; <-ticker.C {
+		cutoff := time.Now().Add(-voiceSpoolMaxAge)
+		if entries, err := os.ReadDir(config.VoiceJobDir); err == nil {
+			for _, e := range entries {
+				info, err := e.Info()
+				if err == nil && !e.IsDir() && info.ModTime().Before(cutoff) {
+					os.Remove(filepath.Join(config.VoiceJobDir, e.Name()))
+				}
+			}
+		}
+		if err := cleanOrphanVoiceInputs(cutoff); err != nil {
+			config.Log.Warn("Gagal membersihkan rekaman asli job suara: ", err)
+		}
+	}
+}
+
+// cleanOrphanVoiceInputs menghapus rekaman asli lebih tua dari cutoff yang tidak lagi
+// punya dokumen job
+func cleanOrphanVoiceInputs(cutoff time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	bucket, err := newVoiceInputBucket()
+	if err != nil {
+		return err
+	}
+	cursor, err := bucket.GetFilesCollection().Find(ctx, bson.M{"uploadDate": bson.M{"$lt": cutoff}},
+		options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	var files []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &files); err != nil {
+		return err
+	}
+	for _, f := range files {
+		err := config.MongoDB.Collection("voice_jobs").FindOne(ctx, bson.M{"_id": f.ID},
+			options.FindOne().SetProjection(bson.M{"_id": 1})).Err()
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			err = deleteVoiceInput(ctx, f.ID)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func insertVoiceJob(t *testing.T, job models.VoiceJob) {
+	t.Helper()
+	if _, err := config.MongoDB.Collection("voice_jobs").InsertOne(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClaimVoiceJobRespectsLease(t *testing.T) {
+	useTestMongo(t)
+	config.VoiceJobLease = time.Minute
+
+	base := time.Now().Add(-time.Hour)
+	future, past := time.Now().Add(time.Minute), time.Now().Add(-time.Minute)
+	leased := models.VoiceJob{ID: primitive.NewObjectID(), Status: models.VoiceJobRunning, Owner: "lain", LockedUntil: &future, CreatedAt: base}
+	expired := models.VoiceJob{ID: primitive.NewObjectID(), Status: models.VoiceJobRunning, Owner: "mati", LockedUntil: &past, CreatedAt: base.Add(time.Second)}
+	pending := models.VoiceJob{ID: primitive.NewObjectID(), Status: models.VoiceJobPending, CreatedAt: base.Add(2 * time.Second)}
+	for _, job := range []models.VoiceJob{leased, expired, pending} {
+		insertVoiceJob(t, job)
+	}
+
+	for _, want := range []primitive.ObjectID{expired.ID, pending.ID} {
+		job, err := claimVoiceJob("worker-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job == nil || job.ID != want {
+			t.Fatalf("klaim = %+v, ingin job %s", job, want.Hex())
+		}
+		if job.Owner != "worker-1" || job.LockedUntil == nil || !job.LockedUntil.After(time.Now()) {
+			t.Errorf("job %s tidak tercatat milik worker-1 dengan sewa aktif", job.ID.Hex())
+		}
+	}
+
+	job, err := claimVoiceJob("worker-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job != nil {
+		t.Fatalf("job %s dengan sewa aktif tidak boleh diklaim ulang", job.ID.Hex())
+	}
+}
+
+func TestSaveVoiceJobMasksTextAndDetectsLostLease(t *testing.T) {
+	useTestMongo(t)
+	config.VoiceJobLease = time.Minute
+
+	const phone = "081234567890"
+	job := models.VoiceJob{
+		ID:         primitive.NewObjectID(),
+		Status:     models.VoiceJobRunning,
+		Owner:      "worker-1",
+		Transcript: "nomor saya " + phone,
+		Response:   "baik, kami hubungi " + phone,
+		CreatedAt:  time.Now(),
+	}
+	insertVoiceJob(t, models.VoiceJob{ID: job.ID, Status: job.Status, Owner: job.Owner, CreatedAt: job.CreatedAt})
+
+	if err := saveVoiceJob(&job); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(job.Transcript, phone) {
+		t.Error("teks polos harus tetap tersedia di memori worker")
+	}
+
+	raw, err := config.MongoDB.Collection("voice_jobs").FindOne(context.Background(), bson.M{"_id": job.ID}).Raw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(raw.String(), phone) {
+		t.Errorf("dokumen voice_jobs memuat PII mentah: %s", raw)
+	}
+
+	// Worker lain mengambil alih setelah sewa habis
+	_, err = config.MongoDB.Collection("voice_jobs").UpdateOne(context.Background(),
+		bson.M{"_id": job.ID}, bson.M{"$set": bson.M{"owner": "worker-2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := saveVoiceJob(&job); !errors.Is(err, errVoiceJobLeaseLost) {
+		t.Fatalf("err = %v, ingin errVoiceJobLeaseLost", err)
+	}
+}
+
+func TestResetMissingVoiceSpool(t *testing.T) {
+	stages := func(done ...string) []models.VoiceJobStage {
+		var out []models.VoiceJobStage
+		for _, name := range models.VoiceStages {
+			status := models.VoiceJobPending
+			for _, d := range done {
+				if d == name {
+					status = models.VoiceJobDone
+				}
+			}
+			out = append(out, models.VoiceJobStage{Name: name, Status: status})
+		}
+		return out
+	}
+	present := filepath.Join(t.TempDir(), "converted.mp3")
+	if err := os.WriteFile(present, []byte("audio"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(t.TempDir(), "hilang.mp3")
+	afterPreprocess := []string{models.VoiceStageConvert, models.VoiceStagePreprocess}
+	afterTranscribe := append(afterPreprocess, models.VoiceStageUpload, models.VoiceStageTranscribe)
+
+	for _, tc := range []struct {
+		name      string
+		converted string
+		done      []string
+		reset     bool
+	}{
+		{"berkas ada di instance ini", present, afterPreprocess, false},
+		{"berkas hilang sebelum transkripsi", missing, afterPreprocess, true},
+		{"berkas hilang setelah transkripsi", missing, afterTranscribe, false},
+	} {
+		job := models.VoiceJob{ConvertedPath: tc.converted, Stages: stages(tc.done...)}
+		resetMissingVoiceSpool(&job)
+
+		convert := job.Stages[0].Status == models.VoiceJobPending && job.Stages[1].Status == models.VoiceJobPending
+		if convert != tc.reset || (job.ConvertedPath == "") != tc.reset {
+			t.Errorf("%s: konversi diulang = %v (path %q), ingin %v", tc.name, convert, job.ConvertedPath, tc.reset)
+		}
+	}
+}
+
+func TestVoiceInputSharedThroughGridFS(t *testing.T) {
+	useTestMongo(t)
+	ctx := context.Background()
+
+	spool := filepath.Join(t.TempDir(), "input.webm")
+	if err := os.WriteFile(spool, []byte("rekaman-asli"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	id := primitive.NewObjectID()
+	if err := storeVoiceInput(id, spool); err != nil {
+		t.Fatal(err)
+	}
+	// Worker di instance lain tidak punya berkas spool
+	os.Remove(spool)
+
+	got, err := readVoiceInput(id)
+	if err != nil || string(got) != "rekaman-asli" {
+		t.Fatalf("readVoiceInput = %q, %v", got, err)
+	}
+
+	if err := deleteVoiceInput(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := deleteVoiceInput(ctx, id); err != nil {
+		t.Errorf("menghapus rekaman yang sudah tidak ada: %v", err)
+	}
+	job := &models.VoiceJob{ID: id}
+	if err := runVoiceConvert(ctx, job); err == nil || !strings.Contains(err.Error(), "unggah ulang") {
+		t.Errorf("konversi tanpa rekaman asli: err = %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// failingSTT selalu gagal, untuk menguji peralihan ke provider cadangan
+type failingSTT struct{ needsMediaURL bool }
+
+func (failingSTT) Name() string          { return "failing" }
+func (p failingSTT) NeedsMediaURL() bool { return p.needsMediaURL }
+func (failingSTT) Transcribe(context.Context, STTRequest) (string, error) {
+	return "", errors.New("provider mati")
+}
+
+// withoutAWS menyiapkan konfigurasi suara tanpa AWS: audio disimpan di direktori
+// sementara, STT memakai chain yang diberikan, dan TTS memakai provider fake
+func withoutAWS(t *testing.T, chain ...STTProvider) {
+	t.Helper()
+	oldS3, oldTranscribe, oldChain := s3Client, transcribeClient, sttChain
+	oldDir, oldTTS, oldFallback := config.AudioLocalDir, config.TTSProvider, config.TTSFallbackProvider
+	oldSSML, oldSTTTimeout, oldTTSTimeout := config.TTSSSMLEnabled, config.STTTimeout, config.TTSTimeout
+	t.Cleanup(func() {
+		s3Client, transcribeClient, sttChain = oldS3, oldTranscribe, oldChain
+		config.AudioLocalDir, config.TTSProvider, config.TTSFallbackProvider = oldDir, oldTTS, oldFallback
+		config.TTSSSMLEnabled, config.STTTimeout, config.TTSTimeout = oldSSML, oldSTTTimeout, oldTTSTimeout
+	})
+
+	s3Client, transcribeClient, sttChain = nil, nil, chain
+	// Transkrip fake selalu sama; jangan sampai ditolak sebagai pesan duplikat
+	recentMessages = &duplicateTracker{seen: make(map[string][]time.Time)}
+	config.AudioLocalDir = t.TempDir()
+	config.TTSProvider, config.TTSFallbackProvider = "fake", ""
+	// Leksikon SSML dibaca dari MongoDB
+	config.TTSSSMLEnabled = false
+	config.STTTimeout, config.TTSTimeout = 5*time.Second, 5*time.Second
+}
+
+// useFakeNLP mengarahkan CallNLPService ke server lokal yang selalu membalas reply
+func useFakeNLP(t *testing.T, want, reply string) {
+	t.Helper()
+	nlp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["message"] != want {
+			t.Errorf("NLP menerima %q, ingin %q", req["message"], want)
+		}
+		json.NewEncoder(w).Encode(nlpResponse{Intent: "jadwal", ResponseMessage: reply, Confidence: 0.9})
+	}))
+	old := nlpServiceURL
+	nlpServiceURL = nlp.URL
+	t.Cleanup(func() {
+		nlpServiceURL = old
+		nlp.Close()
+	})
+}
+
+func TestVoicePipelineRunsWithoutAWS(t *testing.T) {
+	withoutAWS(t, fakeSTT{transcript: "jadwal kuliah hari ini"})
+
+	useFakeNLP(t, "jadwal kuliah hari ini", "Kuliah mulai pukul delapan.")
+
+	converted := filepath.Join(t.TempDir(), "input.mp3")
+	if err := os.WriteFile(converted, []byte("audio-pengguna"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	job := &models.VoiceJob{
+		ID:            primitive.NewObjectID(),
+		ChatID:        "chat-tanpa-aws",
+		UserID:        1,
+		ConvertedPath: converted,
+		OutputFormat:  AudioFormatMP3,
+	}
+
+	ctx := context.Background()
+	for _, stage := range []struct {
+		name string
+		run  func(context.Context, *models.VoiceJob) error
+	}{
+		{models.VoiceStageUpload, runVoiceUpload},
+		{models.VoiceStageTranscribe, runVoiceTranscribe},
+		{models.VoiceStageNLP, runVoiceNLP},
+		{models.VoiceStageTTS, runVoiceTTS},
+	} {
+		if err := stage.run(ctx, job); err != nil {
+			t.Fatalf("tahap %s gagal: %v", stage.name, err)
+		}
+	}
+
+	if job.TranscribeJob != "" {
+		t.Errorf("job Transcribe tidak boleh dibuat tanpa AWS, dapat %q", job.TranscribeJob)
+	}
+	if job.Transcript != "jadwal kuliah hari ini" || job.STTProvider != "fake" {
+		t.Errorf("transkrip = %q dari %q", job.Transcript, job.STTProvider)
+	}
+	if job.Response != "Kuliah mulai pukul delapan." || job.TTSProvider != "fake" {
+		t.Errorf("balasan = %q dari %q", job.Response, job.TTSProvider)
+	}
+	for _, url := range []string{job.UserAudioURL, job.BotAudioURL} {
+		path, ok := localAudioFromURL(url)
+		if !ok {
+			t.Fatalf("URL audio %q bukan URL lokal", url)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("audio %q tidak tersimpan: %v", url, err)
+		}
+	}
+}
+
+func TestOpenLocalAudioChecksOwner(t *testing.T) {
+	useTestMongo(t)
+	withoutAWS(t)
+
+	url, err := StoreUserVoice("audio-milik-1.mp3", []byte("rekaman"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := models.Message{Sender: "user", Modality: models.ModalityVoice, Audio: &models.AudioAttachment{URL: url}, Timestamp: time.Now()}
+	if err := SaveMessages(context.Background(), "chat-audio", 1, "a", &msg); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Base(url)
+
+	file, err := OpenLocalAudio(name, 1)
+	if err != nil {
+		t.Fatalf("pemilik tidak dapat membuka audionya: %v", err)
+	}
+	file.Close()
+
+	for _, tc := range []struct {
+		name     string
+		filename string
+		userID   int
+	}{
+		{"pengguna lain", name, 2},
+		{"berkas tanpa pesan", "user-audio-tebakan.mp3", 1},
+		{"berkas tersembunyi", ".audio-123", 1},
+	} {
+		if _, err := OpenLocalAudio(tc.filename, tc.userID); !errors.Is(err, ErrAudioNotFound) {
+			t.Errorf("%s: err = %v, ingin ErrAudioNotFound", tc.name, err)
+		}
+	}
+}
+
+func TestTranscribeSpeechFallsBackToFake(t *testing.T) {
+	withoutAWS(t, failingSTT{}, fakeSTT{transcript: "halo"})
+
+	result, err := TranscribeSpeech(context.Background(), STTRequest{Audio: []byte("audio")})
+	if err != nil {
+		t.Fatalf("fallback gagal: %v", err)
+	}
+	if result.Provider != "fake" || result.Transcript != "halo" {
+		t.Errorf("hasil = %+v, ingin transkrip dari fake", result)
+	}
+
+	sttChain = []STTProvider{failingSTT{}, failingSTT{}}
+	_, err = TranscribeSpeech(context.Background(), STTRequest{Audio: []byte("audio")})
+	if err == nil || strings.Count(err.Error(), "provider mati") != 2 {
+		t.Errorf("error semua provider harus digabung, dapat %v", err)
+	}
+}
+
+func TestSTTNeedsMediaURL(t *testing.T) {
+	withoutAWS(t, fakeSTT{})
+	if STTNeedsMediaURL() {
+		t.Error("fake tidak membutuhkan MediaURL")
+	}
+	sttChain = []STTProvider{fakeSTT{}, failingSTT{needsMediaURL: true}}
+	if !STTNeedsMediaURL() {
+		t.Error("provider cadangan berbasis S3 harus membuat tahap unggah menyiapkan MediaURL")
+	}
+}
+
+func TestAWSProvidersRequireAWSConfig(t *testing.T) {
+	old := config.AWSAccessKeyID
+	config.AWSAccessKeyID = ""
+	defer func() { config.AWSAccessKeyID = old }()
+
+	if _, err := newAWSTranscribeSTT(); err == nil {
+		t.Error("STT aws harus gagal tanpa konfigurasi AWS")
+	}
+	if _, err := newPollyTTS(); err == nil {
+		t.Error("TTS polly harus gagal tanpa konfigurasi AWS")
+	}
+}
+
+func TestPollyRequiresVoice(t *testing.T) {
+	oldKey, oldSecret, oldBucket, oldVoice := config.AWSAccessKeyID, config.AWSSecretAccessKey, config.AWSBucketName, config.TTSPollyVoice
+	t.Cleanup(func() {
+		config.AWSAccessKeyID, config.AWSSecretAccessKey, config.AWSBucketName, config.TTSPollyVoice = oldKey, oldSecret, oldBucket, oldVoice
+	})
+	config.AWSAccessKeyID, config.AWSSecretAccessKey, config.AWSBucketName = "kunci", "rahasia", "bucket"
+
+	config.TTSPollyVoice = ""
+	if _, err := newPollyTTS(); err == nil || !strings.Contains(err.Error(), "TTS_POLLY_VOICE") {
+		t.Errorf("TTS polly tanpa TTS_POLLY_VOICE: err = %v", err)
+	}
+	config.TTSPollyVoice = "Joanna"
+	if _, err := newPollyTTS(); err != nil {
+		t.Errorf("TTS polly dengan TTS_POLLY_VOICE: %v", err)
+	}
+}
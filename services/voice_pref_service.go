@@ -0,0 +1,145 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Rentang yang didukung Google TTS; provider lain memetakan dari nilai yang sama
+const (
+	minSpeakingRate = 0.25
+	maxSpeakingRate = 2.0
+	maxPitch        = 20.0
+)
+
+var ErrVoiceSettingsInvalid = errors.New("pengaturan suara tidak valid")
+
+// GetVoicePreferences mengambil preferensi suara user; user tanpa preferensi
+// mendapat pengaturan kosong (default provider)
+func GetVoicePreferences(ctx context.Context, userID int) (*models.VoicePreferences, error) {
+	prefs := models.VoicePreferences{UserID: userID}
+	err := config.MongoDB.Collection("voice_preferences").FindOne(ctx, bson.M{"_id": userID}).Decode(&prefs)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// SaveVoicePreferences memvalidasi lalu menyimpan preferensi suara user
+func SaveVoicePreferences(ctx context.Context, userID int, settings models.VoiceSettings) (*models.VoicePreferences, error) {
+	if err := ValidateVoiceSettings(ctx, &settings); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	prefs := models.VoicePreferences{UserID: userID, VoiceSettings: settings, UpdatedAt: &now}
+	if _, err := config.MongoDB.Collection("voice_preferences").ReplaceOne(ctx,
+		bson.M{"_id": userID}, prefs, options.Replace().SetUpsert(true)); err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// ValidateVoiceSettings menormalkan dan memeriksa pengaturan terhadap daftar suara
+// provider. Nama suara selalu terikat ke provider sehingga Provider diisi provider
+// deployment bila kosong.
+func ValidateVoiceSettings(ctx context.Context, s *models.VoiceSettings) error {
+	s.Provider = strings.TrimSpace(s.Provider)
+	s.Gender = strings.ToLower(strings.TrimSpace(s.Gender))
+	s.VoiceName = strings.TrimSpace(s.VoiceName)
+
+	if !ValidTTSProvider(s.Provider) {
+		return fmt.Errorf("%w: provider %q tidak dikenal", ErrVoiceSettingsInvalid, s.Provider)
+	}
+	switch s.Gender {
+	case "", models.VoiceGenderMale, models.VoiceGenderFemale, models.VoiceGenderNeutral:
+	default:
+		return fmt.Errorf("%w: gender harus male, female, atau neutral", ErrVoiceSettingsInvalid)
+	}
+	if s.SpeakingRate != 0 && (s.SpeakingRate < minSpeakingRate || s.SpeakingRate > maxSpeakingRate) {
+		return fmt.Errorf("%w: speaking_rate harus antara %.2f dan %.1f", ErrVoiceSettingsInvalid, minSpeakingRate, maxSpeakingRate)
+	}
+	if s.Pitch < -maxPitch || s.Pitch > maxPitch {
+		return fmt.Errorf("%w: pitch harus antara -%.0f dan %.0f semitone", ErrVoiceSettingsInvalid, maxPitch, maxPitch)
+	}
+	if s.VoiceName == "" {
+		return nil
+	}
+
+	if s.Provider == "" {
+		s.Provider = config.TTSProvider
+	}
+	voices, err := ListTTSVoices(ctx, s.Provider)
+	if err != nil {
+		return fmt.Errorf("gagal mengambil daftar suara %s: %v", s.Provider, err)
+	}
+	for _, v := range voices {
+		if v.Name != s.VoiceName {
+			continue
+		}
+		if s.Gender != "" && v.Gender != "" && v.Gender != s.Gender {
+			return fmt.Errorf("%w: suara %s bergender %s", ErrVoiceSettingsInvalid, v.Name, v.Gender)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: suara %q tidak tersedia di provider %s", ErrVoiceSettingsInvalid, s.VoiceName, s.Provider)
+}
+
+// ResolveVoiceSettings menggabungkan preferensi tersimpan user dengan override per
+// permintaan. Override yang tidak kosong divalidasi ulang bersama hasil gabungannya.
+func ResolveVoiceSettings(ctx context.Context, userID int, override models.VoiceSettings) (models.VoiceSettings, error) {
+	prefs, err := GetVoicePreferences(ctx, userID)
+	if err != nil {
+		return models.VoiceSettings{}, err
+	}
+	if override == (models.VoiceSettings{}) {
+		return prefs.VoiceSettings, nil
+	}
+
+	s := prefs.VoiceSettings
+	if override.Provider != "" && override.Provider != s.Provider {
+		// Nama suara tersimpan milik provider lain
+		s.Provider = override.Provider
+		s.VoiceName = ""
+	}
+	if override.Gender != "" {
+		s.Gender = override.Gender
+		if override.VoiceName == "" {
+			s.VoiceName = ""
+		}
+	}
+	if override.VoiceName != "" {
+		s.VoiceName = override.VoiceName
+	}
+	if override.SpeakingRate != 0 {
+		s.SpeakingRate = override.SpeakingRate
+	}
+	if override.Pitch != 0 {
+		s.Pitch = override.Pitch
+	}
+
+	if err := ValidateVoiceSettings(ctx, &s); err != nil {
+		return models.VoiceSettings{}, err
+	}
+	return s, nil
+}
+
+// userVoiceSettings mengambil preferensi suara untuk sintesis di latar belakang;
+// kegagalan membaca preferensi tidak menggagalkan TTS
+func userVoiceSettings(ctx context.Context, userID int) models.VoiceSettings {
+	prefs, err := GetVoicePreferences(ctx, userID)
+	if err != nil {
+		config.Log.Warnf("Gagal mengambil preferensi suara user %d: %v", userID, err)
+		return models.VoiceSettings{}
+	}
+	return prefs.VoiceSettings
+}
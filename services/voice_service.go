@@ -8,13 +8,12 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"path"
+	"strings"
 	"time"
 
 	"backend-go/config"
-	"backend-go/models"
 
-	texttospeech "cloud.google.com/go/texttospeech/apiv1"
-	texttospeechpb "cloud.google.com/go/texttospeech/apiv1/texttospeechpb"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -28,55 +27,19 @@ var (
 	httpClient       = http.Client{Timeout: 10 * time.Second} // HTTP client untuk ambil hasil transkripsi
 )
 
-// InitVoiceServices inisialisasi klien Polly, Transcribe, dan S3
+// InitVoiceServices inisialisasi klien Transcribe dan S3. Tanpa konfigurasi AWS kedua
+// klien dibiarkan kosong dan audio disimpan lokal.
 func InitVoiceServices() {
+	if !config.AWSEnabled() {
+		log.Println("ℹ️ Layanan suara berjalan tanpa S3 dan Transcribe")
+		return
+	}
 	cfg := config.AWSConfig
 	transcribeClient = transcribe.NewFromConfig(cfg)
 	s3Client = s3.NewFromConfig(cfg)
 	log.Println("✅ Layanan suara (Transcribe & S3) telah diinisialisasi.")
 }
 
-// SynthesizeSpeech mengubah teks menjadi audio mp3 menggunakan Amazon Polly
-func SynthesizeSpeech(text string) ([]byte, error) {
-	log.Println("📝 Teks yang diterima oleh Google TTS:", fmt.Sprintf("[%s]", text))
-
-	ctx := context.Background()
-
-	client, err := texttospeech.NewClient(ctx)
-	if err != nil {
-		log.Println("❌ Gagal membuat client:", err)
-		return nil, fmt.Errorf("failed to create client: %w", err)
-	}
-	defer client.Close()
-
-	req := &texttospeechpb.SynthesizeSpeechRequest{
-		Input: &texttospeechpb.SynthesisInput{
-			InputSource: &texttospeechpb.SynthesisInput_Text{Text: text},
-		},
-		Voice: &texttospeechpb.VoiceSelectionParams{
-			LanguageCode: "id-ID",
-			SsmlGender:   texttospeechpb.SsmlVoiceGender_MALE,
-		},
-		AudioConfig: &texttospeechpb.AudioConfig{
-			AudioEncoding: texttospeechpb.AudioEncoding_MP3,
-		},
-	}
-
-	resp, err := client.SynthesizeSpeech(ctx, req)
-	if err != nil {
-		log.Println("❌ Gagal synthesize:", err)
-		return nil, fmt.Errorf("failed to synthesize: %w", err)
-	}
-
-	if len(resp.AudioContent) == 0 {
-		log.Println("⚠️ TTS menghasilkan audio kosong.")
-		return nil, fmt.Errorf("empty audio content")
-	}
-
-	log.Printf("✅ Suara bot sudah disintesis, size: %d bytes", len(resp.AudioContent))
-	return resp.AudioContent, nil
-}
-
 // UploadAudioToS3 mengunggah file audio ke S3 agar bisa diproses oleh Transcribe
 func UploadUserVoiceToS3(fileName string, audio []byte) (string, error) {
 	uploader := manager.NewUploader(s3Client)
@@ -87,7 +50,7 @@ func UploadUserVoiceToS3(fileName string, audio []byte) (string, error) {
 		Bucket:      aws.String(config.AWSBucketName),
 		Key:         aws.String(key),
 		Body:        bytes.NewReader(audio),
-		ContentType: aws.String("audio/mpeg"),
+		ContentType: aws.String(AudioContentType(fileName)),
 	}
 
 	_, err := uploader.Upload(context.TODO(), upInput)
@@ -108,7 +71,7 @@ func UploadBotVoiceToS3(fileName string, audio []byte) (string, error) {
 		Bucket:      aws.String(config.AWSBucketName),
 		Key:         aws.String(key),
 		Body:        bytes.NewReader(audio),
-		ContentType: aws.String("audio/mpeg"),
+		ContentType: aws.String(AudioContentType(fileName)),
 	}
 
 	_, err := uploader.Upload(context.TODO(), upInput)
@@ -120,47 +83,17 @@ func UploadBotVoiceToS3(fileName string, audio []byte) (string, error) {
 	return audioURL, nil
 }
 
-func TranscribeAudio(audioData []byte) (string, string, error) {
-	audioFileName := fmt.Sprintf("user-audio-%d.mp3", time.Now().Unix())
-	log.Printf("🔼 Mengunggah audio pengguna ke S3: %s", audioFileName)
-
-	// ✅ Upload audio user ke S3
-	audioURL, err := UploadUserVoiceToS3(audioFileName, audioData)
-	if err != nil {
-		log.Printf("❌ Gagal mengunggah audio pengguna ke S3: %v", err)
-		return "", "", fmt.Errorf("failed to upload audio: %v", err)
-	}
-	log.Printf("✅ Audio pengguna berhasil diunggah ke S3, URI: %s", audioURL)
-
-	jobName := fmt.Sprintf("transcribe-job-%d", time.Now().Unix())
-	log.Printf("🎙️ Mulai tugas transkripsi: %s", jobName)
-
-	// ✅ Kirim S3 URI ke AWS Transcribe
-	err = StartTranscriptionJob(jobName, audioURL)
-	if err != nil {
-		log.Printf("❌ Gagal memulai tugas transkripsi: %v", err)
-		return "", "", fmt.Errorf("failed to start transcription job: %v", err)
-	}
-
-	log.Printf("⌛ Menunggu hasil transkripsi untuk tugas: %s", jobName)
-	transcript, err := GetTranscriptionResult(jobName)
-	if err != nil {
-		return "", "", err
-	}
-
-	return transcript, audioURL, nil
-}
-
 // StartTranscriptionJob memulai proses transkripsi dengan Amazon Transcribe
-func StartTranscriptionJob(jobName, mediaUri string) error {
+func StartTranscriptionJob(jobName, mediaUri string, mediaFormat transcribeTypes.MediaFormat) error {
 	input := &transcribe.StartTranscriptionJobInput{
 		TranscriptionJobName: aws.String(jobName),
 		LanguageCode:         transcribeTypes.LanguageCodeIdId,
-		MediaFormat:          transcribeTypes.MediaFormatMp3,
+		MediaFormat:          mediaFormat,
 		Media: &transcribeTypes.Media{
 			MediaFileUri: aws.String(mediaUri),
 		},
 		OutputBucketName: aws.String(config.AWSBucketName),
+		OutputKey:        aws.String(TranscriptOutputKey(jobName)),
 	}
 
 	_, err := transcribeClient.StartTranscriptionJob(context.TODO(), input)
@@ -170,10 +103,42 @@ func StartTranscriptionJob(jobName, mediaUri string) error {
 	return nil
 }
 
-// GetTranscriptionResult mengambil hasil transkripsi jika sudah selesai
-func GetTranscriptionResult(jobName string) (string, error) {
-	for i := 0; i < 20; i++ {
-		job, err := transcribeClient.GetTranscriptionJob(context.TODO(), &transcribe.GetTranscriptionJobInput{
+// ensureTranscriptionJob memulai job Transcribe jika belum ada. Job yang pernah gagal
+// dihapus lalu dimulai ulang dengan nama yang sama agar retry tidak membuat job ganda.
+func ensureTranscriptionJob(ctx context.Context, jobName, mediaUri string, mediaFormat transcribeTypes.MediaFormat) error {
+	existing, err := transcribeClient.GetTranscriptionJob(ctx, &transcribe.GetTranscriptionJobInput{
+		TranscriptionJobName: aws.String(jobName),
+	})
+	if err == nil {
+		if existing.TranscriptionJob.TranscriptionJobStatus != transcribeTypes.TranscriptionJobStatusFailed {
+			return nil
+		}
+		if _, err := transcribeClient.DeleteTranscriptionJob(ctx, &transcribe.DeleteTranscriptionJobInput{
+			TranscriptionJobName: aws.String(jobName),
+		}); err != nil {
+			return fmt.Errorf("gagal menghapus tugas transkripsi yang gagal: %v", err)
+		}
+	}
+	return StartTranscriptionJob(jobName, mediaUri, mediaFormat)
+}
+
+// TranscribeJobName mengembalikan nama job Transcribe untuk file audio pengguna
+func TranscribeJobName(audioFileName string) string {
+	return strings.TrimSuffix(path.Base(audioFileName), path.Ext(audioFileName))
+}
+
+// TranscriptOutputKey adalah lokasi JSON hasil Transcribe di bucket
+func TranscriptOutputKey(jobName string) string {
+	return "transcripts/" + jobName + ".json"
+}
+
+// GetTranscriptionResult menunggu job Transcribe selesai lalu mengambil transkripnya.
+// Batas waktu ditentukan ctx.
+func GetTranscriptionResult(ctx context.Context, jobName string) (string, error) {
+	const pollInterval = 3 * time.Second
+
+	for {
+		job, err := transcribeClient.GetTranscriptionJob(ctx, &transcribe.GetTranscriptionJobInput{
 			TranscriptionJobName: aws.String(jobName),
 		})
 		if err != nil {
@@ -189,10 +154,12 @@ func GetTranscriptionResult(jobName string) (string, error) {
 			return "", errors.New("tugas transkripsi gagal")
 		}
 
-		time.Sleep(3 * time.Second) // tunggu sebelum cek ulang
+		select {
+		case <-ctx.Done():
+			return "", errors.New("tugas transkripsi kehabisan waktu")
+		case <-time.After(pollInterval): // tunggu sebelum cek ulang
+		}
 	}
-
-	return "", errors.New("tugas transkripsi kehabisan waktu")
 }
 
 // fetchTranscriptFromURL mengambil hasil transkrip dari URL (berformat JSON)
@@ -225,32 +192,3 @@ func fetchTranscriptFromURL(url string) (string, error) {
 
 	return result.Results.Transcripts[0].Transcript, nil
 }
-
-func SaveVoiceChatHistory(chatID string, userID int, transcript, intent, userAudioURL, botAudioURL, responseMessage string) error {
-	collection := config.MongoDB.Collection("voice_messages")
-
-	now := time.Now()
-
-	userMsg := models.VoiceMessage{
-		ChatID:     chatID,
-		UserID:     userID,
-		Sender:     "user",
-		AudioURL:   userAudioURL,
-		Transcript: transcript,
-		Intent:     intent,
-		Timestamp:  now,
-	}
-
-	botMsg := models.VoiceMessage{
-		ChatID:     chatID,
-		UserID:     userID,
-		Sender:     "bot",
-		AudioURL:   botAudioURL,
-		Transcript: responseMessage,
-		Intent:     intent,
-		Timestamp:  now.Add(1 * time.Millisecond),
-	}
-
-	_, err := collection.InsertMany(context.TODO(), []interface{}{userMsg, botMsg})
-	return err
-}
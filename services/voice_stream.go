@@ -0,0 +1,440 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Jenis event yang dikirim server ke klien suara streaming
+const (
+	VoiceEventReady          = "ready"           // sesi siap menerima audio
+	VoiceEventTranscript     = "transcript"      // transkrip sementara atau final
+	VoiceEventResponse       = "response"        // balasan teks dari NLP
+	VoiceEventAudioStart     = "audio_start"     // potongan audio balasan menyusul sebagai frame biner
+	VoiceEventAudioEnd       = "audio_end"       // semua potongan audio terkirim
+	VoiceEventAudioCancelled = "audio_cancelled" // pemutaran dihentikan karena barge-in
+	VoiceEventSaved          = "saved"           // giliran tersimpan di riwayat chat
+	VoiceEventError          = "error"
+)
+
+// Pesan kontrol dari klien
+const (
+	VoiceControlStart   = "start"    // awal ucapan; memotong balasan yang sedang diputar
+	VoiceControlStop    = "stop"     // akhir ucapan; transkrip final diproses
+	VoiceControlBargeIn = "barge_in" // hentikan pemutaran tanpa memulai ucapan
+)
+
+var (
+	ErrVoiceStreamBusy    = errors.New("ucapan sebelumnya belum diakhiri dengan stop")
+	ErrVoiceStreamIdle    = errors.New("belum ada ucapan yang dimulai")
+	ErrVoiceStreamControl = errors.New("pesan kontrol tidak dikenal")
+	ErrVoiceStreamFormat  = errors.New("encoding harus pcm16 atau webm_opus")
+	ErrVoiceStreamLimited = errors.New("terlalu banyak ucapan, silakan coba lagi nanti")
+)
+
+// VoiceStreamEvent adalah pesan JSON dari server ke klien
+type VoiceStreamEvent struct {
+	Type      string   `json:"type"`
+	Turn      int      `json:"turn,omitempty"`
+	Text      string   `json:"text,omitempty"`
+	Final     bool     `json:"final,omitempty"`
+	Intent    string   `json:"intent,omitempty"`
+	Format    string   `json:"format,omitempty"`
+	AudioURL  string   `json:"audio_url,omitempty"`
+	MessageID string   `json:"message_id,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Reasons   []string `json:"reasons,omitempty"`
+}
+
+// VoiceStreamControl adalah pesan JSON dari klien
+type VoiceStreamControl struct {
+	Type       string `json:"type"`
+	Encoding   string `json:"encoding"`    // pcm16 (default) atau webm_opus
+	SampleRate int    `json:"sample_rate"` // untuk pcm16, default 16000
+}
+
+// VoiceStreamSink mengirim event dan potongan audio ke klien. Implementasi harus
+// aman dipanggil dari beberapa goroutine.
+type VoiceStreamSink interface {
+	SendEvent(event VoiceStreamEvent) error
+	SendAudio(chunk []byte) error
+}
+
+// voiceStreamTurn adalah satu ucapan yang transkrip finalnya sudah lengkap
+type voiceStreamTurn struct {
+	number     int
+	transcript string
+	audio      []byte
+	cfg        STTStreamConfig
+}
+
+// utteranceAudio adalah salinan audio dan format satu ucapan yang diambil saat ucapan
+// diakhiri, sebelum ucapan berikutnya mengosongkan buffer sesi
+type utteranceAudio struct {
+	audio []byte
+	cfg   STTStreamConfig
+}
+
+// VoiceStreamSession mengelola satu koneksi percakapan suara: audio masuk diteruskan
+// ke STT streaming, transkrip final diproses NLP, lalu balasan TTS dikirim kembali
+// sepotong demi sepotong. Giliran diproses berurutan agar riwayat chat tetap urut.
+// Sesi tidak bergantung pada transport sehingga bisa diuji tanpa WebSocket.
+type VoiceStreamSession struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	chatID string
+	userID int
+	voice  models.VoiceSettings
+	format string // format audio balasan
+	sink   VoiceStreamSink
+	turns  chan voiceStreamTurn
+
+	// allowTurn dipanggil di awal setiap ucapan untuk rate limit; nil = tanpa batas
+	allowTurn func(ctx context.Context) error
+	// uploadAudio dan saveTurn menyimpan giliran; diganti di pengujian
+	uploadAudio func(ctx context.Context, id string, t voiceStreamTurn) (string, error)
+	saveTurn    func(ctx context.Context, job *models.VoiceJob) error
+
+	collectors sync.WaitGroup // pembaca hasil STT per ucapan
+	worker     sync.WaitGroup // pemroses giliran
+
+	mu         sync.Mutex
+	turn       int                 // nomor ucapan terakhir
+	stt        STTStream           // ucapan yang sedang berlangsung
+	sttCfg     STTStreamConfig     // format audio ucapan berlangsung
+	audio      bytes.Buffer        // audio ucapan berlangsung, disimpan ke riwayat
+	captured   chan utteranceAudio // tujuan salinan audio saat ucapan berlangsung diakhiri
+	playCancel context.CancelFunc  // pemutaran balasan yang sedang berjalan
+}
+
+// NewVoiceStreamSession membuat sesi dan mengirim event ready. allowTurn membatasi
+// jumlah ucapan (mis. rate limit grup "voice") dan boleh nil.
+func NewVoiceStreamSession(ctx context.Context, chatID string, userID int, voice models.VoiceSettings, format string, sink VoiceStreamSink, allowTurn func(ctx context.Context) error) *VoiceStreamSession {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &VoiceStreamSession{
+		ctx:         ctx,
+		cancel:      cancel,
+		chatID:      chatID,
+		userID:      userID,
+		voice:       voice,
+		format:      format,
+		sink:        sink,
+		turns:       make(chan voiceStreamTurn, 4),
+		allowTurn:   allowTurn,
+		uploadAudio: uploadStreamAudio,
+		saveTurn:    runVoiceSave,
+	}
+	s.worker.Add(1)
+	go s.processTurns()
+	sink.SendEvent(VoiceStreamEvent{Type: VoiceEventReady, Format: format})
+	return s
+}
+
+// HandleControl menjalankan pesan kontrol dari klien
+func (s *VoiceStreamSession) HandleControl(ctl VoiceStreamControl) error {
+	switch ctl.Type {
+	case VoiceControlStart:
+		return s.startUtterance(ctl)
+	case VoiceControlStop:
+		return s.stopUtterance()
+	case VoiceControlBargeIn:
+		s.bargeIn()
+		return nil
+	}
+	return ErrVoiceStreamControl
+}
+
+// HandleAudio meneruskan potongan audio ke STT. Audio tanpa start didahului start
+// otomatis dengan PCM 16 kHz.
+func (s *VoiceStreamSession) HandleAudio(chunk []byte) error {
+	s.mu.Lock()
+	active := s.stt != nil
+	s.mu.Unlock()
+	if !active {
+		if err := s.startUtterance(VoiceStreamControl{Type: VoiceControlStart}); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	if s.stt == nil {
+		s.mu.Unlock()
+		return ErrVoiceStreamIdle
+	}
+	s.audio.Write(chunk)
+	if err := s.stt.Send(chunk); err != nil {
+		s.mu.Unlock()
+		return fmt.Errorf("gagal mengirim audio ke STT: %v", err)
+	}
+
+	// Ucapan terlalu panjang diakhiri otomatis, masih di bawah kunci yang sama agar
+	// salinan audionya tepat berisi potongan yang sudah dikirim ke STT
+	var err error
+	if s.audio.Len() >= config.VoiceStreamMaxUtteranceBytes {
+		err = s.endUtteranceLocked()
+	}
+	s.mu.Unlock()
+	return err
+}
+
+// Close menghentikan sesi dan menunggu giliran yang sudah final selesai disimpan
+func (s *VoiceStreamSession) Close() {
+	s.mu.Lock()
+	if s.stt != nil {
+		s.endUtteranceLocked()
+	}
+	s.mu.Unlock()
+
+	// Transkrip final yang masih ditunggu tetap diantrekan sebelum antrean ditutup
+	s.collectors.Wait()
+	s.bargeIn()
+	close(s.turns)
+	s.worker.Wait()
+	s.cancel()
+}
+
+func (s *VoiceStreamSession) startUtterance(ctl VoiceStreamControl) error {
+	cfg := STTStreamConfig{Language: "id-ID", Encoding: ctl.Encoding, SampleRate: ctl.SampleRate}
+	if cfg.Encoding == "" {
+		cfg.Encoding = StreamEncodingPCM16
+	}
+	if cfg.Encoding != StreamEncodingPCM16 && cfg.Encoding != StreamEncodingWebMOpus {
+		return ErrVoiceStreamFormat
+	}
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = voiceSampleRate
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stt != nil {
+		return ErrVoiceStreamBusy
+	}
+	if s.allowTurn != nil {
+		if err := s.allowTurn(s.ctx); err != nil {
+			return err
+		}
+	}
+
+	// Barge-in: user mulai bicara saat balasan sebelumnya masih diputar
+	if s.playCancel != nil {
+		s.playCancel()
+	}
+
+	stream, err := sttStream.StartStream(s.ctx, cfg)
+	if err != nil {
+		return err
+	}
+	s.turn++
+	s.stt = stream
+	s.sttCfg = cfg
+	s.audio.Reset()
+	s.captured = make(chan utteranceAudio, 1)
+
+	s.collectors.Add(1)
+	go s.collectTranscript(s.turn, stream, s.captured)
+	return nil
+}
+
+func (s *VoiceStreamSession) stopUtterance() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.endUtteranceLocked()
+}
+
+// endUtteranceLocked menyalin audio ucapan berlangsung untuk pengumpul transkripnya
+// lalu menutup stream STT. Pemanggil memegang s.mu.
+func (s *VoiceStreamSession) endUtteranceLocked() error {
+	if s.stt == nil {
+		return ErrVoiceStreamIdle
+	}
+	s.captured <- utteranceAudio{audio: bytes.Clone(s.audio.Bytes()), cfg: s.sttCfg}
+	err := s.stt.CloseSend()
+	s.stt = nil
+	s.captured = nil
+	return err
+}
+
+func (s *VoiceStreamSession) bargeIn() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.playCancel != nil {
+		s.playCancel()
+	}
+}
+
+// collectTranscript meneruskan transkrip sementara ke klien dan mengantrekan
+// transkrip final setelah stream STT selesai. Audio giliran diambil dari salinan yang
+// dibuat saat ucapan diakhiri, bukan dari buffer sesi yang mungkin sudah dipakai
+// ucapan berikutnya.
+func (s *VoiceStreamSession) collectTranscript(turn int, stream STTStream, captured <-chan utteranceAudio) {
+	defer s.collectors.Done()
+
+	var finals []string
+	for result := range stream.Results() {
+		if result.Err != nil {
+			s.sink.SendEvent(VoiceStreamEvent{Type: VoiceEventError, Turn: turn, Error: result.Err.Error()})
+			return
+		}
+		if result.Final {
+			finals = append(finals, result.Text)
+			continue
+		}
+		s.sink.SendEvent(VoiceStreamEvent{
+			Type: VoiceEventTranscript,
+			Turn: turn,
+			Text: strings.TrimSpace(strings.Join(append(finals, result.Text), " ")),
+		})
+	}
+
+	transcript := strings.TrimSpace(strings.Join(finals, " "))
+	if transcript == "" {
+		s.sink.SendEvent(VoiceStreamEvent{Type: VoiceEventError, Turn: turn, Error: "tidak ada ucapan yang terdeteksi"})
+		return
+	}
+
+	// Stream STT bisa selesai lebih dulu; tunggu sampai ucapan benar-benar diakhiri
+	var utterance utteranceAudio
+	select {
+	case utterance = <-captured:
+	case <-s.ctx.Done():
+		return
+	}
+	t := voiceStreamTurn{
+		number:     turn,
+		transcript: transcript,
+		audio:      utterance.audio,
+		cfg:        utterance.cfg,
+	}
+
+	s.sink.SendEvent(VoiceStreamEvent{Type: VoiceEventTranscript, Turn: turn, Text: transcript, Final: true})
+	select {
+	case s.turns <- t:
+	case <-s.ctx.Done():
+	}
+}
+
+// processTurns menjalankan giliran satu per satu
+func (s *VoiceStreamSession) processTurns() {
+	defer s.worker.Done()
+	for t := range s.turns {
+		if err := s.processTurn(t); err != nil {
+			event := VoiceStreamEvent{Type: VoiceEventError, Turn: t.number, Error: "Gagal memproses ucapan"}
+			var fatal *voiceStageFatal
+			if errors.As(err, &fatal) {
+				event.Error = fatal.Error()
+				event.Reasons = fatal.reasons
+			} else {
+				config.Log.Error("Gagal memproses giliran suara streaming: ", err)
+			}
+			s.sink.SendEvent(event)
+		}
+	}
+}
+
+// processTurn memakai tahap NLP dan penyimpanan yang sama dengan job suara sehingga
+// moderasi, masking PII, dan format pesan tetap seragam
+func (s *VoiceStreamSession) processTurn(t voiceStreamTurn) error {
+	job := models.VoiceJob{
+		ID:            primitive.NewObjectID(),
+		ChatID:        s.chatID,
+		UserID:        s.userID,
+		Transcript:    t.transcript,
+		Voice:         s.voice,
+		UserMessageID: primitive.NewObjectID(),
+		BotMessageID:  primitive.NewObjectID(),
+	}
+
+	if err := runVoiceNLP(s.ctx, &job); err != nil {
+		return err
+	}
+	s.sink.SendEvent(VoiceStreamEvent{Type: VoiceEventResponse, Turn: t.number, Text: job.Response, Intent: job.Intent})
+
+	tts, err := SynthesizeAudio(s.ctx, TTSRequest{Text: job.Response, Voice: job.Voice, Format: s.format})
+	if err != nil {
+		return err
+	}
+	job.BotAudioURL = tts.URL
+	job.TTSProvider = tts.Provider
+
+	// Balasan sudah ada: giliran tetap disimpan walaupun klien memotong atau terputus
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(s.ctx), 30*time.Second)
+	defer cancel()
+
+	s.play(t.number, tts)
+
+	if url, err := s.uploadAudio(saveCtx, job.UserMessageID.Hex(), t); err != nil {
+		config.Log.Warn("Gagal menyimpan audio ucapan streaming: ", err)
+	} else {
+		job.UserAudioURL = url
+	}
+	if err := s.saveTurn(saveCtx, &job); err != nil {
+		return err
+	}
+	s.sink.SendEvent(VoiceStreamEvent{Type: VoiceEventSaved, Turn: t.number, MessageID: job.BotMessageID.Hex()})
+	return nil
+}
+
+// play mengirim audio balasan per potongan VOICE_STREAM_CHUNK_BYTES sampai selesai atau
+// dibatalkan barge-in
+func (s *VoiceStreamSession) play(turn int, tts *TTSAudio) {
+	playCtx, cancel := context.WithCancel(s.ctx)
+	s.mu.Lock()
+	s.playCancel = cancel
+	// User sudah mulai bicara lagi sebelum balasan ini siap diputar
+	if s.turn > turn {
+		cancel()
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.playCancel = nil
+		s.mu.Unlock()
+		cancel()
+	}()
+
+	if playCtx.Err() == nil {
+		s.sink.SendEvent(VoiceStreamEvent{Type: VoiceEventAudioStart, Turn: turn, Format: tts.Format, AudioURL: tts.URL})
+	}
+	for audio := tts.Audio; len(audio) > 0; {
+		if playCtx.Err() != nil {
+			s.sink.SendEvent(VoiceStreamEvent{Type: VoiceEventAudioCancelled, Turn: turn})
+			return
+		}
+		n := min(len(audio), config.VoiceStreamChunkBytes)
+		if err := s.sink.SendAudio(audio[:n]); err != nil {
+			return
+		}
+		audio = audio[n:]
+	}
+	s.sink.SendEvent(VoiceStreamEvent{Type: VoiceEventAudioEnd, Turn: turn})
+}
+
+// uploadStreamAudio mengubah audio ucapan ke VOICE_STT_FORMAT mono 16 kHz, sama dengan
+// rekaman unggahan, lalu menyimpannya
+func uploadStreamAudio(ctx context.Context, id string, t voiceStreamTurn) (string, error) {
+	opts, err := transcodeOptionsFor(config.VoiceSTTFormat)
+	if err != nil {
+		return "", err
+	}
+	opts.SampleRate = voiceSampleRate
+	opts.Channels = 1
+	if t.cfg.Encoding == StreamEncodingPCM16 {
+		opts.InputArgs = []string{"-f", "s16le", "-ar", strconv.Itoa(t.cfg.SampleRate), "-ac", "1"}
+	}
+	audio, err := transcoder.Transcode(ctx, t.audio, opts)
+	if err != nil {
+		return "", err
+	}
+	return StoreUserVoice("user-stream-"+id+"."+config.VoiceSTTFormat, audio)
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"backend-go/config"
+	"backend-go/models"
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// recordingSink menyimpan semua event dan potongan audio yang dikirim sesi
+type recordingSink struct {
+	mu     sync.Mutex
+	events []VoiceStreamEvent
+	audio  int
+}
+
+func (s *recordingSink) SendEvent(event VoiceStreamEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *recordingSink) SendAudio(chunk []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audio += len(chunk)
+	return nil
+}
+
+func (s *recordingSink) eventsFor(turn int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var types []string
+	for _, e := range s.events {
+		if e.Turn == turn {
+			types = append(types, e.Type)
+		}
+	}
+	return types
+}
+
+func TestVoiceStreamSessionOffline(t *testing.T) {
+	withoutAWS(t, fakeSTT{transcript: "halo bot"})
+	useFakeNLP(t, "halo bot", "Halo juga.")
+
+	oldStream, oldMax, oldChunk := sttStream, config.VoiceStreamMaxUtteranceBytes, config.VoiceStreamChunkBytes
+	defer func() {
+		sttStream, config.VoiceStreamMaxUtteranceBytes, config.VoiceStreamChunkBytes = oldStream, oldMax, oldChunk
+	}()
+	sttStream = fakeSTT{transcript: "halo bot"}
+	config.VoiceStreamMaxUtteranceBytes = 8
+	config.VoiceStreamChunkBytes = 4
+
+	// Dua ucapan diizinkan, ucapan ketiga terkena rate limit
+	turns := 0
+	allowTurn := func(context.Context) error {
+		if turns++; turns > 2 {
+			return ErrVoiceStreamLimited
+		}
+		return nil
+	}
+
+	sink := &recordingSink{}
+	session := NewVoiceStreamSession(context.Background(), "chat-stream", 1, models.VoiceSettings{}, AudioFormatMP3, sink, allowTurn)
+
+	var mu sync.Mutex
+	uploaded := map[int][]byte{}
+	var saved []models.VoiceJob
+	session.uploadAudio = func(_ context.Context, _ string, turn voiceStreamTurn) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		uploaded[turn.number] = turn.audio
+		return "/voice/audio/user-stream.mp3", nil
+	}
+	session.saveTurn = func(_ context.Context, job *models.VoiceJob) error {
+		mu.Lock()
+		defer mu.Unlock()
+		saved = append(saved, *job)
+		return nil
+	}
+
+	// Giliran 1 diakhiri stop lalu giliran 2 langsung dimulai sehingga buffer audio sesi
+	// dipakai ulang sebelum transkrip giliran 1 selesai dikumpulkan
+	if err := session.HandleControl(VoiceStreamControl{Type: VoiceControlStart}); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.HandleAudio([]byte("aaaa")); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.HandleControl(VoiceStreamControl{Type: VoiceControlStop}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Giliran 2 dimulai otomatis oleh audio dan diakhiri otomatis karena melewati batas
+	if err := session.HandleAudio([]byte("bbbbbbbb")); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.HandleControl(VoiceStreamControl{Type: VoiceControlStop}); !errors.Is(err, ErrVoiceStreamIdle) {
+		t.Errorf("ucapan yang melewati batas harus sudah berakhir, stop = %v", err)
+	}
+
+	if err := session.HandleAudio([]byte("cccc")); !errors.Is(err, ErrVoiceStreamLimited) {
+		t.Errorf("giliran ketiga harus terkena rate limit, dapat %v", err)
+	}
+	session.Close()
+
+	if len(saved) != 2 {
+		t.Fatalf("giliran tersimpan = %d, ingin 2", len(saved))
+	}
+	for _, job := range saved {
+		if job.Transcript != "halo bot" || job.Response != "Halo juga." || job.TTSProvider != "fake" {
+			t.Errorf("giliran tersimpan = %+v", job)
+		}
+	}
+	if !bytes.Equal(uploaded[1], []byte("aaaa")) || !bytes.Equal(uploaded[2], []byte("bbbbbbbb")) {
+		t.Errorf("audio per giliran = %q, %q", uploaded[1], uploaded[2])
+	}
+	for turn := 1; turn <= 2; turn++ {
+		events := sink.eventsFor(turn)
+		for _, want := range []string{VoiceEventTranscript, VoiceEventResponse, VoiceEventSaved} {
+			found := false
+			for _, e := range events {
+				found = found || e == want
+			}
+			if !found {
+				t.Errorf("giliran %d tidak mengirim event %s: %v", turn, want, events)
+			}
+		}
+	}
+	if sink.audio == 0 {
+		t.Error("audio balasan tidak pernah dikirim")
+	}
+}